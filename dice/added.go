@@ -0,0 +1,354 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"math"
+
+	"github.com/richardwilkes/toolbox/v2/xrand"
+)
+
+// maxAddedCounts is the most combinations of the number of faces that exploded and that did not which addedDice.mean
+// visits individually. Beyond that, the mean is approximated from the expected number of each.
+const maxAddedCounts = 4096
+
+// addedDice describes the faces rolled by a prepared Dice whose explosions add dice and that keeps or drops some of
+// them. Each die rolls faces that explode until it rolls one that does not, or until it has exploded as many times as
+// it may. Every face that explodes is higher than every face that does not, so once sorted, the faces rolled are those
+// that did not explode followed by those that did. Given how many there are of each, the faces within each group are
+// independent of one another, so the kept dice are a selection from the top or bottom of one group, possibly along with
+// every die of the other.
+type addedDice struct {
+	dice       Dice
+	explosions int
+	// low is the distribution of a face that does not explode, or nil if every face explodes.
+	low *pmf
+	// high is the distribution of a face that explodes.
+	high *pmf
+	// q is the chance of a roll landing on a face that explodes.
+	q float64
+}
+
+// addedCounts holds the chance of each number of faces that exploded, for one number of faces that did not.
+type addedCounts struct {
+	// weight is the chance of stopped faces not exploding.
+	weight float64
+	// exploded is the distribution of the number of faces that exploded, given that stopped faces did not.
+	exploded *pmf
+	stopped  int
+}
+
+// keptGroups identifies the dice kept from each group: the sorted indexes from lowFrom up to lowTo of lowCount faces
+// that did not explode, and from highFrom up to highTo of highCount faces that did. A group with nothing kept is left
+// zeroed.
+type keptGroups struct {
+	lowCount, lowFrom, lowTo    int
+	highCount, highFrom, highTo int
+}
+
+// newAddedDice returns a description of the faces rolled by a prepared Dice whose explosions add dice, when each die
+// may explode up to the given number of times and each roll may be rolled again up to the given number of rerolls, or
+// nil if the distribution of a single face is too large to build.
+func newAddedDice(dice Dice, explosions, rerolls int) *addedDice {
+	if dice.Sides > maxSupport {
+		return nil
+	}
+	threshold := dice.explodesAt()
+	a := &addedDice{dice: dice, explosions: explosions, q: chanceAtLeast(dice, rerolls, threshold)}
+	lowChance, highChance := faceChances(dice, rerolls)
+	chanceOf := func(face int) float64 {
+		if face <= dice.RerollThreshold {
+			return lowChance
+		}
+		return highChance
+	}
+	a.high = newPMF(threshold, dice.Sides)
+	for i := range a.high.probs {
+		a.high.probs[i] = chanceOf(threshold+i) / a.q
+	}
+	if threshold > 1 && a.q < 1 {
+		a.low = newPMF(1, threshold-1)
+		for i := range a.low.probs {
+			a.low.probs[i] = chanceOf(1+i) / (1 - a.q)
+		}
+	}
+	return a
+}
+
+// addedDiceRange returns the lowest and highest total of the dice kept by a prepared Dice whose explosions add dice,
+// when each die may explode up to the given number of times. The fewest dice are rolled when none explode, unless every
+// face explodes, and the most when every roll explodes. Every face kept may be a 1 in the former case, and must be the
+// highest face in the latter.
+func addedDiceRange(dice Dice, explosions int) (low, high int) {
+	fewest := dice.Count
+	most := dice.Count * (explosions + 1)
+	if dice.explodesAt() == 1 {
+		fewest = most
+	}
+	from, to := dice.keptRangeOf(fewest)
+	low = to - from
+	from, to = dice.keptRangeOf(most)
+	return low, (to - from) * dice.Sides
+}
+
+// capped returns the chance of a single die rolling a face that explodes on every roll it may make, including the last,
+// which can no longer explode.
+func (a *addedDice) capped() float64 {
+	if a.low == nil {
+		return 1
+	}
+	return math.Pow(a.q, float64(a.explosions+1))
+}
+
+// chain returns the distribution of the number of faces that explode before a single die rolls one that does not,
+// given that it does so before exploding as many times as it may. Numbers whose chance is negligible are not followed.
+func (a *addedDice) chain() *pmf {
+	longest := a.explosions
+	if a.q > 0 {
+		longest = min(longest, int(math.Ceil(math.Log(negligible)/math.Log(a.q))))
+	}
+	chain := newPMF(0, longest)
+	var total float64
+	chance := 1.0
+	for i := range chain.probs {
+		chain.probs[i] = chance
+		total += chance
+		chance *= a.q
+	}
+	for i := range chain.probs {
+		chain.probs[i] /= total
+	}
+	return chain
+}
+
+// counts returns the chance of each combination of the number of faces that did not explode and the number that did,
+// or nil if it would be too large to compute. A die that explodes as many times as it may rolls only faces that
+// explode, as even its last roll explodes with the usual chance, while any other die rolls some faces that explode and
+// then one that does not. Combinations whose chance is negligible are not followed.
+func (a *addedDice) counts() []addedCounts {
+	count := a.dice.Count
+	rolls := a.explosions + 1
+	if a.low == nil {
+		return []addedCounts{{weight: 1, exploded: &pmf{lo: count * rolls, probs: []float64{1}}}}
+	}
+	capped := a.capped()
+	chain := a.chain()
+	var result []addedCounts
+	var work float64
+	for k := 0; k <= count; k++ {
+		weight := binomialChance(count, k, capped)
+		if weight < negligible {
+			if float64(k) > float64(count)*capped {
+				break
+			}
+			continue
+		}
+		// k dice rolled only faces that explode, while the rest each stopped on a face that did not.
+		exploded := convolvePower(chain, count-k)
+		if exploded == nil {
+			return nil
+		}
+		if work += float64(len(exploded.probs)) * float64(len(chain.probs)); work > maxWork {
+			return nil
+		}
+		result = append(result, addedCounts{weight: weight, exploded: shiftPMF(exploded, k*rolls), stopped: count - k})
+	}
+	return result
+}
+
+// groups returns the dice kept from each group when stopped faces did not explode and exploded faces did.
+func (a *addedDice) groups(stopped, exploded int) keptGroups {
+	from, to := a.dice.keptRangeOf(stopped + exploded)
+	var g keptGroups
+	if from < stopped && to > from {
+		g.lowCount, g.lowFrom, g.lowTo = stopped, from, min(to, stopped)
+	}
+	if to > stopped && to > from {
+		g.highCount, g.highFrom, g.highTo = exploded, max(from, stopped)-stopped, to-stopped
+	}
+	return g
+}
+
+// distribution returns the distribution of the total of the kept dice, or nil if it would be too large to compute.
+func (a *addedDice) distribution() *pmf {
+	counts := a.counts()
+	if counts == nil {
+		return nil
+	}
+	return a.distributionOf(counts)
+}
+
+// distributionOf returns the distribution of the total of the kept dice given the chance of each combination of the
+// number of faces that did not explode and the number that did, or nil if it would be too large to compute.
+func (a *addedDice) distributionOf(counts []addedCounts) *pmf {
+	cache := make(map[keptGroups]*pmf)
+	var totals *pmf
+	var work float64
+	for _, c := range counts {
+		for i, p := range c.exploded.probs {
+			chance := c.weight * p
+			if chance < negligible {
+				continue
+			}
+			g := a.groups(c.stopped, c.exploded.lo+i)
+			kept, ok := cache[g]
+			if !ok {
+				low := selectedPart(a.low, g.lowCount, g.lowFrom, g.lowTo)
+				high := selectedPart(a.high, g.highCount, g.highFrom, g.highTo)
+				if low == nil || high == nil {
+					return nil
+				}
+				if kept = convolve(low, high); kept == nil {
+					return nil
+				}
+				// Selecting the kept dice of a group costs about one multiply-add for each total, face and kept die.
+				keep := g.lowTo - g.lowFrom + g.highTo - g.highFrom
+				work += float64(len(kept.probs)) * float64(a.dice.Sides) * float64(keep)
+				cache[g] = kept
+			}
+			if work += float64(len(kept.probs)); work > maxWork {
+				return nil
+			}
+			if totals = addScaled(totals, kept, chance); totals == nil {
+				return nil
+			}
+		}
+	}
+	return totals
+}
+
+// mean returns the average total of the kept dice. The mean of the exact distribution is used whenever it can be
+// computed, so that it agrees with Distribution.Mean. Failing that, the average kept total of each combination of the
+// number of faces that did not explode and the number that did is weighted by its chance, and when there are too many
+// combinations for that, the average is approximated by that of the expected number of each.
+func (a *addedDice) mean() float64 {
+	counts := a.counts()
+	if counts != nil {
+		if totals := a.distributionOf(counts); totals != nil {
+			return totals.mean()
+		}
+		var combinations int
+		for _, c := range counts {
+			combinations += len(c.exploded.probs)
+		}
+		if combinations > maxAddedCounts {
+			counts = nil
+		}
+	}
+	if counts == nil {
+		count := float64(a.dice.Count)
+		stopped := math.Round(count * (1 - a.capped()))
+		exploded := math.Round(count * expectedExplosions(a.q, a.explosions+1))
+		return a.keptMean(a.groups(int(stopped), int(exploded)))
+	}
+	cache := make(map[keptGroups]float64)
+	var mean float64
+	for _, c := range counts {
+		for i, p := range c.exploded.probs {
+			g := a.groups(c.stopped, c.exploded.lo+i)
+			kept, ok := cache[g]
+			if !ok {
+				kept = a.keptMean(g)
+				cache[g] = kept
+			}
+			mean += c.weight * p * kept
+		}
+	}
+	return mean
+}
+
+// keptMean returns the average total of the dice kept from each group.
+func (a *addedDice) keptMean(g keptGroups) float64 {
+	return selectedPartMean(a.low, g.lowCount, g.lowFrom, g.lowTo) +
+		selectedPartMean(a.high, g.highCount, g.highFrom, g.highTo)
+}
+
+// sample returns a sample of the total of the kept dice. The number of dice that explode as many times as they may is
+// sampled first, then the number of faces that exploded in the remaining dice, and finally which of those faces land on
+// each total, working inward from the kept end.
+func (a *addedDice) sample(rnd xrand.Randomizer) int {
+	capped := sampleBinomial(a.dice.Count, a.capped(), rnd)
+	stopped := a.dice.Count - capped
+	exploded := capped * (a.explosions + 1)
+	if stopped > 0 {
+		chain := a.chain()
+		mean, variance, _ := pmfDie(chain).moments()
+		low, high := chain.bounds()
+		exploded += sampleSum(stopped, mean, variance, low, high, 1, rnd)
+	}
+	from, to := a.dice.keptRangeOf(stopped + exploded)
+	s := newKeptSample(stopped+exploded, from, to)
+	if s.topDown {
+		s.add(pmfDie(a.high), exploded, rnd)
+		if a.low != nil {
+			s.add(pmfDie(a.low), stopped, rnd)
+		}
+	} else {
+		if a.low != nil {
+			s.add(pmfDie(a.low), stopped, rnd)
+		}
+		s.add(pmfDie(a.high), exploded, rnd)
+	}
+	return s.total
+}
+
+// selectedPart returns the distribution of the total of the dice at the sorted indexes from up to to among count dice
+// that are each distributed as die, or nil if it would be too large to compute. The indexes must include the lowest or
+// the highest of the dice.
+func selectedPart(die *pmf, count, from, to int) *pmf {
+	switch {
+	case from >= to:
+		return &pmf{probs: []float64{1}}
+	case from == 0 && to == count:
+		return convolvePower(die, count)
+	case to == count:
+		return selectedDistribution(die, Dice{Count: count, Selection: KeepHighest, SelectCount: to - from})
+	default:
+		return selectedDistribution(die, Dice{Count: count, Selection: KeepLowest, SelectCount: to})
+	}
+}
+
+// selectedPartMean returns the average total of the dice at the sorted indexes from up to to among count dice that are
+// each distributed as die. The indexes must include the lowest or the highest of the dice.
+func selectedPartMean(die *pmf, count, from, to int) float64 {
+	switch {
+	case from >= to:
+		return 0
+	case from == 0 && to == count:
+		return float64(count) * die.mean()
+	case to == count:
+		return selectedMean(Dice{Count: count, Selection: KeepHighest, SelectCount: to - from}, die)
+	default:
+		return selectedMean(Dice{Count: count, Selection: KeepLowest, SelectCount: to}, die)
+	}
+}
+
+// addScaled adds the chances of src, each multiplied by scale, to those of dst, widening dst as needed, and returns the
+// result, or nil if it would be too large. A nil dst is treated as empty.
+func addScaled(dst, src *pmf, scale float64) *pmf {
+	srcLow, srcHigh := src.bounds()
+	if dst == nil {
+		dst = newPMF(srcLow, srcHigh)
+	} else if dstLow, dstHigh := dst.bounds(); srcLow < dstLow || srcHigh > dstHigh {
+		low, high := min(srcLow, dstLow), max(srcHigh, dstHigh)
+		if high-low >= maxSupport {
+			return nil
+		}
+		wider := newPMF(low, high)
+		copy(wider.probs[dstLow-low:], dst.probs)
+		dst = wider
+	}
+	offset := srcLow - dst.lo
+	for i, p := range src.probs {
+		dst.probs[offset+i] += p * scale
+	}
+	return dst
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"math"
+
+	"github.com/richardwilkes/toolbox/v2/errs"
+)
+
+// AddDice returns an Expression for the sum of the two Dice. When the Dice are compatible, the result is a single dice
+// term, so 2d6+1 plus 1d6 gives 3d6+1. Dice are compatible when both keep all of their dice and differ in nothing but
+// their Count and Modifier, such as 2d6!+1 and 1d6!, or when one of them is a constant whose value divides evenly by the
+// Multiplier of the other. Otherwise, or when the combined Dice would exceed the Config's MaxCount or MaxModifier, the
+// result has a separate term for each, such as 2d6+1d4. Use Expression.Dice to retrieve the combined Dice. An error is
+// returned only if rolling the result could overflow an int.
+func (r *Roller) AddDice(a, b Dice) (*Expression, error) {
+	return r.combineDice('+', a, b)
+}
+
+// SubtractDice returns an Expression for the first Dice less the second. When the Dice are compatible, as described for
+// AddDice, and the first has at least as many dice as the second, the dice of the second are removed from the first, as
+// a penalty of -1d does in GURPS, so 3d6+2 less 1d6+1 gives 2d6+1. Otherwise, the result subtracts a roll of the second
+// from a roll of the first, such as 2d6-1d4. An error is returned only if rolling the result could overflow an int.
+func (r *Roller) SubtractDice(a, b Dice) (*Expression, error) {
+	return r.combineDice('-', a, b)
+}
+
+func (r *Roller) combineDice(op byte, a, b Dice) (*Expression, error) {
+	a = r.Normalize(a)
+	b = r.Normalize(b)
+	if op == '+' && a.Count == 0 && b.Count != 0 {
+		a, b = b, a
+	}
+	if dice, ok := r.combinedDice(op, a, b); ok {
+		return &Expression{roller: r, root: r.diceTerm(dice)}, nil
+	}
+	node, reason := newBinaryNode(op, r.diceTerm(a), r.diceTerm(b))
+	if reason != "" {
+		return nil, errs.New(reason)
+	}
+	return &Expression{roller: r, root: node}, nil
+}
+
+// combinedDice returns the single Dice that results from combining the normalized Dice with the operator, if there is
+// one within the Config's limits.
+func (r *Roller) combinedDice(op byte, a, b Dice) (Dice, bool) {
+	combine := checkedAdd
+	if op == '-' {
+		combine = checkedSub
+	}
+	if a.Count == 0 {
+		a = Dice{Modifier: a.Modifier * a.Multiplier, Multiplier: 1}
+	}
+	var count, modifier int
+	var ok bool
+	switch {
+	case b.Count == 0:
+		// A constant folds into the Modifier, which the Multiplier then applies to.
+		value := b.Modifier * b.Multiplier
+		if value%a.Multiplier != 0 {
+			return Dice{}, false
+		}
+		count = a.Count
+		modifier, ok = combine(a.Modifier, value/a.Multiplier)
+	case a.Count == 0:
+		// Dice cannot be removed from a constant.
+		return Dice{}, false
+	default:
+		if a.Selection != SelectAll || b.Selection != SelectAll {
+			return Dice{}, false
+		}
+		kindA := a
+		kindA.Count, kindA.Modifier = 0, 0
+		kindB := b
+		kindB.Count, kindB.Modifier = 0, 0
+		if kindA != kindB {
+			return Dice{}, false
+		}
+		if count, ok = combine(a.Count, b.Count); ok {
+			modifier, ok = combine(a.Modifier, b.Modifier)
+		}
+	}
+	cfg := r.config()
+	if !ok || count < 0 || count > cfg.MaxCount || modifier < -cfg.MaxModifier || modifier > cfg.MaxModifier {
+		return Dice{}, false
+	}
+	a.Count = count
+	a.Modifier = modifier
+	return r.Normalize(a), true
+}
+
+// diceTerm returns an Expression node for the normalized Dice.
+func (r *Roller) diceTerm(dice Dice) exprNode {
+	if dice.Count == 0 {
+		return &constantNode{value: dice.Modifier * dice.Multiplier}
+	}
+	return &diceNode{dice: dice, min: r.Minimum(dice), max: r.Maximum(dice)}
+}
+
+// Dice returns the Expression as a single Dice, if it consists of nothing but one dice term or constant, as the result
+// of AddDice or SubtractDice does when it combines its Dice. A constant beyond the Config's MaxModifier is not returned.
+func (e *Expression) Dice() (Dice, bool) {
+	switch n := e.root.(type) {
+	case *diceNode:
+		return n.dice, true
+	case *constantNode:
+		if limit := e.roller.config().MaxModifier; n.value >= -limit && n.value <= limit {
+			return Dice{Modifier: n.value, Multiplier: 1}, true
+		}
+	}
+	return Dice{}, false
+}
+
+// ScaleDice returns the Dice with its dice and Modifier multiplied by factor, as when a hit does one and a half times its
+// usual damage. The scaled Count is rounded down to whole dice, and the average result of the fraction of a die left
+// over is added to the scaled Modifier, which is then rounded to the nearest whole number, with halves rounded away from
+// zero. For example, 2d6+1 scaled by 1.5 gives 3d6+2 and 1d6 scaled by 1.5 gives 1d6+2. The Multiplier is unchanged. An
+// error is returned if factor is negative or not a finite number, if the Dice keep or drop some of their dice, or if the
+// result would exceed the Config's MaxCount or MaxModifier.
+func (r *Roller) ScaleDice(dice Dice, factor float64) (Dice, error) {
+	if !(factor >= 0) || math.IsInf(factor, 1) {
+		return Dice{}, errs.Newf("scale factor %v must be a finite number no less than 0", factor)
+	}
+	dice = r.Normalize(dice)
+	if dice.Selection != SelectAll {
+		return Dice{}, errs.New("dice that keep or drop some of their dice cannot be scaled")
+	}
+	cfg := r.config()
+	count := float64(dice.Count) * factor
+	whole := math.Floor(count)
+	if whole > float64(cfg.MaxCount) {
+		return Dice{}, errs.Newf("scaled die count exceeds %d", cfg.MaxCount)
+	}
+	modifier := float64(dice.Modifier) * factor
+	if fraction := count - whole; fraction > 0 {
+		one := dice
+		one.Count = 1
+		modifier += fraction * r.diceMean(one)
+	}
+	modifier = math.Round(modifier)
+	if math.Abs(modifier) > float64(cfg.MaxModifier) {
+		return Dice{}, errs.Newf("scaled modifier exceeds %d", cfg.MaxModifier)
+	}
+	dice.Count = int(whole)
+	dice.Modifier = int(modifier)
+	return r.Normalize(dice), nil
+}
+
+// MultiplyDiceCount returns the Dice with its Count multiplied by factor, leaving its Modifier unchanged, as a critical
+// hit does in D&D, so 2d6+3 doubled gives 4d6+3. An error is returned if factor is less than 0, if the Dice keep or drop
+// some of their dice, or if the result would exceed the Config's MaxCount.
+func (r *Roller) MultiplyDiceCount(dice Dice, factor int) (Dice, error) {
+	if factor < 0 {
+		return Dice{}, errs.Newf("factor %d may not be less than 0", factor)
+	}
+	dice = r.Normalize(dice)
+	if dice.Selection != SelectAll {
+		return Dice{}, errs.New("dice that keep or drop some of their dice cannot be multiplied")
+	}
+	cfg := r.config()
+	if dice.Count != 0 && factor > cfg.MaxCount/dice.Count {
+		return Dice{}, errs.Newf("multiplied die count exceeds %d", cfg.MaxCount)
+	}
+	dice.Count *= factor
+	return r.Normalize(dice), nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestAddAndSubtractDice(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		A          string
+		B          string
+		Add        string
+		Subtract   string
+		Added      bool
+		Subtracted bool
+	}{
+		{"2d6+1", "1d6", "3d6+1", "d6+1", true, true},                // 0
+		{"3d6+2", "1d6+1", "4d6+3", "2d6+1", true, true},             // 1
+		{"2d6!+1", "d6!", "3d6!+1", "d6!+1", true, true},             // 2
+		{"2d10>=7", "3d10>=7+1", "5d10>=7+1", "", true, false},       // 3 - cannot remove more dice than there are
+		{"2d6", "2d6", "4d6", "0", true, true},                       // 4
+		{"2d6+1", "3", "2d6+4", "2d6-2", true, true},                 // 5
+		{"3", "2d6", "2d6+3", "3-2d6", true, false},                  // 6
+		{"3", "4", "7", "-1", true, true},                            // 7
+		{"2d6x2", "4", "2d6+2x2", "2d6-2x2", true, true},             // 8
+		{"2d6x2", "3", "2d6x2+3", "2d6x2-3", false, false},           // 9
+		{"2d6", "1d4", "2d6+d4", "2d6-d4", false, false},             // 10
+		{"2d6", "d6!", "2d6+d6!", "2d6-d6!", false, false},           // 11
+		{"4d6kh3", "4d6kh3", "4d6kh3+4d6kh3", "", false, false},      // 12
+		{"2d6x2", "1d6x3", "2d6x2+d6x3", "2d6x2-d6x3", false, false}, // 13
+		{"2d{0,1}", "d{0,1}", "3d{0,1}", "d{0,1}", true, true},       // 14
+		{"4dF", "2dF+1", "6dF+1", "2dF-1", true, true},               // 15
+	} {
+		desc := fmt.Sprintf("Table index %d: %s and %s", i, one.A, one.B)
+		a := r.Parse(one.A)
+		b := r.Parse(one.B)
+		sum, err := r.AddDice(a, b)
+		c.NoError(err, desc)
+		c.Equal(one.Add, sum.String(), desc)
+		d, ok := sum.Dice()
+		c.Equal(one.Added, ok, desc)
+		if ok {
+			c.Equal(one.Add, r.Format(d), desc)
+			c.Equal(r.Minimum(a)+r.Minimum(b), r.Minimum(d), desc)
+			c.Equal(r.Maximum(a)+r.Maximum(b), r.Maximum(d), desc)
+		}
+		if one.Subtract == "" {
+			one.Subtract = r.Format(a) + "-" + r.Format(b)
+		}
+		difference, err := r.SubtractDice(a, b)
+		c.NoError(err, desc)
+		c.Equal(one.Subtract, difference.String(), desc)
+		_, ok = difference.Dice()
+		c.Equal(one.Subtracted, ok, desc)
+	}
+}
+
+func TestAddDiceLimits(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	// Combined dice beyond the Config's limits are kept as separate terms.
+	sum, err := r.AddDice(r.Parse("999999d6"), r.Parse("1d6"))
+	c.NoError(err)
+	c.Equal("999999d6+d6", sum.String())
+	_, ok := sum.Dice()
+	c.False(ok)
+	sum, err = r.AddDice(r.Parse("d6+999999"), r.Parse("d6+1"))
+	c.NoError(err)
+	c.Equal("d6+999999+d6+1", sum.String())
+	sum, err = r.AddDice(dice.Dice{Modifier: 999999, Multiplier: 999999}, r.Parse("1"))
+	c.NoError(err)
+	c.Equal(999999*999999+1, sum.Roll())
+	_, ok = sum.Dice()
+	c.False(ok)
+
+	// A Config whose limits reach the edge of equationOverflows may still overflow once two maximal Dice are combined.
+	cfg := dice.DefaultConfig()
+	cfg.MaxCount = 1
+	cfg.MaxSides = 2
+	cfg.MaxModifier = math.MaxInt - 3
+	cfg.MaxMultiplier = 1
+	cfg.CustomDice = nil
+	edge, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	big := dice.Dice{Count: 1, Sides: 2, Modifier: math.MaxInt - 3, Multiplier: 1}
+	_, err = edge.AddDice(big, big)
+	c.HasError(err)
+	_, err = edge.SubtractDice(big, big)
+	c.NoError(err)
+}
+
+func TestScaleDice(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		Dice     string
+		Factor   float64
+		Expected string
+	}{
+		{"2d6+1", 1.5, "3d6+2"},     // 0
+		{"1d6", 1.5, "d6+2"},        // 1
+		{"3d6", 0.5, "d6+2"},        // 2
+		{"2d6+3", 2, "4d6+6"},       // 3
+		{"2d6-3", 0.5, "d6-2"},      // 4 - the modifier of -1.5 rounds away from zero
+		{"1d8", 0.5, "2"},           // 5 - half of a d8 averages 2.25
+		{"2d6+1x3", 1.5, "3d6+2x3"}, // 6
+		{"4d6!", 0.25, "d6!"},       // 7
+		{"5", 1.5, "8"},             // 8
+		{"2d6+1", 0, "0"},           // 9
+		{"2dF+1", 1.5, "3dF+2"},     // 10
+	} {
+		desc := fmt.Sprintf("Table index %d: %s x %v", i, one.Dice, one.Factor)
+		d, err := r.ScaleDice(r.Parse(one.Dice), one.Factor)
+		c.NoError(err, desc)
+		c.Equal(one.Expected, r.Format(d), desc)
+	}
+	for i, factor := range []float64{-1, math.NaN(), math.Inf(1)} {
+		_, err := r.ScaleDice(r.Parse("2d6"), factor)
+		c.HasError(err, fmt.Sprintf("Table index %d", i))
+	}
+	_, err := r.ScaleDice(r.Parse("4d6kh3"), 2)
+	c.HasError(err)
+	_, err = r.ScaleDice(r.Parse("999999d6"), 1.5)
+	c.HasError(err)
+	_, err = r.ScaleDice(r.Parse("d6+999999"), 1.5)
+	c.HasError(err)
+}
+
+func TestMultiplyDiceCount(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		Dice     string
+		Factor   int
+		Expected string
+	}{
+		{"2d6+3", 2, "4d6+3"},       // 0
+		{"d8+2x2", 3, "3d8+2x2"},    // 1
+		{"2d6!", 2, "4d6!"},         // 2
+		{"2d6+3", 0, "3"},           // 3
+		{"5", 2, "5"},               // 4
+		{"333333d6", 3, "999999d6"}, // 5
+	} {
+		desc := fmt.Sprintf("Table index %d: %s x %d", i, one.Dice, one.Factor)
+		d, err := r.MultiplyDiceCount(r.Parse(one.Dice), one.Factor)
+		c.NoError(err, desc)
+		c.Equal(one.Expected, r.Format(d), desc)
+	}
+	_, err := r.MultiplyDiceCount(r.Parse("2d6"), -1)
+	c.HasError(err)
+	_, err = r.MultiplyDiceCount(r.Parse("4d6kh3"), 2)
+	c.HasError(err)
+	_, err = r.MultiplyDiceCount(r.Parse("500000d6"), 2)
+	c.HasError(err)
+	_, err = r.MultiplyDiceCount(r.Parse("2d6"), math.MaxInt)
+	c.HasError(err)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// RollN rolls the dice n times and returns each result, in order. It draws exactly the same values from the Randomizer
+// as calling Roll n times, but prepares the Dice only once.
+func (r *Roller) RollN(dice Dice, n int) []int {
+	results := make([]int, max(n, 0))
+	dice = r.prepare(dice)
+	for i := range results {
+		results[i] = r.rollPrepared(dice)
+	}
+	return results
+}
+
+// Rolls returns an endless sequence of rolls of the dice, each drawing exactly the same values from the Randomizer as a
+// call to Roll. The sequence ends only when the caller stops ranging over it.
+func (r *Roller) Rolls(dice Dice) iter.Seq[int] {
+	dice = r.prepare(dice)
+	return func(yield func(int) bool) {
+		for yield(r.rollPrepared(dice)) {
+		}
+	}
+}
+
+// Derive returns a Roller with the same Config as this one, but whose Randomizer is an independent stream derived from
+// this Roller's. When the Randomizer is a SeededRandomizer, the stream is the one its Derive method returns for the
+// given id, so each goroutine of a simulation can be given its own Roller that neither contends with the others nor
+// depends on how their rolls interleave, while the simulation as a whole remains reproducible from a single seed. Any
+// other Randomizer cannot be derived from, so this Roller is returned as is.
+func (r *Roller) Derive(id uint64) *Roller {
+	cfg := r.config()
+	seeded, ok := cfg.Randomizer.(*SeededRandomizer)
+	if !ok {
+		return r
+	}
+	cfg = cfg.Clone()
+	cfg.Randomizer = seeded.Derive(id)
+	return &Roller{cfg: cfg}
+}
+
+// RollParallel rolls the dice n times, spreading the rolls across the given number of goroutines, and returns each
+// result. A workers value less than 1 uses one goroutine for each available CPU. The rolls are divided into one
+// contiguous block per goroutine, and the goroutine rolling block i uses the Roller returned by Derive(i), so with a
+// SeededRandomizer the results are reproducible for a given seed and number of workers. Any other Randomizer cannot be
+// derived from, so a seed is first drawn from it and the goroutines derive their streams from that instead; they still
+// draw independently of one another, and the results are reproducible whenever the Randomizer's values are.
+func (r *Roller) RollParallel(dice Dice, n, workers int) []int {
+	results := make([]int, max(n, 0))
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(min(workers, len(results)), 1)
+	dice = r.prepare(dice)
+	r = r.seeded()
+	var wg sync.WaitGroup
+	for i := range workers {
+		block := results[i*len(results)/workers : (i+1)*len(results)/workers]
+		roller := r.Derive(uint64(i))
+		wg.Go(func() {
+			for j := range block {
+				block[j] = roller.rollPrepared(dice)
+			}
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+// seeded returns this Roller if its Randomizer is a SeededRandomizer. Otherwise, it returns a Roller with the same
+// Config, but whose Randomizer is a SeededRandomizer with a seed drawn from this Roller's Randomizer.
+func (r *Roller) seeded() *Roller {
+	cfg := r.config()
+	if _, ok := cfg.Randomizer.(*SeededRandomizer); ok {
+		return r
+	}
+	// Intn takes an int, which may be only 32 bits wide, so the seed is drawn 16 bits at a time.
+	var seed uint64
+	for range 4 {
+		seed = seed<<16 | uint64(cfg.Randomizer.Intn(1<<16))
+	}
+	cfg = cfg.Clone()
+	cfg.Randomizer = NewSeededRandomizer(seed)
+	return &Roller{cfg: cfg}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func newSeededRoller(c check.Checker, seed uint64) *dice.Roller {
+	c.Helper()
+	cfg := dice.DefaultConfig()
+	cfg.Randomizer = dice.NewSeededRandomizer(seed)
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	return r
+}
+
+func TestRollN(t *testing.T) {
+	c := check.New(t)
+	for i, text := range []string{"3d6+2", "4d6kh3", "d6!", "8d10>=7", "d100oe", "4dF", "5"} {
+		desc := fmt.Sprintf("Table index %d: %s", i, text)
+		single := newSeededRoller(c, 11)
+		batch := newSeededRoller(c, 11)
+		d := single.Parse(text)
+		want := make([]int, 50)
+		for j := range want {
+			want[j] = single.Roll(d)
+		}
+		c.Equal(want, batch.RollN(d, len(want)), desc)
+
+		seq := newSeededRoller(c, 11)
+		var got []int
+		for v := range seq.Rolls(d) {
+			got = append(got, v)
+			if len(got) == len(want) {
+				break
+			}
+		}
+		c.Equal(want, got, desc)
+	}
+	r := newSeededRoller(c, 11)
+	c.Equal(0, len(r.RollN(r.Parse("3d6"), 0)))
+	c.Equal(0, len(r.RollN(r.Parse("3d6"), -5)))
+}
+
+func TestDerive(t *testing.T) {
+	c := check.New(t)
+	rnd := dice.NewSeededRandomizer(5)
+	first := rnd.Derive(1)
+	for range 10 {
+		rnd.Intn(100)
+	}
+	// A derived sequence does not depend on the position of the one it was derived from.
+	second := rnd.Derive(1)
+	c.Equal(first.Seed(), second.Seed())
+	c.NotEqual(first.Seed(), rnd.Derive(2).Seed())
+	c.NotEqual(first.Seed(), dice.NewSeededRandomizer(6).Derive(1).Seed())
+	c.NotEqual(rnd.Seed(), rnd.Derive(0).Seed())
+
+	r := newSeededRoller(c, 5)
+	d := r.Parse("10d6")
+	a := r.Derive(3).RollN(d, 20)
+	c.Equal(a, r.Derive(3).RollN(d, 20))
+	c.NotEqual(a, r.Derive(4).RollN(d, 20))
+	c.NotEqual(a, r.RollN(d, 20))
+
+	// A Randomizer that cannot be derived from is shared.
+	plain := newRoller(c, nil, false, false)
+	c.True(plain == plain.Derive(1))
+	var unset *dice.Roller
+	c.True(unset.Derive(1) == nil)
+}
+
+func TestRollParallel(t *testing.T) {
+	c := check.New(t)
+	d := dice.Dice{Count: 3, Sides: 6, Modifier: 1, Multiplier: 1}
+	for i, one := range []struct {
+		N       int
+		Workers int
+	}{
+		{1000, 4}, // 0
+		{10, 3},   // 1
+		{3, 8},    // 2 - never more goroutines than rolls
+		{0, 4},    // 3
+		{100, 1},  // 4
+	} {
+		desc := fmt.Sprintf("Table index %d", i)
+		r := newSeededRoller(c, 77)
+		results := r.RollParallel(d, one.N, one.Workers)
+		c.Equal(one.N, len(results), desc)
+		c.Equal(results, newSeededRoller(c, 77).RollParallel(d, one.N, one.Workers), desc)
+
+		// Each goroutine rolls a contiguous block with the Roller derived for its position.
+		workers := max(min(one.Workers, one.N), 1)
+		var want []int
+		for w := range workers {
+			want = append(want, r.Derive(uint64(w)).RollN(d, (w+1)*one.N/workers-w*one.N/workers)...)
+		}
+		c.Equal(len(want), len(results), desc)
+		if one.N != 0 {
+			c.Equal(want, results, desc)
+		}
+		for _, v := range results {
+			c.True(v >= 4 && v <= 19, desc)
+		}
+	}
+	c.Equal(100, len(newRoller(c, nil, false, false).RollParallel(d, 100, 0)))
+
+	// A Randomizer that cannot be derived from only provides the seed the goroutines derive their streams from, so
+	// they draw independently, and the results are reproducible when its values are.
+	rnd := &sequenceRandomizer{values: []int{5, 1, 4, 2}}
+	results := newRoller(c, rnd, false, false).RollParallel(d, 1000, 4)
+	c.Equal(4, rnd.next)
+	c.NotEqual(results[:250], results[250:500])
+	c.Equal(results, newRoller(c, &sequenceRandomizer{values: []int{5, 1, 4, 2}}, false, false).RollParallel(d, 1000, 4))
+}
+
+func TestRollerSharedAcrossGoroutines(t *testing.T) {
+	c := check.New(t)
+	r := newSeededRoller(c, 3)
+	d := r.Parse("4d6kh3")
+	var wg sync.WaitGroup
+	results := make([][]int, 8)
+	for i := range results {
+		wg.Go(func() {
+			results[i] = r.RollN(d, 500)
+		})
+	}
+	wg.Wait()
+	for _, one := range results {
+		c.Equal(500, len(one))
+		for _, v := range one {
+			c.True(v >= 3 && v <= 18)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+// Comparison determines how the result of a Check is compared to its target.
+type Comparison uint8
+
+// Possible Comparison values.
+const (
+	// RollOver succeeds when the result is at or above the target, as with a d20 roll against a difficulty class.
+	RollOver Comparison = iota
+	// RollUnder succeeds when the result is at or below the target, as with a 3d6 roll against a GURPS skill.
+	RollUnder
+)
+
+// Outcome is the outcome of a Check.
+type Outcome int8
+
+// Possible Outcome values, ordered from worst to best.
+const (
+	CriticalFailure Outcome = iota
+	Failure
+	Success
+	CriticalSuccess
+)
+
+// String implements fmt.Stringer.
+func (o Outcome) String() string {
+	switch o {
+	case CriticalFailure:
+		return "critical failure"
+	case Failure:
+		return "failure"
+	case Success:
+		return "success"
+	case CriticalSuccess:
+		return "critical success"
+	default:
+		return "unknown"
+	}
+}
+
+// Succeeded returns true if the Outcome is a Success or CriticalSuccess.
+func (o Outcome) Succeeded() bool {
+	return o >= Success
+}
+
+// CriticalRule decides the Outcome of a Check. It is given the check's result with its Outcome already set to Success
+// or Failure by comparing the result to the target, and returns the Outcome the check should have instead. It must not
+// rely on the result's Result field, which is nil when computing probabilities.
+type CriticalRule func(res *CheckResult) Outcome
+
+// Check describes a roll of the Dice against a target number.
+type Check struct {
+	// Critical decides the Outcome of the check. When nil, the check only succeeds or fails.
+	Critical CriticalRule
+	Dice     Dice
+	Target   int
+	// DegreeStep, when greater than 0, is the size of the margin that makes up each degree of success or failure. For
+	// example, a DegreeStep of 10 makes a margin of 0 to 9 the first degree of success and a margin of 10 to 19 the
+	// second, while a margin of -1 to -9 is the first degree of failure and -10 to -19 the second.
+	DegreeStep int
+	Comparison Comparison
+}
+
+// CheckResult holds the outcome of a Check.
+type CheckResult struct {
+	// Result holds the details of the roll. It is nil when the CheckResult is being evaluated to compute probabilities.
+	Result *Result
+	// Natural is the total of the kept dice, before the Modifier and Multiplier are applied. For Dice that count
+	// successes, it is the number of successes the dice scored.
+	Natural int
+	// Total is the result of the roll that was compared to the target.
+	Total  int
+	Target int
+	// Margin is the amount by which the check succeeded, when 0 or more, or failed, when less than 0. It is the amount
+	// the Total exceeds the Target by for RollOver, and the amount it falls short of the Target by for RollUnder. A
+	// margin that would overflow an int is held at math.MaxInt-1 or 1-math.MaxInt instead.
+	Margin int
+	// Degree is the degree of success, counting up from 0 for the first degree, or of failure, counting down from -1
+	// for the first degree, as determined by the Check's DegreeStep. Without a DegreeStep, it is 0 for a success and
+	// -1 for a failure.
+	Degree  int
+	Outcome Outcome
+}
+
+// CheckProbabilities holds the exact probability of each Outcome of a Check.
+type CheckProbabilities struct {
+	CriticalFailure float64
+	Failure         float64
+	Success         float64
+	CriticalSuccess float64
+}
+
+// Of returns the probability of the given Outcome.
+func (p *CheckProbabilities) Of(outcome Outcome) float64 {
+	switch outcome {
+	case CriticalFailure:
+		return p.CriticalFailure
+	case Failure:
+		return p.Failure
+	case Success:
+		return p.Success
+	case CriticalSuccess:
+		return p.CriticalSuccess
+	default:
+		return 0
+	}
+}
+
+// Succeeds returns the probability of the check succeeding, critically or not.
+func (p *CheckProbabilities) Succeeds() float64 {
+	return p.Success + p.CriticalSuccess
+}
+
+// Check rolls the check's Dice and compares the result to its target.
+func (r *Roller) Check(check Check) *CheckResult {
+	res := r.RollDetailed(check.Dice)
+	return check.evaluate(res.Subtotal-res.Modifier, res.Total, res)
+}
+
+// CheckProbabilities returns the exact probability of each Outcome of the check, computed from the distribution of its
+// Dice. An error is returned if the distribution would be too large to compute.
+func (r *Roller) CheckProbabilities(check Check) (*CheckProbabilities, error) {
+	dist, err := r.Distribution(check.Dice)
+	if err != nil {
+		return nil, err
+	}
+	var probs CheckProbabilities
+	for i, p := range dist.dice.probs {
+		if p == 0 {
+			continue
+		}
+		switch check.evaluate(dist.dice.lo+i, dist.value(i), nil).Outcome {
+		case CriticalFailure:
+			probs.CriticalFailure += p
+		case Failure:
+			probs.Failure += p
+		case Success:
+			probs.Success += p
+		case CriticalSuccess:
+			probs.CriticalSuccess += p
+		}
+	}
+	return &probs, nil
+}
+
+// evaluate the check for a roll whose kept dice totaled natural and whose result was total.
+func (check *Check) evaluate(natural, total int, detail *Result) *CheckResult {
+	res := &CheckResult{
+		Result:  detail,
+		Natural: natural,
+		Total:   total,
+		Target:  check.Target,
+		Outcome: Failure,
+	}
+	if check.Comparison == RollUnder {
+		res.Margin = saturatingSub(check.Target, total)
+	} else {
+		res.Margin = saturatingSub(total, check.Target)
+	}
+	step := max(check.DegreeStep, 0)
+	switch {
+	case res.Margin >= 0:
+		res.Outcome = Success
+		if step != 0 {
+			res.Degree = res.Margin / step
+		}
+	case step != 0:
+		// A failure by exactly one step begins the second degree of failure, just as a success by one step begins the
+		// second degree of success.
+		res.Degree = -1 - (-res.Margin)/step
+	default:
+		res.Degree = -1
+	}
+	if check.Critical != nil {
+		res.Outcome = check.Critical(res)
+	}
+	return res
+}
+
+// NaturalCriticals returns a CriticalRule for roll-over systems such as those based on a d20: a check whose Natural is
+// at or above high is a CriticalSuccess, even if it would otherwise fail, and one whose Natural is at or below low is a
+// CriticalFailure, even if it would otherwise succeed. For example, NaturalCriticals(1, 20) treats a natural 20 as an
+// automatic critical success and a natural 1 as an automatic critical failure.
+func NaturalCriticals(low, high int) CriticalRule {
+	return func(res *CheckResult) Outcome {
+		switch {
+		case res.Natural >= high:
+			return CriticalSuccess
+		case res.Natural <= low:
+			return CriticalFailure
+		default:
+			return res.Outcome
+		}
+	}
+}
+
+// DegreeCriticals returns a CriticalRule for systems with four degrees of success, such as Pathfinder 2e: a check that
+// succeeds by at least one DegreeStep is a CriticalSuccess and one that fails by at least one DegreeStep is a
+// CriticalFailure. A Natural at or above high then improves the Outcome by one degree, and one at or below low worsens
+// it by one degree. For Pathfinder 2e, use a DegreeStep of 10 with DegreeCriticals(1, 20).
+func DegreeCriticals(low, high int) CriticalRule {
+	return func(res *CheckResult) Outcome {
+		outcome := res.Outcome
+		switch {
+		case res.Degree >= 1:
+			outcome = CriticalSuccess
+		case res.Degree <= -2:
+			outcome = CriticalFailure
+		}
+		switch {
+		case res.Natural >= high && outcome < CriticalSuccess:
+			outcome++
+		case res.Natural <= low && outcome > CriticalFailure:
+			outcome--
+		}
+		return outcome
+	}
+}
+
+// GURPSCriticals is the CriticalRule for a GURPS success roll of 3d6 against an effective skill, made with RollUnder.
+// A roll of 3 or 4 is a CriticalSuccess, as is a 5 against a skill of 15 or more, or a 6 against a skill of 16 or more.
+// A roll of 18 is a CriticalFailure, as is a 17 against a skill of 15 or less, or any roll that fails by 10 or more. A
+// roll of 17 against a higher skill is an ordinary Failure.
+func GURPSCriticals(res *CheckResult) Outcome {
+	switch {
+	case res.Natural <= 4, res.Natural == 5 && res.Target >= 15, res.Natural == 6 && res.Target >= 16:
+		return CriticalSuccess
+	case res.Natural >= 18, res.Natural == 17 && res.Target <= 15, res.Margin <= -10:
+		return CriticalFailure
+	case res.Natural == 17:
+		return Failure
+	default:
+		return res.Outcome
+	}
+}
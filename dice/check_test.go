@@ -0,0 +1,154 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestCheck(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Spec       string
+		Critical   dice.CriticalRule
+		Face       int
+		Target     int
+		DegreeStep int
+		Comparison dice.Comparison
+		Natural    int
+		Total      int
+		Margin     int
+		Degree     int
+		Outcome    dice.Outcome
+	}{
+		{"1d20+5", nil, 9, 15, 0, dice.RollOver, 10, 15, 0, 0, dice.Success},                                    // 0
+		{"1d20+5", nil, 8, 15, 0, dice.RollOver, 9, 14, -1, -1, dice.Failure},                                   // 1
+		{"1d20+5", dice.NaturalCriticals(1, 20), 19, 15, 0, dice.RollOver, 20, 25, 10, 0, dice.CriticalSuccess}, // 2
+		{"1d20+25", dice.NaturalCriticals(1, 20), 0, 15, 0, dice.RollOver, 1, 26, 11, 0, dice.CriticalFailure},  // 3
+		{"1d20", nil, 9, 10, 10, dice.RollOver, 10, 10, 0, 0, dice.Success},                                     // 4
+		{"1d20", nil, 18, 10, 10, dice.RollOver, 19, 19, 9, 0, dice.Success},                                    // 5
+		{"1d20", nil, 19, 10, 10, dice.RollOver, 20, 20, 10, 1, dice.Success},                                   // 6
+		{"1d20", nil, 0, 10, 10, dice.RollOver, 1, 1, -9, -1, dice.Failure},                                     // 7
+		{"1d20", nil, 0, 11, 10, dice.RollOver, 1, 1, -10, -2, dice.Failure},                                    // 8
+		{"1d20", dice.DegreeCriticals(1, 20), 0, 2, 10, dice.RollOver, 1, 1, -1, -1, dice.CriticalFailure},      // 9
+		{"1d20", dice.DegreeCriticals(1, 20), 19, 25, 10, dice.RollOver, 20, 20, -5, -1, dice.Success},          // 10
+		{"1d20", dice.DegreeCriticals(1, 20), 14, 5, 10, dice.RollOver, 15, 15, 10, 1, dice.CriticalSuccess},    // 11
+		{"3d6", nil, 2, 12, 0, dice.RollUnder, 9, 9, 3, 0, dice.Success},                                        // 12
+		{"3d6", dice.GURPSCriticals, 5, 16, 0, dice.RollUnder, 18, 18, -2, -1, dice.CriticalFailure},            // 13
+		{"3d6", dice.GURPSCriticals, 0, 2, 0, dice.RollUnder, 3, 3, -1, -1, dice.CriticalSuccess},               // 14
+		{"3d6", dice.GURPSCriticals, 1, 15, 0, dice.RollUnder, 6, 6, 9, 0, dice.Success},                        // 15
+		{"3d6", dice.GURPSCriticals, 4, 3, 0, dice.RollUnder, 15, 15, -12, -1, dice.CriticalFailure},            // 16
+		{"2d6x3", nil, 5, 30, 0, dice.RollOver, 12, 36, 6, 0, dice.Success},                                     // 17
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Spec)
+		r := newRoller(c, &sequenceRandomizer{values: []int{one.Face}}, false, false)
+		res := r.Check(dice.Check{
+			Critical:   one.Critical,
+			Dice:       r.Parse(one.Spec),
+			Target:     one.Target,
+			DegreeStep: one.DegreeStep,
+			Comparison: one.Comparison,
+		})
+		c.NotNil(res.Result, desc)
+		c.Equal(one.Natural, res.Natural, desc)
+		c.Equal(one.Total, res.Total, desc)
+		c.Equal(one.Target, res.Target, desc)
+		c.Equal(one.Margin, res.Margin, desc)
+		c.Equal(one.Degree, res.Degree, desc)
+		c.Equal(one.Outcome, res.Outcome, desc)
+		c.Equal(one.Outcome >= dice.Success, res.Outcome.Succeeded(), desc)
+	}
+
+	// Margins that would overflow an int are held near its limits rather than wrapping around and reversing the
+	// outcome.
+	r := newRoller(c, &sequenceRandomizer{values: []int{9}}, false, false)
+	for i, one := range []struct {
+		Target     int
+		Comparison dice.Comparison
+		Margin     int
+		Degree     int
+		Outcome    dice.Outcome
+	}{
+		{math.MinInt, dice.RollOver, math.MaxInt - 19, (math.MaxInt - 19) / 10, dice.Success},   // 0
+		{math.MinInt, dice.RollUnder, math.MinInt + 20, -1 - (math.MaxInt-19)/10, dice.Failure}, // 1
+		{math.MaxInt, dice.RollOver, 1 - math.MaxInt, -1 - (math.MaxInt-1)/10, dice.Failure},    // 2
+		{math.MaxInt, dice.RollUnder, math.MaxInt - 1, (math.MaxInt - 1) / 10, dice.Success},    // 3
+	} {
+		desc := fmt.Sprintf("Boundary index %d", i)
+		res := r.Check(dice.Check{
+			Dice:       r.Parse("1d20-30"),
+			Target:     one.Target,
+			DegreeStep: 10,
+			Comparison: one.Comparison,
+		})
+		c.Equal(-20, res.Total, desc)
+		c.Equal(one.Margin, res.Margin, desc)
+		c.Equal(one.Degree, res.Degree, desc)
+		c.Equal(one.Outcome, res.Outcome, desc)
+	}
+	probs, err := r.CheckProbabilities(dice.Check{Dice: r.Parse("1d20-30"), Target: math.MaxInt})
+	c.NoError(err)
+	c.Equal(0.0, probs.Succeeds())
+}
+
+func TestCheckProbabilities(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		Spec            string
+		Critical        dice.CriticalRule
+		Target          int
+		DegreeStep      int
+		Comparison      dice.Comparison
+		CriticalFailure float64
+		Failure         float64
+		Success         float64
+		CriticalSuccess float64
+	}{
+		{"3d6", nil, 10, 0, dice.RollUnder, 0, 108.0 / 216, 108.0 / 216, 0},                                      // 0
+		{"3d6", dice.GURPSCriticals, 10, 0, dice.RollUnder, 4.0 / 216, 104.0 / 216, 104.0 / 216, 4.0 / 216},      // 1
+		{"3d6", dice.GURPSCriticals, 16, 0, dice.RollUnder, 1.0 / 216, 3.0 / 216, 192.0 / 216, 20.0 / 216},       // 2
+		{"3d6", dice.GURPSCriticals, 3, 0, dice.RollUnder, 56.0 / 216, 156.0 / 216, 0, 4.0 / 216},                // 3
+		{"1d20+5", dice.NaturalCriticals(1, 20), 15, 0, dice.RollOver, 1.0 / 20, 8.0 / 20, 10.0 / 20, 1.0 / 20},  // 4
+		{"1d20+10", dice.DegreeCriticals(1, 20), 15, 10, dice.RollOver, 1.0 / 20, 3.0 / 20, 10.0 / 20, 6.0 / 20}, // 5
+		{"1d20", dice.DegreeCriticals(1, 20), 25, 10, dice.RollOver, 15.0 / 20, 4.0 / 20, 1.0 / 20, 0},           // 6
+		{"2d6x3", nil, 30, 0, dice.RollOver, 0, 30.0 / 36, 6.0 / 36, 0},                                          // 7
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Spec)
+		probs, err := r.CheckProbabilities(dice.Check{
+			Critical:   one.Critical,
+			Dice:       r.Parse(one.Spec),
+			Target:     one.Target,
+			DegreeStep: one.DegreeStep,
+			Comparison: one.Comparison,
+		})
+		c.NoError(err, desc)
+		c.True(near(one.CriticalFailure, probs.CriticalFailure), desc)
+		c.True(near(one.Failure, probs.Failure), desc)
+		c.True(near(one.Success, probs.Success), desc)
+		c.True(near(one.CriticalSuccess, probs.CriticalSuccess), desc)
+		c.True(near(one.Success+one.CriticalSuccess, probs.Succeeds()), desc)
+		c.True(near(1, probs.Of(dice.CriticalFailure)+probs.Of(dice.Failure)+probs.Of(dice.Success)+
+			probs.Of(dice.CriticalSuccess)), desc)
+	}
+}
+
+func TestOutcomeString(t *testing.T) {
+	c := check.New(t)
+	c.Equal("critical failure", dice.CriticalFailure.String())
+	c.Equal("failure", dice.Failure.String())
+	c.Equal("success", dice.Success.String())
+	c.Equal("critical success", dice.CriticalSuccess.String())
+}
@@ -12,6 +12,7 @@ package dice
 
 import (
 	"math"
+	"slices"
 	"sync"
 
 	"github.com/richardwilkes/toolbox/v2/errs"
@@ -33,20 +34,45 @@ var (
 		MaxSides:               999_999,
 		MaxModifier:            999_999,
 		MaxMultiplier:          999_999,
+		MaxExplosions:          100,
+		MaxRerolls:             100,
+		FastRollThreshold:      10_000,
+		CustomDice:             map[string][]int{"F": {-1, 0, 1}},
 		GURPSFormat:            false,
 		ExtraDiceFromModifiers: false,
+		DigitDice:              false,
 	}
 )
 
 // Config holds various configuration options Dice.
 type Config struct {
 	// Randomizer is the source of randomness to use when rolling dice. When Clone() is called, it is copied by
-	// reference, so the same Randomizer is used in both the original and the clone.
+	// reference, so the same Randomizer is used in both the original and the clone. Use a SeededRandomizer for rolls that
+	// must be reproducible, or a RecordingRandomizer to capture the values drawn for each roll.
 	Randomizer    xrand.Randomizer
 	MaxCount      int
 	MaxSides      int
 	MaxModifier   int
 	MaxMultiplier int
+	// MaxExplosions is the most times a single die may explode. A die that would explode again after this many
+	// explosions, such as an exploding d1, simply stops. The limit is further reduced for a roll whose result would
+	// otherwise be able to overflow an int.
+	MaxExplosions int
+	// MaxRerolls is the most times a single roll of a die may be rolled again by a reroll modifier. A die that would be
+	// rolled again after this many rerolls, such as a d6 rerolled until it rolls above 6, keeps its last roll.
+	MaxRerolls int
+	// FastRollThreshold, when greater than 0, is the most dice a roll will roll one at a time. The total of a roll with
+	// more dice is instead sampled from a close approximation of its distribution, taking time that does not grow with
+	// the number of dice, so that a roll such as 999999d999999 from an untrusted source cannot stall the caller. Rolls of
+	// no more dice than this draw exactly the same values from the Randomizer as they always have, so seeded results are
+	// unaffected. RollDetailed, and so Check and Contest, sample the same rolls Roll does, reporting a Sampled Result
+	// with no individual dice. The default is 10,000. A value of 0 disables sampling.
+	FastRollThreshold int
+	// CustomDice holds named dice whose faces are other than the numbers 1 through N, such as the Fudge dice of 4dF,
+	// mapping each name to the value of each of the die's faces. A name must start with an uppercase ASCII letter and
+	// contain only ASCII letters, and is matched case-sensitively. The default Config defines F, with the faces -1, 0
+	// and 1. Dice with arbitrary faces may also be written in place, as in 2d{0,0,1,1,2,3}.
+	CustomDice map[string][]int
 	// GURPSFormat determines whether GURPS dice formatting should be used. A value of true means the die count is
 	// always shown and the sides value is suppressed if it is a '6', while a value of false means the die count is
 	// suppressed if it is a '1' and the sides value is always shown.
@@ -54,6 +80,12 @@ type Config struct {
 	// ExtraDiceFromModifiers determines if modifiers greater than or equal to the average result of the base die should
 	// be converted to extra dice for the purposes of display. For example, 1d6+8 will display as 3d6+1.
 	ExtraDiceFromModifiers bool
+	// DigitDice determines whether d66, d666 and d% are read as the dice of Traveller and many old-school tables rather
+	// than as dice with 66, 666 or no sides. Each digit of a d66 or d666 is read from a separate d6, giving results such
+	// as 11 through 16, 21 through 26 and so on up to 66, while the tens and ones of a d% are read from two d10, giving
+	// 1 through 100. Such dice behave as custom dice, so they may keep or drop some of their dice, but may not explode,
+	// be rerolled or count successes. MaxSides may not be less than 666, the highest face of a d666, when enabled.
+	DigitDice bool
 }
 
 // DefaultConfig returns a copy of the default Config that will be used if one isn't explicitly set on a Roller.
@@ -72,10 +104,15 @@ func SetDefaultConfig(opts *Config) {
 	}
 }
 
-// Clone this configuration. Currently, this is a simple copy, but provided so that if the options become more complex
-// in the future, there is one canonical way to clone them.
+// Clone this configuration.
 func (c *Config) Clone() *Config {
 	other := *c
+	if c.CustomDice != nil {
+		other.CustomDice = make(map[string][]int, len(c.CustomDice))
+		for name, faces := range c.CustomDice {
+			other.CustomDice[name] = slices.Clone(faces)
+		}
+	}
 	return &other
 }
 
@@ -111,6 +148,45 @@ func (c *Config) Valid() error {
 	if c.MaxMultiplier > maxFieldValue {
 		return errs.Newf("MaxMultiplier may not be greater than %d", maxFieldValue)
 	}
+	if c.MaxExplosions < 0 {
+		return errs.New("MaxExplosions may not be less than 0")
+	}
+	if c.MaxExplosions > maxFieldValue {
+		return errs.Newf("MaxExplosions may not be greater than %d", maxFieldValue)
+	}
+	if c.MaxRerolls < 0 {
+		return errs.New("MaxRerolls may not be less than 0")
+	}
+	if c.MaxRerolls > maxFieldValue {
+		return errs.Newf("MaxRerolls may not be greater than %d", maxFieldValue)
+	}
+	if c.FastRollThreshold < 0 {
+		return errs.New("FastRollThreshold may not be less than 0")
+	}
+	if c.FastRollThreshold > maxFieldValue {
+		return errs.Newf("FastRollThreshold may not be greater than %d", maxFieldValue)
+	}
+	// The d666 has the most faces of the digit dice, and the highest face, which must fit within MaxSides just as the
+	// faces of CustomDice must.
+	if c.DigitDice && c.MaxSides < maxDigitFace {
+		return errs.Newf("MaxSides may not be less than %d when DigitDice is enabled", maxDigitFace)
+	}
+	for name, faces := range c.CustomDice {
+		if !validCustomDiceName(name) {
+			return errs.Newf("CustomDice name %q must start with an uppercase letter and contain only letters", name)
+		}
+		if len(faces) == 0 {
+			return errs.Newf("CustomDice %q must have at least one face", name)
+		}
+		if len(faces) > c.MaxSides {
+			return errs.Newf("CustomDice %q may not have more than MaxSides (%d) faces", name, c.MaxSides)
+		}
+		for _, face := range faces {
+			if face < -c.MaxSides || face > c.MaxSides {
+				return errs.Newf("CustomDice %q may not have a face beyond ±MaxSides (%d)", name, c.MaxSides)
+			}
+		}
+	}
 	if c.equationOverflows() {
 		return errs.New("max values may cause an overflow")
 	}
@@ -196,11 +272,35 @@ func computeExtraDice(sides, modifier, maxAdjustment int) (dieCountAdjustment, a
 		// untouched. maxAdjustment/2 whole pairs each consume perPair; an odd cap adds one lone die consuming
 		// average+1, charging exactly what the greedy conversion above would have charged those same dice.
 		dieCountAdjustment = maxAdjustment
-		consumed := (maxAdjustment / 2) * perPair
-		if maxAdjustment&1 == 1 {
-			consumed += average + 1
-		}
-		adjustedModifier = modifier - consumed
+		adjustedModifier = modifier - extraDiceCost(sides, maxAdjustment)
 	}
 	return dieCountAdjustment, adjustedModifier
 }
+
+// extraDiceCost returns the modifier that computeExtraDice converts into the given number of dice of the given number
+// of sides: each pair of dice consumes sides+1, and a lone die consumes its average, rounded up.
+func extraDiceCost(sides, count int) int {
+	return (count/2)*(sides+1) + (count&1)*((sides+2)/2)
+}
+
+// saturatingAdd returns a+b, or maxFieldValue or -maxFieldValue if the sum would overflow an int.
+func saturatingAdd(a, b int) int {
+	if sum, ok := checkedAdd(a, b); ok {
+		return sum
+	}
+	if b > 0 {
+		return maxFieldValue
+	}
+	return -maxFieldValue
+}
+
+// saturatingSub returns a-b, or maxFieldValue or -maxFieldValue if the difference would overflow an int.
+func saturatingSub(a, b int) int {
+	if diff, ok := checkedSub(a, b); ok {
+		return diff
+	}
+	if b < 0 {
+		return maxFieldValue
+	}
+	return -maxFieldValue
+}
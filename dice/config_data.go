@@ -0,0 +1,228 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/richardwilkes/toolbox/v2/errs"
+	"github.com/richardwilkes/toolbox/v2/xrand"
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigDataVersion is the version of ConfigData written by this package.
+const CurrentConfigDataVersion = 1
+
+// Randomizer kinds that may be serialized.
+const (
+	// CryptoRandomizerKind identifies the Randomizer returned by xrand.New().
+	CryptoRandomizerKind = "crypto"
+	// SeededRandomizerKind identifies a SeededRandomizer.
+	SeededRandomizerKind = "seeded"
+)
+
+// RandomizerData holds the serializable form of a Config's Randomizer.
+type RandomizerData struct {
+	Kind string `json:"kind" yaml:"kind"`
+	// State is the position of a SeededRandomizer within its sequence. If empty, the sequence starts from the
+	// beginning.
+	State string `json:"state,omitempty" yaml:"state,omitempty"`
+	// Seed is the seed of a SeededRandomizer.
+	Seed uint64 `json:"seed,omitempty" yaml:"seed,omitempty"`
+}
+
+// ConfigData holds the serializable form of a Config. Only a Config whose Randomizer is the one returned by xrand.New()
+// or a SeededRandomizer can be serialized.
+type ConfigData struct {
+	CustomDice             map[string][]int `json:"custom_dice" yaml:"custom_dice"`
+	Randomizer             RandomizerData   `json:"randomizer" yaml:"randomizer"`
+	Version                int              `json:"version" yaml:"version"`
+	MaxCount               int              `json:"max_count" yaml:"max_count"`
+	MaxSides               int              `json:"max_sides" yaml:"max_sides"`
+	MaxModifier            int              `json:"max_modifier" yaml:"max_modifier"`
+	MaxMultiplier          int              `json:"max_multiplier" yaml:"max_multiplier"`
+	MaxExplosions          int              `json:"max_explosions" yaml:"max_explosions"`
+	MaxRerolls             int              `json:"max_rerolls" yaml:"max_rerolls"`
+	GURPSFormat            bool             `json:"gurps_format,omitempty" yaml:"gurps_format,omitempty"`
+	ExtraDiceFromModifiers bool             `json:"extra_dice_from_modifiers,omitempty" yaml:"extra_dice_from_modifiers,omitempty"`
+	FastRollThreshold      int              `json:"fast_roll_threshold" yaml:"fast_roll_threshold"`
+	DigitDice              bool             `json:"digit_dice,omitempty" yaml:"digit_dice,omitempty"`
+}
+
+// Data returns the serializable form of this Config. A SeededRandomizer is captured at its current position within its
+// sequence, so a Config restored from the data continues the sequence where this one left off.
+func (c *Config) Data() (*ConfigData, error) {
+	if err := c.Valid(); err != nil {
+		return nil, err
+	}
+	data := &ConfigData{
+		Version:                CurrentConfigDataVersion,
+		MaxCount:               c.MaxCount,
+		MaxSides:               c.MaxSides,
+		MaxModifier:            c.MaxModifier,
+		MaxMultiplier:          c.MaxMultiplier,
+		MaxExplosions:          c.MaxExplosions,
+		MaxRerolls:             c.MaxRerolls,
+		GURPSFormat:            c.GURPSFormat,
+		ExtraDiceFromModifiers: c.ExtraDiceFromModifiers,
+		DigitDice:              c.DigitDice,
+		FastRollThreshold:      c.FastRollThreshold,
+	}
+	if c.CustomDice != nil {
+		data.CustomDice = make(map[string][]int, len(c.CustomDice))
+		for name, faces := range c.CustomDice {
+			data.CustomDice[name] = slices.Clone(faces)
+		}
+	}
+	switch rnd := c.Randomizer.(type) {
+	case *SeededRandomizer:
+		data.Randomizer = RandomizerData{Kind: SeededRandomizerKind, Seed: rnd.Seed(), State: rnd.state()}
+	default:
+		if c.Randomizer != xrand.New() {
+			return nil, errs.Newf("a Randomizer of type %T cannot be serialized", c.Randomizer)
+		}
+		data.Randomizer = RandomizerData{Kind: CryptoRandomizerKind}
+	}
+	return data, nil
+}
+
+// Config returns a new Config from this data. An error is returned if the data is from an unsupported version or the
+// resulting Config is not valid.
+func (d *ConfigData) Config() (*Config, error) {
+	if d.Version < 1 || d.Version > CurrentConfigDataVersion {
+		return nil, errs.Newf("unsupported dice config version %d", d.Version)
+	}
+	cfg := &Config{
+		MaxCount:               d.MaxCount,
+		MaxSides:               d.MaxSides,
+		MaxModifier:            d.MaxModifier,
+		MaxMultiplier:          d.MaxMultiplier,
+		MaxExplosions:          d.MaxExplosions,
+		MaxRerolls:             d.MaxRerolls,
+		GURPSFormat:            d.GURPSFormat,
+		ExtraDiceFromModifiers: d.ExtraDiceFromModifiers,
+		DigitDice:              d.DigitDice,
+		FastRollThreshold:      d.FastRollThreshold,
+	}
+	if d.CustomDice != nil {
+		cfg.CustomDice = make(map[string][]int, len(d.CustomDice))
+		for name, faces := range d.CustomDice {
+			cfg.CustomDice[name] = slices.Clone(faces)
+		}
+	}
+	switch d.Randomizer.Kind {
+	case CryptoRandomizerKind:
+		cfg.Randomizer = xrand.New()
+	case SeededRandomizerKind:
+		rnd := NewSeededRandomizer(d.Randomizer.Seed)
+		if d.Randomizer.State != "" {
+			if err := rnd.restoreState(d.Randomizer.State); err != nil {
+				return nil, err
+			}
+		}
+		cfg.Randomizer = rnd
+	default:
+		return nil, errs.Newf("unknown randomizer kind %q", d.Randomizer.Kind)
+	}
+	if err := cfg.Valid(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c *Config) MarshalJSON() ([]byte, error) {
+	data, err := c.Data()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *Config) UnmarshalJSON(text []byte) error {
+	var data ConfigData
+	if err := json.Unmarshal(text, &data); err != nil {
+		return err
+	}
+	return c.setData(&data)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (c *Config) MarshalYAML() (any, error) {
+	return c.Data()
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (c *Config) UnmarshalYAML(node *yaml.Node) error {
+	var data ConfigData
+	if err := node.Decode(&data); err != nil {
+		return err
+	}
+	return c.setData(&data)
+}
+
+func (c *Config) setData(data *ConfigData) error {
+	cfg, err := data.Config()
+	if err != nil {
+		return err
+	}
+	*c = *cfg
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The Roller is written as its Config.
+func (r *Roller) MarshalJSON() ([]byte, error) {
+	return r.config().MarshalJSON()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The Roller is read from its Config. Since a Roller never changes once
+// created, only a zero Roller that has not yet been shared, such as the one json.Unmarshal creates for a field of type
+// *Roller, may be read into; an error is returned for a Roller created by NewRoller.
+func (r *Roller) UnmarshalJSON(text []byte) error {
+	if err := r.checkUnmarshal(); err != nil {
+		return err
+	}
+	var cfg Config
+	if err := cfg.UnmarshalJSON(text); err != nil {
+		return err
+	}
+	r.cfg = &cfg
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler. The Roller is written as its Config.
+func (r *Roller) MarshalYAML() (any, error) {
+	return r.config().MarshalYAML()
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler. The Roller is read from its Config. As with UnmarshalJSON, only a zero
+// Roller that has not yet been shared may be read into.
+func (r *Roller) UnmarshalYAML(node *yaml.Node) error {
+	if err := r.checkUnmarshal(); err != nil {
+		return err
+	}
+	var cfg Config
+	if err := cfg.UnmarshalYAML(node); err != nil {
+		return err
+	}
+	r.cfg = &cfg
+	return nil
+}
+
+// checkUnmarshal returns an error if the Roller already has a Config, and so may be in use.
+func (r *Roller) checkUnmarshal() error {
+	if r.cfg != nil {
+		return errs.New("a Roller may not be changed once created")
+	}
+	return nil
+}
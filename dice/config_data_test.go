@@ -0,0 +1,154 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+	"github.com/richardwilkes/toolbox/v2/xrand"
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigSerialization(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.MaxCount = 50
+	cfg.MaxExplosions = 7
+	cfg.GURPSFormat = true
+	cfg.DigitDice = true
+	cfg.FastRollThreshold = 1000
+	cfg.CustomDice["Boost"] = []int{0, 0, 1, 2}
+	cfg.Randomizer = dice.NewSeededRandomizer(1234)
+
+	text, err := json.Marshal(cfg)
+	c.NoError(err)
+	var fromJSON dice.Config
+	c.NoError(json.Unmarshal(text, &fromJSON))
+	text, err = yaml.Marshal(cfg)
+	c.NoError(err)
+	var fromYAML dice.Config
+	c.NoError(yaml.Unmarshal(text, &fromYAML))
+
+	for _, restored := range []*dice.Config{&fromJSON, &fromYAML} {
+		c.Equal(50, restored.MaxCount)
+		c.Equal(cfg.MaxSides, restored.MaxSides)
+		c.Equal(7, restored.MaxExplosions)
+		c.Equal(cfg.MaxRerolls, restored.MaxRerolls)
+		c.True(restored.GURPSFormat)
+		c.False(restored.ExtraDiceFromModifiers)
+		c.True(restored.DigitDice)
+		c.Equal(1000, restored.FastRollThreshold)
+		c.Equal(cfg.CustomDice, restored.CustomDice)
+		rnd, ok := restored.Randomizer.(*dice.SeededRandomizer)
+		c.True(ok)
+		c.Equal(uint64(1234), rnd.Seed())
+	}
+
+	// Both restored randomizers continue from where the original was when it was serialized.
+	want := make([]int, 20)
+	for i := range want {
+		want[i] = cfg.Randomizer.Intn(100)
+	}
+	for i := range want {
+		c.Equal(want[i], fromJSON.Randomizer.Intn(100))
+		c.Equal(want[i], fromYAML.Randomizer.Intn(100))
+	}
+
+	// A serialized randomizer that has already drawn values resumes from that point, rather than from its seed.
+	text, err = json.Marshal(cfg)
+	c.NoError(err)
+	var resumed dice.Config
+	c.NoError(json.Unmarshal(text, &resumed))
+	c.Equal(cfg.Randomizer.Intn(1_000_000), resumed.Randomizer.Intn(1_000_000))
+}
+
+func TestConfigSerializationDefaults(t *testing.T) {
+	c := check.New(t)
+	text, err := json.Marshal(dice.DefaultConfig())
+	c.NoError(err)
+	c.Equal(`{"custom_dice":{"F":[-1,0,1]},"randomizer":{"kind":"crypto"},"version":1,"max_count":999999,`+
+		`"max_sides":999999,"max_modifier":999999,"max_multiplier":999999,"max_explosions":100,"max_rerolls":100,`+
+		`"fast_roll_threshold":10000}`,
+		string(text))
+	var cfg dice.Config
+	c.NoError(json.Unmarshal(text, &cfg))
+	c.True(cfg.Randomizer == xrand.New())
+	c.Equal(dice.DefaultConfig().CustomDice, cfg.CustomDice)
+
+	// A Randomizer other than the built-in kinds cannot be serialized.
+	other := dice.DefaultConfig()
+	other.Randomizer = dice.NewRecordingRandomizer(xrand.New())
+	_, err = json.Marshal(other)
+	c.HasError(err)
+	_, err = yaml.Marshal(other)
+	c.HasError(err)
+}
+
+func TestConfigDeserializationErrors(t *testing.T) {
+	c := check.New(t)
+	const valid = `"randomizer":{"kind":"seeded","seed":5},"max_count":10,"max_sides":100,"max_modifier":100,` +
+		`"max_multiplier":10,"max_explosions":10,"max_rerolls":10`
+	var cfg dice.Config
+	c.NoError(json.Unmarshal([]byte(`{"version":1,`+valid+`}`), &cfg))
+	c.Equal(0, len(cfg.CustomDice))
+	const limits = `"max_count":10,"max_sides":100,"max_multiplier":10`
+	for i, text := range []string{
+		`{` + valid + `}`,                                                          // 0 - missing version
+		`{"version":2,` + valid + `}`,                                              // 1 - a version from the future
+		`{"version":1,"randomizer":{"kind":"crypto"}}`,                             // 2 - limits that fail Valid
+		`{"version":1,` + valid + `,"custom_dice":{"f":[1]}}`,                      // 3 - a custom die that fails Valid
+		`{"version":1,"randomizer":{"kind":"quantum"},` + limits + `}`,             // 4 - an unknown randomizer
+		`{"version":1,"randomizer":{"kind":"seeded","state":"zz"},` + limits + `}`, // 5 - state that is not hex
+		`{"version":1,"randomizer":{"kind":"seeded","state":"00"},` + limits + `}`, // 6 - state that is not a PCG
+	} {
+		c.HasError(json.Unmarshal([]byte(text), &cfg), "case %d", i)
+	}
+	c.HasError(yaml.Unmarshal([]byte("version: 1\nrandomizer:\n  kind: crypto\nmax_count: 0\n"), &cfg))
+}
+
+func TestRollerSerialization(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.Randomizer = dice.NewSeededRandomizer(99)
+	cfg.ExtraDiceFromModifiers = true
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	r.Roll(r.Parse("10d6"))
+
+	type campaign struct {
+		Roller *dice.Roller `json:"roller"`
+	}
+	text, err := json.Marshal(&campaign{Roller: r})
+	c.NoError(err)
+	var fromJSON campaign
+	c.NoError(json.Unmarshal(text, &fromJSON))
+	text, err = yaml.Marshal(&campaign{Roller: r})
+	c.NoError(err)
+	var fromYAML campaign
+	c.NoError(yaml.Unmarshal(text, &fromYAML))
+
+	d := r.Parse("3d6+2x2")
+	want := r.Roll(d)
+	c.Equal(want, fromJSON.Roller.Roll(d))
+	c.Equal(want, fromYAML.Roller.Roll(d))
+	c.Equal("3d6", fromJSON.Roller.Format(r.Parse("1d6+7")))
+	c.Equal("3d6", fromYAML.Roller.Format(r.Parse("1d6+7")))
+
+	// A Roller that has been created never changes.
+	text, err = json.Marshal(dice.DefaultConfig())
+	c.NoError(err)
+	c.HasError(json.Unmarshal(text, fromJSON.Roller))
+	c.HasError(yaml.Unmarshal([]byte("version: 1\nrandomizer:\n  kind: crypto\n"), fromYAML.Roller))
+	c.Equal("3d6", fromJSON.Roller.Format(r.Parse("1d6+7")))
+	c.Equal("3d6", fromYAML.Roller.Format(r.Parse("1d6+7")))
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// TiePolicy determines how a Contest whose sides score the same is resolved.
+type TiePolicy uint8
+
+// Possible TiePolicy values.
+const (
+	// TieStands leaves a tie as a tie, as with a GURPS Quick Contest, where neither side wins.
+	TieStands TiePolicy = iota
+	// TieFavorsFirst awards a tie to the first side.
+	TieFavorsFirst
+	// TieFavorsSecond awards a tie to the second side, such as the defender of a D&D grapple check, where a tie leaves
+	// the situation unchanged.
+	TieFavorsSecond
+	// TieRerolls has both sides roll again until one of them wins. After MaxTieRerolls attempts to break the tie, it
+	// stands.
+	TieRerolls
+)
+
+// MaxTieRerolls is the most times both sides of a Contest roll again to break a tie under the TieRerolls policy. It
+// keeps a Contest whose sides can only tie, such as 1d1 against 1d1, from rolling forever.
+const MaxTieRerolls = 100
+
+// Side identifies a side of a Contest.
+type Side uint8
+
+// Possible Side values.
+const (
+	// NoSide is the winner of a Contest that ends in a tie.
+	NoSide Side = iota
+	FirstSide
+	SecondSide
+)
+
+// Contestant describes one side of a Contest.
+type Contestant struct {
+	Dice Dice
+	// Modifier is added to the contestant's score.
+	Modifier int
+	// Target, together with Comparison, determines how the contestant's roll is scored. For RollOver, the score is the
+	// amount the roll exceeds the Target by, so a Target of 0 scores the roll itself. For RollUnder, as with the skill
+	// rolls of a GURPS Quick Contest, the score is the amount the roll falls short of the Target by; that is, the
+	// contestant's margin of success.
+	Target     int
+	Comparison Comparison
+}
+
+// Contest describes two sides rolling against each other, the side with the higher score winning.
+type Contest struct {
+	First  Contestant
+	Second Contestant
+	Ties   TiePolicy
+}
+
+// ContestResult holds the outcome of a Contest.
+type ContestResult struct {
+	// First and Second hold the details of each side's roll. When a tie was rolled again, they hold the last roll.
+	First       *Result
+	Second      *Result
+	FirstScore  int
+	SecondScore int
+	// Margin is the amount the winner's score exceeds the loser's by. It is 0 for a tie, including one awarded to a side
+	// by the TiePolicy.
+	Margin int
+	// Rerolls is the number of times both sides rolled again to break a tie.
+	Rerolls int
+	Winner  Side
+}
+
+// ContestProbabilities holds the exact probability of each side winning a Contest, and of the Contest ending in a tie.
+type ContestProbabilities struct {
+	First  float64
+	Second float64
+	Tie    float64
+}
+
+// scoreChance holds a contestant's score and the chance of it occurring.
+type scoreChance struct {
+	score  int
+	chance float64
+}
+
+// score returns the contestant's score for a roll whose result was total. A score that would overflow an int is held
+// at maxFieldValue or -maxFieldValue instead.
+func (c *Contestant) score(total int) int {
+	if c.Comparison == RollUnder {
+		return saturatingAdd(saturatingSub(c.Target, total), c.Modifier)
+	}
+	return saturatingAdd(saturatingSub(total, c.Target), c.Modifier)
+}
+
+// Contest rolls both sides of the contest and determines the winner.
+func (r *Roller) Contest(contest Contest) *ContestResult {
+	res := &ContestResult{}
+	for {
+		res.First = r.RollDetailed(contest.First.Dice)
+		res.Second = r.RollDetailed(contest.Second.Dice)
+		res.FirstScore = contest.First.score(res.First.Total)
+		res.SecondScore = contest.Second.score(res.Second.Total)
+		if res.FirstScore != res.SecondScore || contest.Ties != TieRerolls || res.Rerolls >= MaxTieRerolls {
+			break
+		}
+		res.Rerolls++
+	}
+	switch {
+	case res.FirstScore > res.SecondScore:
+		res.Winner = FirstSide
+		res.Margin = saturatingSub(res.FirstScore, res.SecondScore)
+	case res.FirstScore < res.SecondScore:
+		res.Winner = SecondSide
+		res.Margin = saturatingSub(res.SecondScore, res.FirstScore)
+	case contest.Ties == TieFavorsFirst:
+		res.Winner = FirstSide
+	case contest.Ties == TieFavorsSecond:
+		res.Winner = SecondSide
+	}
+	return res
+}
+
+// ContestProbabilities returns the exact probability of each side winning the contest, and of it ending in a tie,
+// computed from the distributions of both sides' Dice. An error is returned if either distribution would be too large
+// to compute.
+func (r *Roller) ContestProbabilities(contest Contest) (*ContestProbabilities, error) {
+	first, err := r.contestantScores(&contest.First)
+	if err != nil {
+		return nil, err
+	}
+	var second []scoreChance
+	if second, err = r.contestantScores(&contest.Second); err != nil {
+		return nil, err
+	}
+	// below[i] holds the chance of the second side scoring less than second[i].score.
+	below := make([]float64, len(second)+1)
+	for i, one := range second {
+		below[i+1] = below[i] + one.chance
+	}
+	var probs ContestProbabilities
+	for _, one := range first {
+		i, found := slices.BinarySearchFunc(second, one.score, func(s scoreChance, score int) int {
+			return cmp.Compare(s.score, score)
+		})
+		probs.First += one.chance * below[i]
+		if found {
+			probs.Tie += one.chance * second[i].chance
+			i++
+		}
+		probs.Second += one.chance * max(below[len(second)]-below[i], 0)
+	}
+	switch contest.Ties {
+	case TieFavorsFirst:
+		probs.First += probs.Tie
+		probs.Tie = 0
+	case TieFavorsSecond:
+		probs.Second += probs.Tie
+		probs.Tie = 0
+	case TieRerolls:
+		if probs.Tie < 1 {
+			// A tie survives only if every one of the rolls to break it ties, while each side wins the first roll that
+			// does not tie with the same relative chance as it wins the original roll.
+			remaining := math.Pow(probs.Tie, float64(MaxTieRerolls+1))
+			scale := (1 - remaining) / (1 - probs.Tie)
+			probs.First *= scale
+			probs.Second *= scale
+			probs.Tie = remaining
+		}
+	}
+	return &probs, nil
+}
+
+// contestantScores returns each distinct score the contestant can achieve and its chance, in ascending order of score.
+// Results whose scores are held at the same limit share a single entry.
+func (r *Roller) contestantScores(c *Contestant) ([]scoreChance, error) {
+	dist, err := r.Distribution(c.Dice)
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]scoreChance, 0, len(dist.dice.probs))
+	for v, p := range dist.All() {
+		score := c.score(v)
+		if n := len(scores); n != 0 && scores[n-1].score == score {
+			scores[n-1].chance += p
+		} else {
+			scores = append(scores, scoreChance{score: score, chance: p})
+		}
+	}
+	if c.Comparison == RollUnder {
+		// The score falls as the result rises.
+		slices.Reverse(scores)
+	}
+	return scores, nil
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestContest(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Values      []int
+		Ties        dice.TiePolicy
+		FirstScore  int
+		SecondScore int
+		Margin      int
+		Rerolls     int
+		Winner      dice.Side
+	}{
+		{[]int{5, 1}, dice.TieStands, 6, 2, 4, 0, dice.FirstSide},               // 0
+		{[]int{1, 5}, dice.TieStands, 2, 6, 4, 0, dice.SecondSide},              // 1
+		{[]int{3, 3}, dice.TieStands, 4, 4, 0, 0, dice.NoSide},                  // 2
+		{[]int{3, 3}, dice.TieFavorsFirst, 4, 4, 0, 0, dice.FirstSide},          // 3
+		{[]int{3, 3}, dice.TieFavorsSecond, 4, 4, 0, 0, dice.SecondSide},        // 4
+		{[]int{3, 3, 2, 2, 0, 4}, dice.TieRerolls, 1, 5, 4, 2, dice.SecondSide}, // 5
+	} {
+		desc := fmt.Sprintf("Table index %d", i)
+		r := newRoller(c, &sequenceRandomizer{values: one.Values}, false, false)
+		res := r.Contest(dice.Contest{
+			First:  dice.Contestant{Dice: r.Parse("1d6")},
+			Second: dice.Contestant{Dice: r.Parse("1d6")},
+			Ties:   one.Ties,
+		})
+		c.Equal(one.FirstScore, res.FirstScore, desc)
+		c.Equal(one.SecondScore, res.SecondScore, desc)
+		c.Equal(one.FirstScore, res.First.Total, desc)
+		c.Equal(one.SecondScore, res.Second.Total, desc)
+		c.Equal(one.Margin, res.Margin, desc)
+		c.Equal(one.Rerolls, res.Rerolls, desc)
+		c.Equal(one.Winner, res.Winner, desc)
+	}
+
+	// A GURPS Quick Contest of skill 12 against skill 10, each scored by its margin of success.
+	r := newRoller(c, topFaceRandomizer{}, false, false)
+	res := r.Contest(dice.Contest{
+		First:  dice.Contestant{Dice: r.Parse("3d6"), Target: 12, Comparison: dice.RollUnder},
+		Second: dice.Contestant{Dice: r.Parse("3d6"), Target: 10, Modifier: 1, Comparison: dice.RollUnder},
+	})
+	c.Equal(-6, res.FirstScore)
+	c.Equal(-7, res.SecondScore)
+	c.Equal(1, res.Margin)
+	c.Equal(dice.FirstSide, res.Winner)
+
+	// A tie that cannot be broken stands once the rerolls run out.
+	res = r.Contest(dice.Contest{
+		First:  dice.Contestant{Dice: r.Parse("1d1")},
+		Second: dice.Contestant{Dice: r.Parse("1d1")},
+		Ties:   dice.TieRerolls,
+	})
+	c.Equal(dice.MaxTieRerolls, res.Rerolls)
+	c.Equal(dice.NoSide, res.Winner)
+
+	// The tie rerolls are not limited by the per-die reroll limit.
+	cfg := dice.DefaultConfig()
+	cfg.MaxRerolls = 0
+	cfg.Randomizer = topFaceRandomizer{}
+	limited, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	res = limited.Contest(dice.Contest{
+		First:  dice.Contestant{Dice: r.Parse("1d1")},
+		Second: dice.Contestant{Dice: r.Parse("1d1")},
+		Ties:   dice.TieRerolls,
+	})
+	c.Equal(dice.MaxTieRerolls, res.Rerolls)
+
+	// Scores and margins that would overflow an int are held near its limits rather than wrapping around.
+	res = r.Contest(dice.Contest{
+		First:  dice.Contestant{Dice: r.Parse("1d6"), Target: math.MinInt},
+		Second: dice.Contestant{Dice: r.Parse("1d6"), Target: math.MinInt + 1, Comparison: dice.RollUnder},
+	})
+	c.Equal(math.MaxInt-1, res.FirstScore)
+	c.Equal(1-math.MaxInt, res.SecondScore)
+	c.Equal(math.MaxInt-1, res.Margin)
+	c.Equal(dice.FirstSide, res.Winner)
+	res = r.Contest(dice.Contest{
+		First:  dice.Contestant{Dice: r.Parse("1d6"), Target: 7, Modifier: math.MinInt},
+		Second: dice.Contestant{Dice: r.Parse("1d6"), Target: -1, Modifier: math.MaxInt},
+	})
+	c.Equal(1-math.MaxInt, res.FirstScore)
+	c.Equal(math.MaxInt-1, res.SecondScore)
+	c.Equal(math.MaxInt-1, res.Margin)
+	c.Equal(dice.SecondSide, res.Winner)
+}
+
+func TestContestProbabilities(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		Contest dice.Contest
+		First   float64
+		Second  float64
+		Tie     float64
+	}{
+		{ // 0
+			dice.Contest{First: dice.Contestant{Dice: r.Parse("1d6")}, Second: dice.Contestant{Dice: r.Parse("1d6")}},
+			15.0 / 36, 15.0 / 36, 6.0 / 36,
+		},
+		{ // 1
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d6")},
+				Second: dice.Contestant{Dice: r.Parse("1d6")},
+				Ties:   dice.TieFavorsSecond,
+			},
+			15.0 / 36, 21.0 / 36, 0,
+		},
+		{ // 2
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d6")},
+				Second: dice.Contestant{Dice: r.Parse("1d6")},
+				Ties:   dice.TieFavorsFirst,
+			},
+			21.0 / 36, 15.0 / 36, 0,
+		},
+		{ // 3
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d6")},
+				Second: dice.Contestant{Dice: r.Parse("1d6")},
+				Ties:   dice.TieRerolls,
+			},
+			0.5, 0.5, 0,
+		},
+		{ // 4
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d6"), Modifier: 6},
+				Second: dice.Contestant{Dice: r.Parse("1d6")},
+			},
+			1, 0, 0,
+		},
+		{ // 5
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d4")},
+				Second: dice.Contestant{Dice: r.Parse("1d6"), Modifier: -1},
+			},
+			10.0 / 24, 10.0 / 24, 4.0 / 24,
+		},
+		{ // 6
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d6"), Target: 4, Comparison: dice.RollUnder},
+				Second: dice.Contestant{Dice: r.Parse("1d6"), Target: 3, Comparison: dice.RollUnder},
+			},
+			21.0 / 36, 10.0 / 36, 5.0 / 36,
+		},
+		{ // 7
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d1")},
+				Second: dice.Contestant{Dice: r.Parse("1d1")},
+				Ties:   dice.TieRerolls,
+			},
+			0, 0, 1,
+		},
+		{ // 8 - every result scores the same clamped amount, so the contest always ties
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d6"), Target: math.MinInt},
+				Second: dice.Contestant{Dice: r.Parse("1d6"), Target: math.MinInt},
+			},
+			0, 0, 1,
+		},
+		{ // 9
+			dice.Contest{
+				First:  dice.Contestant{Dice: r.Parse("1d6"), Target: math.MinInt},
+				Second: dice.Contestant{Dice: r.Parse("2d6"), Target: 20, Modifier: math.MaxInt, Comparison: dice.RollUnder},
+				Ties:   dice.TieFavorsSecond,
+			},
+			0, 1, 0,
+		},
+	} {
+		desc := fmt.Sprintf("Table index %d", i)
+		probs, err := r.ContestProbabilities(one.Contest)
+		c.NoError(err, desc)
+		c.True(near(one.First, probs.First), desc)
+		c.True(near(one.Second, probs.Second), desc)
+		c.True(near(one.Tie, probs.Tie), desc)
+	}
+
+	// A GURPS Quick Contest between equal skills favors neither side, and the exact result matches a direct count.
+	probs, err := r.ContestProbabilities(dice.Contest{
+		First:  dice.Contestant{Dice: r.Parse("3d6"), Target: 12, Comparison: dice.RollUnder},
+		Second: dice.Contestant{Dice: r.Parse("3d6"), Target: 12, Comparison: dice.RollUnder},
+	})
+	c.NoError(err)
+	c.True(near(probs.First, probs.Second))
+	c.True(near(1, probs.First+probs.Second+probs.Tie))
+	ways := make(map[int]int)
+	for a := 1; a <= 6; a++ {
+		for b := 1; b <= 6; b++ {
+			for d := 1; d <= 6; d++ {
+				ways[a+b+d]++
+			}
+		}
+	}
+	var tie float64
+	for _, n := range ways {
+		tie += float64(n*n) / (216 * 216)
+	}
+	c.True(near(tie, probs.Tie))
+}
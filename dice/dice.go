@@ -14,17 +14,113 @@ import (
 	"bytes"
 	"encoding/binary"
 	"hash"
+	"iter"
 	"strconv"
 	"strings"
 	"unicode"
 )
 
+// Selection determines which of the rolled dice contribute to the result.
+type Selection uint8
+
+// Possible Selection values.
+const (
+	SelectAll Selection = iota
+	KeepHighest
+	KeepLowest
+	DropHighest
+	DropLowest
+)
+
+// ExplodeMode determines whether a die that rolls at or above its explosion threshold is rolled again, and how the
+// additional rolls contribute to the result.
+type ExplodeMode uint8
+
+// Possible ExplodeMode values.
+const (
+	NoExplode ExplodeMode = iota
+	// Explode rolls another die for each die that explodes, adding it to the dice rolled. The added die may explode in
+	// turn, and is kept or dropped on its own.
+	Explode
+	// Compound rolls again for each die that explodes, adding the new roll into the die that exploded.
+	Compound
+	// Penetrate rolls again for each die that explodes, adding the new roll less 1 into the die that exploded.
+	Penetrate
+)
+
+// RerollMode determines whether a die that rolls at or below its reroll threshold is rolled again.
+type RerollMode uint8
+
+// Possible RerollMode values.
+const (
+	NoReroll RerollMode = iota
+	// RerollOnce rolls a die again, once, if it rolls at or below the threshold, keeping the second roll.
+	RerollOnce
+	// RerollUntil keeps rolling a die again for as long as it rolls at or below the threshold.
+	RerollUntil
+)
+
+// OpenEndedMode determines whether a die rolls again when its first roll lands near either end of its range, as with
+// the open-ended percentile rolls of Rolemaster.
+type OpenEndedMode uint8
+
+// Possible OpenEndedMode values.
+const (
+	NoOpenEnded OpenEndedMode = iota
+	// OpenEndedHigh rolls a die again when it lands high, adding the new roll, and keeps rolling again and adding for
+	// as long as the new roll also lands high.
+	OpenEndedHigh
+	// OpenEndedLow rolls a die again when its first roll lands low, subtracting the new roll, and keeps rolling again
+	// and subtracting for as long as the new roll lands high.
+	OpenEndedLow
+	// OpenEndedBoth combines OpenEndedHigh and OpenEndedLow.
+	OpenEndedBoth
+)
+
 // Dice holds the basic dice information.
 type Dice struct {
 	Count      int
 	Sides      int
 	Modifier   int
 	Multiplier int
+	// Selection determines which of the rolled dice contribute to the result, with SelectCount holding the number of
+	// dice the Selection keeps or drops. For example, 4d6kh3 keeps the highest 3 of 4 dice. SelectCount may only exceed
+	// Count for an Explode, as the dice its explosions add can then be kept or dropped too.
+	Selection   Selection
+	SelectCount int
+	// Explode determines whether dice that roll at or above ExplodeThreshold are rolled again. An ExplodeThreshold of 0
+	// explodes only on the highest face. For example, d10!>8 explodes on an 8, 9 or 10. The dice an Explode adds are
+	// sorted in with the others when some are kept or dropped, so 2d6!kl1 may keep the 3 an exploding 6 added, while
+	// 2d6!!kl1 keeps the lower of its two dice, treating a 6 and the 3 compounded into it as a 9.
+	Explode          ExplodeMode
+	ExplodeThreshold int
+	// Reroll determines whether faces at or below RerollThreshold are rolled again. Every roll of a die is subject to
+	// it, including those made when the die explodes. For example, 2d6ro<2 rolls each 1 or 2 again once, while
+	// d20r<3 rolls again until the result is above 3.
+	Reroll          RerollMode
+	RerollThreshold int
+	// OpenEnded determines whether a die whose first roll lands in the top or bottom 5% of its faces, rounded down but
+	// at least one face, is rolled again. For example, d100oe rolls again and adds on a 96 to 100, and rolls again and
+	// subtracts on a 1 to 5, while d100oeh and d100oel only do the former or the latter. A die may roll again no more
+	// than the Config's MaxExplosions times. Open-ended dice may keep or drop some of their dice, but may not explode,
+	// be rerolled or count successes, so those are removed.
+	OpenEnded OpenEndedMode
+	// SuccessThreshold, when not 0, makes the Dice count successes rather than total the faces rolled: each face at or
+	// above SuccessThreshold is one success, each face at or above DoubleThreshold (when not 0) is two, and each face
+	// at or below FailureThreshold (when not 0) takes a success away. Every roll of an exploding die counts, and a
+	// penetrating die's extra rolls are reduced by 1 before being compared. The Modifier and Multiplier then apply to
+	// the number of successes. For example, 8d10>=7dbl10f1! rolls eight ten-sided dice that explode on a 10, counting a
+	// success for each 7 or higher, two for each 10, and subtracting one for each 1. Dice that count successes keep all
+	// of their dice and do not compound, so a Selection is removed and a Compound explosion becomes an Explode.
+	SuccessThreshold int
+	DoubleThreshold  int
+	FailureThreshold int
+	// Faces, when not empty, gives the dice faces other than the numbers 1 through Sides: either the name of one of the
+	// Config's CustomDice, such as the F of 4dF, a list of faces written in place, such as the {0,0,1,1,2,3} of
+	// 2d{0,0,1,1,2,3}, or one of the 66, 666 or % read when the Config's DigitDice is enabled. Sides then holds the
+	// number of faces. Such dice may keep or drop some of their dice, but may not explode, be rerolled or count
+	// successes.
+	Faces string
 }
 
 func (dice Dice) normalize() Dice {
@@ -35,12 +131,169 @@ func (dice Dice) normalize() Dice {
 	if dice.Multiplier < 1 || (dice.Count == 0 && dice.Modifier == 0) {
 		dice.Multiplier = 1
 	}
+	if dice.Count == 0 {
+		dice.Faces = ""
+	} else if dice.Faces != "" {
+		dice.Explode = NoExplode
+		dice.Reroll = NoReroll
+		dice.SuccessThreshold = 0
+	}
+	if dice.OpenEnded > OpenEndedBoth || dice.Count == 0 || dice.Sides < 2 || dice.Faces != "" {
+		dice.OpenEnded = NoOpenEnded
+	} else if dice.OpenEnded != NoOpenEnded {
+		dice.Explode = NoExplode
+		dice.Reroll = NoReroll
+		dice.SuccessThreshold = 0
+	}
+	if dice.SuccessThreshold < 1 || dice.Count == 0 {
+		dice.SuccessThreshold = 0
+		dice.DoubleThreshold = 0
+		dice.FailureThreshold = 0
+	} else {
+		// No face can reach a threshold above Sides, so all such thresholds are equivalent.
+		dice.SuccessThreshold = min(dice.SuccessThreshold, dice.Sides+1)
+		switch {
+		case dice.DoubleThreshold < 1 || dice.DoubleThreshold > dice.Sides:
+			dice.DoubleThreshold = 0
+		case dice.DoubleThreshold < dice.SuccessThreshold:
+			dice.DoubleThreshold = dice.SuccessThreshold
+		}
+		dice.FailureThreshold = min(max(dice.FailureThreshold, 0), dice.SuccessThreshold-1)
+		dice.Selection = SelectAll
+		if dice.Explode == Compound {
+			dice.Explode = Explode
+		}
+	}
+	if dice.Reroll > RerollUntil || dice.Count == 0 || dice.RerollThreshold < 1 {
+		dice.Reroll = NoReroll
+	}
+	if dice.Reroll == NoReroll {
+		dice.RerollThreshold = 0
+	} else {
+		dice.RerollThreshold = min(dice.RerollThreshold, dice.Sides)
+	}
+	switch {
+	case dice.Explode > Penetrate || dice.Count == 0 || dice.ExplodeThreshold > dice.Sides:
+		dice.Explode = NoExplode
+		dice.ExplodeThreshold = 0
+	case dice.Explode == NoExplode || dice.ExplodeThreshold == dice.Sides:
+		dice.ExplodeThreshold = 0
+	case dice.ExplodeThreshold < 0:
+		dice.ExplodeThreshold = 1
+	}
+	dice.SelectCount = max(dice.SelectCount, 0)
+	if dice.Explode != Explode {
+		// Only an Explode can add dice, so otherwise no more dice can be kept or dropped than are rolled.
+		dice.SelectCount = min(dice.SelectCount, dice.Count)
+	}
+	switch dice.Selection {
+	case KeepHighest, KeepLowest:
+		if dice.SelectCount == dice.Count && dice.Explode != Explode {
+			dice.Selection = SelectAll
+		}
+	case DropHighest, DropLowest:
+		if dice.SelectCount == 0 {
+			dice.Selection = SelectAll
+		}
+	default:
+		dice.Selection = SelectAll
+	}
+	if dice.Selection == SelectAll {
+		dice.SelectCount = 0
+	}
 	return dice
 }
 
-// MarshalText implements the encoding.TextMarshaler interface.
+// explodesAt returns the lowest face that causes a die to explode.
+func (dice Dice) explodesAt() int {
+	if dice.ExplodeThreshold == 0 {
+		return dice.Sides
+	}
+	return dice.ExplodeThreshold
+}
+
+// keptRange returns the range of indexes, from (inclusive) to to (exclusive), of the dice that contribute to the result
+// once the rolled dice have been sorted into ascending order.
+func (dice Dice) keptRange() (from, to int) {
+	return dice.keptRangeOf(dice.Count)
+}
+
+// keptRangeOf returns the range of indexes, from (inclusive) to to (exclusive), of the dice that contribute to the
+// result once the given number of rolled dice, which may include dice added by explosions, have been sorted into
+// ascending order.
+func (dice Dice) keptRangeOf(rolled int) (from, to int) {
+	// An Explode may keep or drop more dice than Count, and so more than were rolled.
+	count := min(dice.SelectCount, rolled)
+	switch dice.Selection {
+	case KeepHighest:
+		return rolled - count, rolled
+	case KeepLowest:
+		return 0, count
+	case DropHighest:
+		return 0, rolled - count
+	case DropLowest:
+		return count, rolled
+	default:
+		return 0, rolled
+	}
+}
+
+// kept returns the number of dice that contribute to the result.
+func (dice Dice) kept() int {
+	from, to := dice.keptRange()
+	return to - from
+}
+
+// addsDice returns true if the Dice keeps or drops some of its dice and its explosions add dice, so the dice kept
+// depend on the dice each explosion adds.
+func (dice Dice) addsDice() bool {
+	return dice.Explode == Explode && dice.Selection != SelectAll
+}
+
+// isPlain returns true if the dice use none of the notation beyond count, sides, modifier and multiplier.
+func (dice Dice) isPlain() bool {
+	return dice.Selection == SelectAll && dice.Explode == NoExplode && dice.SuccessThreshold == 0 &&
+		dice.Reroll == NoReroll && dice.OpenEnded == NoOpenEnded && dice.Faces == ""
+}
+
+// openEndedThresholds returns the highest face that makes an open-ended die roll again low and the lowest face that
+// makes it roll again high: the bottom and top 5% of its faces, rounded down but at least one face.
+func (dice Dice) openEndedThresholds() (low, high int) {
+	width := max(dice.Sides/20, 1)
+	return width, dice.Sides - width + 1
+}
+
+// countsSuccesses returns true if the dice count successes rather than total the faces rolled.
+func (dice Dice) countsSuccesses() bool {
+	return dice.SuccessThreshold != 0
+}
+
+// score returns what a roll of the given value contributes to the result: the value itself, or when counting
+// successes, the number of successes it scores.
+func (dice Dice) score(value int) int {
+	if dice.SuccessThreshold == 0 {
+		return value
+	}
+	var successes int
+	if value >= dice.SuccessThreshold {
+		successes++
+		if dice.DoubleThreshold != 0 && value >= dice.DoubleThreshold {
+			successes++
+		}
+	} else if value <= dice.FailureThreshold {
+		successes--
+	}
+	return successes
+}
+
+// MarshalText implements the encoding.TextMarshaler interface. A d66 or d666 is written as d%66 or d%666, so that
+// UnmarshalText reads it back as a digit die rather than as a die with 66 or 666 sides.
 func (dice Dice) MarshalText() (text []byte, err error) {
-	return []byte(dice.normalize().format(DefaultConfig().GURPSFormat)), nil
+	dice = dice.normalize()
+	if dice.Faces == "66" || dice.Faces == "666" {
+		dice.Faces = "%" + dice.Faces
+	}
+	return []byte(dice.format(DefaultConfig().GURPSFormat)), nil
 }
 
 func (dice Dice) format(gurpsFormat bool) string {
@@ -50,9 +303,43 @@ func (dice Dice) format(gurpsFormat bool) string {
 			buffer.WriteString(strconv.Itoa(dice.Count))
 		}
 		buffer.WriteString("d")
-		if !gurpsFormat || dice.Sides != 6 {
+		if dice.Faces != "" {
+			buffer.WriteString(dice.Faces)
+		} else if !gurpsFormat || dice.Sides != 6 {
 			buffer.WriteString(strconv.Itoa(dice.Sides))
 		}
+		// Successes come before explosions, since a threshold following an explosion belongs to the explosion.
+		if dice.SuccessThreshold != 0 {
+			buffer.WriteString(">=")
+			buffer.WriteString(strconv.Itoa(dice.SuccessThreshold))
+			if dice.DoubleThreshold != 0 {
+				buffer.WriteString("dbl")
+				buffer.WriteString(strconv.Itoa(dice.DoubleThreshold))
+			}
+			if dice.FailureThreshold != 0 {
+				buffer.WriteByte('f')
+				buffer.WriteString(strconv.Itoa(dice.FailureThreshold))
+			}
+		}
+		if dice.Reroll != NoReroll {
+			buffer.WriteString(rerollNotation[dice.Reroll])
+			buffer.WriteByte('<')
+			buffer.WriteString(strconv.Itoa(dice.RerollThreshold))
+		}
+		if dice.Explode != NoExplode {
+			buffer.WriteString(explodeNotation[dice.Explode])
+			if dice.ExplodeThreshold != 0 {
+				buffer.WriteByte('>')
+				buffer.WriteString(strconv.Itoa(dice.ExplodeThreshold))
+			}
+		}
+		if dice.OpenEnded != NoOpenEnded {
+			buffer.WriteString(openEndedNotation[dice.OpenEnded])
+		}
+		if dice.Selection != SelectAll {
+			buffer.WriteString(selectionNotation[dice.Selection])
+			buffer.WriteString(strconv.Itoa(dice.SelectCount))
+		}
 	}
 	if dice.Modifier != 0 {
 		if dice.Modifier > 0 {
@@ -74,15 +361,22 @@ func (dice Dice) format(gurpsFormat bool) string {
 
 // UnmarshalText implements the encoding.TextUnmarshaler interface.
 func (dice *Dice) UnmarshalText(text []byte) error {
-	*dice = parseDice(string(text), maxFieldValue, maxFieldValue, maxFieldValue, maxFieldValue)
+	cfg := DefaultConfig()
+	cfg.MaxCount = maxFieldValue
+	cfg.MaxSides = maxFieldValue
+	cfg.MaxModifier = maxFieldValue
+	cfg.MaxMultiplier = maxFieldValue
+	// Every digit die MarshalText writes contains a '%', while no other Dice does.
+	cfg.DigitDice = bytes.IndexByte(text, '%') != -1
+	*dice = parseDice(string(text), cfg)
 	return nil
 }
 
-func parseDice(in string, maxCount, maxSides, maxModifier, maxMultiplier int) Dice {
+func parseDice(in string, cfg *Config) Dice {
 	in = strings.TrimSpace(in)
 	var dice Dice
 	var i int
-	dice.Count, i = extractValue(in, 0, maxCount)
+	dice.Count, i = extractValue(in, 0, cfg.MaxCount)
 	hadCount := i != 0
 	var ch byte
 	ch, i = nextChar(in, i)
@@ -91,8 +385,16 @@ func parseDice(in string, maxCount, maxSides, maxModifier, maxMultiplier int) Di
 	if isDieMarker(rune(ch)) {
 		hadD = true
 		j := i
-		dice.Sides, i = extractValue(in, i, maxSides)
+		if i = parseDigitDie(in, i, &dice, cfg); i == j {
+			dice.Sides, i = extractValue(in, i, cfg.MaxSides)
+			if i == j {
+				// Malformed faces end the specification, just as any other unrecognized text does.
+				i, _ = parseFaces(in, i, &dice, cfg, false)
+			}
+		}
 		hadSides = i != j
+		// A malformed modifier ends the specification, just as any other unrecognized text does.
+		i, _ = parseSuffixes(in, i, &dice, cfg, false)
 		ch, i = nextChar(in, i)
 	}
 	if hadSides && !hadCount {
@@ -102,7 +404,7 @@ func parseDice(in string, maxCount, maxSides, maxModifier, maxMultiplier int) Di
 	}
 	if isSign(rune(ch)) {
 		neg := ch == '-'
-		dice.Modifier, i = extractValue(in, i, maxModifier)
+		dice.Modifier, i = extractValue(in, i, cfg.MaxModifier)
 		if neg {
 			dice.Modifier = -dice.Modifier
 		}
@@ -113,11 +415,107 @@ func parseDice(in string, maxCount, maxSides, maxModifier, maxMultiplier int) Di
 		dice.Count = 0
 	}
 	if isMultiplier(rune(ch)) {
-		dice.Multiplier, _ = extractValue(in, i, maxMultiplier)
+		dice.Multiplier, _ = extractValue(in, i, cfg.MaxMultiplier)
 	}
 	return dice.normalize()
 }
 
+// parseDiceStrict parses a Dice specification just as parseDice does, but reports anything it does not recognize, and
+// any value that exceeds its limit or that normalization would change or discard, such as a success threshold above
+// the highest face, rather than ignoring or adjusting it. Whitespace is permitted only before and after the
+// specification.
+func parseDiceStrict(in string, cfg *Config) (Dice, *syntaxError) {
+	pos := len(in) - len(strings.TrimLeftFunc(in, unicode.IsSpace))
+	in = strings.TrimRightFunc(in, unicode.IsSpace)
+	if pos >= len(in) {
+		return Dice{}, &syntaxError{pos: pos, reason: "dice specification is empty"}
+	}
+	dice := Dice{Multiplier: 1}
+	start := pos
+	value, pos, err := parseLimitedValue(in, pos, max(cfg.MaxCount, cfg.MaxModifier), "number", true)
+	if err != nil {
+		return Dice{}, err
+	}
+	hadCount := pos != start
+	hadD := pos < len(in) && isDieMarker(rune(in[pos]))
+	if hadD {
+		if value > cfg.MaxCount {
+			return Dice{}, &syntaxError{pos: start, reason: "die count exceeds " + strconv.Itoa(cfg.MaxCount)}
+		}
+		dice.Count = value
+		pos++
+		sidesStart := pos
+		if pos = parseDigitDie(in, pos, &dice, cfg); pos == sidesStart {
+			if dice.Sides, pos, err = parseLimitedValue(in, pos, cfg.MaxSides, "number of sides", true); err != nil {
+				return Dice{}, err
+			}
+		}
+		if pos == sidesStart {
+			if pos, err = parseFaces(in, pos, &dice, cfg, true); err != nil {
+				return Dice{}, err
+			}
+		}
+		switch {
+		case pos == sidesStart && !hadCount:
+			return Dice{}, &syntaxError{pos: start, reason: "die marker needs a count or a number of sides"}
+		case pos == sidesStart:
+			dice.Sides = 6
+		case !hadCount:
+			dice.Count = 1
+		}
+		if dice.Sides < 1 {
+			// Normalization discards dice with no sides, leaving only the modifier.
+			return Dice{}, &syntaxError{pos: sidesStart, reason: "number of sides must be at least 1"}
+		}
+		if pos, err = parseSuffixes(in, pos, &dice, cfg, true); err != nil {
+			return Dice{}, err
+		}
+	} else {
+		if value > cfg.MaxModifier {
+			return Dice{}, &syntaxError{pos: start, reason: "modifier exceeds " + strconv.Itoa(cfg.MaxModifier)}
+		}
+		dice.Modifier = value
+	}
+	if pos < len(in) && isSign(rune(in[pos])) {
+		signPos := pos
+		pos++
+		var modifier int
+		if modifier, pos, err = parseLimitedValue(in, pos, cfg.MaxModifier, "modifier", true); err != nil {
+			return Dice{}, err
+		}
+		if pos == signPos+1 {
+			return Dice{}, &syntaxError{pos: pos, reason: "modifier is missing its value"}
+		}
+		if in[signPos] == '-' {
+			modifier = -modifier
+		}
+		if dice.Modifier += modifier; dice.Modifier > cfg.MaxModifier {
+			return Dice{}, &syntaxError{pos: start, reason: "modifier exceeds " + strconv.Itoa(cfg.MaxModifier)}
+		}
+	}
+	if pos < len(in) && isMultiplier(rune(in[pos])) {
+		pos++
+		multiplierPos := pos
+		if dice.Multiplier, pos, err = parseLimitedValue(in, pos, cfg.MaxMultiplier, "multiplier", true); err != nil {
+			return Dice{}, err
+		}
+		if pos == multiplierPos {
+			return Dice{}, &syntaxError{pos: pos, reason: "multiplier is missing its value"}
+		}
+		if dice.Multiplier < 1 {
+			return Dice{}, &syntaxError{pos: multiplierPos, reason: "multiplier may not be less than 1"}
+		}
+		if dice.Count == 0 && dice.Modifier == 0 && dice.Multiplier != 1 {
+			// Normalization discards a multiplier that has nothing to multiply.
+			return Dice{}, &syntaxError{pos: multiplierPos, reason: "multiplier has no dice or modifier to multiply"}
+		}
+	}
+	if pos < len(in) {
+		return Dice{}, &syntaxError{pos: pos, reason: "unexpected " + strconv.Quote(tokenAt(in, pos))}
+	}
+	return dice, nil
+}
+
 // Hash writes this object's contents into the hasher.
 //
 //nolint:errcheck // Ignore failure to check error return on binary.Write
@@ -129,12 +527,42 @@ func (dice *Dice) Hash(h hash.Hash) {
 	_ = binary.Write(h, binary.LittleEndian, int64(dice.Sides))
 	_ = binary.Write(h, binary.LittleEndian, int64(dice.Modifier))
 	_ = binary.Write(h, binary.LittleEndian, int64(dice.Multiplier))
+	// The fields added after the original four are only written when in use, so the hash of a plain Dice is unchanged.
+	if dice.Selection != SelectAll {
+		_ = binary.Write(h, binary.LittleEndian, uint8(dice.Selection))
+		_ = binary.Write(h, binary.LittleEndian, int64(dice.SelectCount))
+	}
+	if dice.Explode != NoExplode {
+		_ = binary.Write(h, binary.LittleEndian, uint8(dice.Explode))
+		_ = binary.Write(h, binary.LittleEndian, int64(dice.ExplodeThreshold))
+	}
+	if dice.Faces != "" {
+		_, _ = h.Write([]byte(dice.Faces))
+	}
+	if dice.Reroll != NoReroll {
+		_ = binary.Write(h, binary.LittleEndian, uint8(dice.Reroll))
+		_ = binary.Write(h, binary.LittleEndian, int64(dice.RerollThreshold))
+	}
+	if dice.SuccessThreshold != 0 {
+		_ = binary.Write(h, binary.LittleEndian, int64(dice.SuccessThreshold))
+		_ = binary.Write(h, binary.LittleEndian, int64(dice.DoubleThreshold))
+		_ = binary.Write(h, binary.LittleEndian, int64(dice.FailureThreshold))
+	}
+	if dice.OpenEnded != NoOpenEnded {
+		_ = binary.Write(h, binary.LittleEndian, uint8(dice.OpenEnded))
+	}
 }
 
 // ExtractDicePosition returns the start (inclusive) and end (exclusive) index of a Dice specification within the text.
 // If none can be found, -1, -1 will be returned. The span never contains an internal space and always begins with a
-// digit or a die marker, so parsing text[start:end] yields exactly the specification the span represents.
+// digit or a die marker, so parsing text[start:end] yields exactly the specification the span represents. The span
+// includes the whole of the notation, such as the kh3 of 4d6kh3 or the F of 4dF, with custom and digit dice recognized
+// as the default Config describes them.
 func ExtractDicePosition(text string) (start, end int) {
+	return extractDicePosition(text, DefaultConfig())
+}
+
+func extractDicePosition(text string, cfg *Config) (start, end int) {
 	start = -1
 	state := 0
 	foundDigit := false   // The current candidate contains at least one digit (a count or a number of sides).
@@ -143,8 +571,13 @@ func ExtractDicePosition(text string) (start, end int) {
 	dInWord := false      // The 'd' starting the current candidate is adjacent to a letter, so it is part of a word.
 	signHasDigit := false // A digit has followed the latest sign, so the sign has an operand and is not dangling.
 	maximum := len(text)
+	skip := 0 // Notation up to this index was consumed by the dice parsers and needs no further scanning.
 	var prev rune
 	for i, ch := range text {
+		if i < skip {
+			prev = ch
+			continue
+		}
 		if state == 5 {
 			// A bare number was found and we are skipping the spaces that follow it. It stays a valid result only if
 			// the text ends here; any other character means the number was not the final token, so discard it and
@@ -172,6 +605,14 @@ func ExtractDicePosition(text string) (start, end int) {
 				hasD = true
 				dInWord = isProseLetter(prev)
 				state = 1
+				// Custom and digit dice, such as the F of 4dF, take the place of the number of sides.
+				if !dInWord {
+					if end = dieNotationEnd(text, i+1, true, cfg); end > i+1 {
+						foundDigit = true
+						skip = end
+						state = 6
+					}
+				}
 			case isSign(ch):
 				signHasDigit = false
 				state = 2
@@ -204,7 +645,13 @@ func ExtractDicePosition(text string) (start, end int) {
 			case isMultiplier(ch):
 				state = 3
 			default:
-				state = 4
+				// Modifiers, such as the kh3 of 4d6kh3, may follow the number of sides.
+				if end = dieNotationEnd(text, i, false, cfg); end > i {
+					skip = end
+					state = 6
+				} else {
+					state = 4
+				}
 			}
 		case 2: // Found a sign; take its digit operand, then a multiplier if present, as New does.
 			switch {
@@ -222,6 +669,16 @@ func ExtractDicePosition(text string) (start, end int) {
 			if !isDigit(ch) {
 				state = 4
 			}
+		case 6: // Finished the die and its modifiers; allow a sign or 'x'.
+			switch {
+			case isSign(ch):
+				signHasDigit = false
+				state = 2
+			case isMultiplier(ch):
+				state = 3
+			default:
+				state = 4
+			}
 		}
 		if state == 4 {
 			maximum = i
@@ -252,6 +709,62 @@ func ExtractDicePosition(text string) (start, end int) {
 	return -1, -1
 }
 
+// DicePositions returns an iterator over the start (inclusive) and end (exclusive) index of every Dice specification
+// within the text, in the order they appear. Each is found by the same rules ExtractDicePosition uses, applied to the
+// text following the previous specification, so a bare number is only reported when it is the last thing in the text
+// and a 'd' within a word, as in "read 5", is not mistaken for a die marker. Letters and digits immediately following a
+// specification, such as the kh3 of 4d6kh3, belong to the same word, so they are skipped rather than searched, as are
+// the dangling operators ExtractDicePosition trims from the end of a specification.
+func DicePositions(text string) iter.Seq2[int, int] {
+	return dicePositions(text, DefaultConfig())
+}
+
+func dicePositions(text string, cfg *Config) iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		offset := 0
+		for offset < len(text) {
+			start, end := extractDicePosition(text[offset:], cfg)
+			if start == -1 {
+				return
+			}
+			if !yield(offset+start, offset+end) {
+				return
+			}
+			offset += end
+			for offset < len(text) && (isWordByte(text[offset]) || isSign(rune(text[offset]))) {
+				offset++
+			}
+		}
+	}
+}
+
+// dieNotationEnd returns the end of the notation starting at pos that the dice specification scanner does not track
+// itself: when body is true, the faces of a custom or digit die, such as the F of 4dF, which must be present, followed
+// by any modifiers, such as the kh3 of 4d6kh3. Notation that runs straight into a letter belongs to a word instead, so
+// pos is returned for it.
+func dieNotationEnd(text string, pos int, body bool, cfg *Config) int {
+	var dice Dice
+	end := pos
+	if body {
+		if end = parseDigitDie(text, pos, &dice, cfg); end == pos {
+			end, _ = parseFaces(text, pos, &dice, cfg, false)
+		}
+		if end == pos {
+			return pos
+		}
+	}
+	end, _ = parseSuffixes(text, end, &dice, cfg, false)
+	if end < len(text) && !isMultiplier(rune(text[end])) && unicode.IsLetter(rune(text[end])) {
+		return pos
+	}
+	return end
+}
+
+// isWordByte reports whether ch is an ASCII letter or digit.
+func isWordByte(ch byte) bool {
+	return isDigit(rune(ch)) || (lowerASCII(ch) >= 'a' && lowerASCII(ch) <= 'z')
+}
+
 // isDigit reports whether ch is an ASCII decimal digit.
 func isDigit(ch rune) bool { return ch >= '0' && ch <= '9' }
 
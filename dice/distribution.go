@@ -0,0 +1,357 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"iter"
+	"math"
+
+	"github.com/richardwilkes/toolbox/v2/errs"
+)
+
+// Distribution holds the exact probability distribution of the results of a Dice. It is computed by convolving the
+// distributions of the individual dice rather than by simulation, so its probabilities are exact to within the
+// precision of a float64. Explosion chains whose chance of occurring is smaller than that precision are not followed.
+type Distribution struct {
+	dice *pmf
+	// cdf[i] holds the chance of the dice totaling no more than dice.lo+i.
+	cdf        []float64
+	modifier   int
+	multiplier int
+}
+
+// Distribution returns the exact probability distribution of the results of rolling the Dice. An error is returned if
+// the distribution would be too large to compute.
+func (r *Roller) Distribution(dice Dice) (*Distribution, error) {
+	dice = r.prepare(dice)
+	var totals *pmf
+	switch {
+	case dice.Count == 0:
+		totals = &pmf{probs: []float64{1}}
+	case dice.addsDice():
+		if added := newAddedDice(dice, r.explosionLimit(dice), r.rerollLimit(dice)); added != nil {
+			totals = added.distribution()
+		}
+		if totals == nil {
+			return nil, errs.New("the distribution is too large to compute")
+		}
+	default:
+		die := r.dieDistribution(dice)
+		if die == nil {
+			return nil, errs.New("the distribution of a single die is too large to compute")
+		}
+		if dice.Selection == SelectAll {
+			totals = convolvePower(die, dice.Count)
+		} else {
+			totals = selectedDistribution(die, dice)
+		}
+		if totals == nil {
+			return nil, errs.New("the distribution is too large to compute")
+		}
+	}
+	return newDistribution(totals, dice.Modifier, dice.Multiplier), nil
+}
+
+func newDistribution(totals *pmf, modifier, multiplier int) *Distribution {
+	d := &Distribution{dice: totals, cdf: make([]float64, len(totals.probs)), modifier: modifier, multiplier: multiplier}
+	var sum float64
+	for i, p := range totals.probs {
+		sum += p
+		d.cdf[i] = min(sum, 1)
+	}
+	return d
+}
+
+// value returns the result produced when the dice total to lo+i.
+func (d *Distribution) value(i int) int {
+	return (d.dice.lo + i + d.modifier) * d.multiplier
+}
+
+// index returns the index of the largest dice total whose result does not exceed v. A v outside the range of results
+// gives -1 or the number of totals, so the arithmetic that recovers the total cannot overflow.
+func (d *Distribution) index(v int) int {
+	if v < d.value(0) {
+		return -1
+	}
+	if last := len(d.dice.probs) - 1; v > d.value(last) {
+		return last + 1
+	}
+	// Results are (total+modifier)*multiplier with a positive multiplier, so floor division recovers the total.
+	q := v / d.multiplier
+	if v%d.multiplier != 0 && v < 0 {
+		q--
+	}
+	return q - d.modifier - d.dice.lo
+}
+
+// Minimum returns the lowest result with a non-zero probability.
+func (d *Distribution) Minimum() int {
+	for i, p := range d.dice.probs {
+		if p > 0 {
+			return d.value(i)
+		}
+	}
+	return d.value(0)
+}
+
+// Maximum returns the highest result with a non-zero probability.
+func (d *Distribution) Maximum() int {
+	for i := len(d.dice.probs) - 1; i >= 0; i-- {
+		if d.dice.probs[i] > 0 {
+			return d.value(i)
+		}
+	}
+	return d.value(0)
+}
+
+// Probability returns the probability of a result of exactly v.
+func (d *Distribution) Probability(v int) float64 {
+	i := d.index(v)
+	if i < 0 || i >= len(d.dice.probs) || d.value(i) != v {
+		return 0
+	}
+	return d.dice.probs[i]
+}
+
+// AtMost returns the probability of a result of v or less. This is the cumulative distribution function.
+func (d *Distribution) AtMost(v int) float64 {
+	i := d.index(v)
+	switch {
+	case i < 0:
+		return 0
+	case i >= len(d.cdf)-1:
+		return 1 // Avoid rounding error in the accumulated sum at the top of the range
+	default:
+		return d.cdf[i]
+	}
+}
+
+// AtLeast returns the probability of a result of v or more.
+func (d *Distribution) AtLeast(v int) float64 {
+	if v <= d.value(0) {
+		return 1
+	}
+	return max(1-d.AtMost(v-1), 0)
+}
+
+// Mean returns the average result.
+func (d *Distribution) Mean() float64 {
+	var mean float64
+	for i, p := range d.dice.probs {
+		mean += p * float64(d.value(i))
+	}
+	return mean
+}
+
+// Variance returns the variance of the results.
+func (d *Distribution) Variance() float64 {
+	mean := d.Mean()
+	var variance float64
+	for i, p := range d.dice.probs {
+		delta := float64(d.value(i)) - mean
+		variance += p * delta * delta
+	}
+	return variance
+}
+
+// StandardDeviation returns the standard deviation of the results.
+func (d *Distribution) StandardDeviation() float64 {
+	return math.Sqrt(d.Variance())
+}
+
+// Median returns the lowest result that is at least as likely to be met or undercut as to be exceeded; that is, the
+// lowest v for which AtMost(v) is at least 0.5.
+func (d *Distribution) Median() int {
+	for i, c := range d.cdf {
+		if c >= 0.5 {
+			return d.value(i)
+		}
+	}
+	return d.Maximum()
+}
+
+// Mode returns the most likely result. If several results are equally likely, the lowest of them is returned.
+func (d *Distribution) Mode() int {
+	best := 0
+	for i, p := range d.dice.probs {
+		if p > d.dice.probs[best] {
+			best = i
+		}
+	}
+	return d.value(best)
+}
+
+// All returns an iterator over each possible result and its probability, in ascending order of result. Results that
+// cannot occur are skipped.
+func (d *Distribution) All() iter.Seq2[int, float64] {
+	return func(yield func(int, float64) bool) {
+		for i, p := range d.dice.probs {
+			if p > 0 && !yield(d.value(i), p) {
+				return
+			}
+		}
+	}
+}
+
+// convolve returns the distribution of the sum of independent values distributed as a and b, or nil if it would be too
+// large to compute.
+func convolve(a, b *pmf) *pmf {
+	if len(a.probs)+len(b.probs)-1 > maxSupport || len(a.probs)*len(b.probs) > maxWork {
+		return nil
+	}
+	result := newPMF(a.lo+b.lo, a.lo+b.lo+len(a.probs)+len(b.probs)-2)
+	for i, pa := range a.probs {
+		if pa == 0 {
+			continue
+		}
+		for j, pb := range b.probs {
+			result.probs[i+j] += pa * pb
+		}
+	}
+	return result
+}
+
+// convolvePower returns the distribution of the sum of n independent values distributed as d, or nil if it would be
+// too large to compute. It squares its way up to n, so only O(log n) convolutions are needed.
+func convolvePower(d *pmf, n int) *pmf {
+	if n < 1 {
+		return &pmf{probs: []float64{1}}
+	}
+	if len(d.probs) > 1 && n > (maxSupport-1)/(len(d.probs)-1) {
+		return nil
+	}
+	var result *pmf
+	for power := d; ; {
+		if n&1 == 1 {
+			if result == nil {
+				result = power
+			} else if result = convolve(result, power); result == nil {
+				return nil
+			}
+		}
+		if n >>= 1; n == 0 {
+			return result
+		}
+		if power = convolve(power, power); power == nil {
+			return nil
+		}
+	}
+}
+
+// selectedDistribution returns the distribution of the total of the dice the Selection keeps, where each die is
+// distributed as die, or nil if it would be too large to compute.
+//
+// The dice are assigned to the die's totals one total at a time, starting from the end whose dice are kept: the
+// highest total when keeping the highest dice, the lowest otherwise. Given that the j dice not yet assigned all land
+// on this total or beyond, the number landing exactly on it is binomially distributed, and the first dice assigned are
+// the ones kept. Once every kept die has been assigned, the remaining dice cannot affect the total.
+func selectedDistribution(die *pmf, dice Dice) *pmf {
+	from, to := dice.keptRange()
+	keep := to - from
+	highest := to == dice.Count
+	if keep == 0 {
+		return &pmf{probs: []float64{1}}
+	}
+	if !selectionFits(len(die.probs), keep) {
+		return nil
+	}
+	low, high := die.bounds()
+	base := keep * low // The lowest possible kept total
+	width := keep*(high-low) + 1
+	// states[k] holds the distribution of the kept total (less base) when k dice have been assigned so far; done
+	// accumulates the distribution once all kept dice have been assigned.
+	states := make([][]float64, keep)
+	states[0] = make([]float64, width)
+	states[0][0] = 1
+	done := make([]float64, width)
+	remaining := 1.0 // The chance of a die landing on the current total or beyond
+	for step := range die.probs {
+		i := step
+		if highest {
+			i = len(die.probs) - 1 - step
+		}
+		p := die.probs[i]
+		if p <= 0 {
+			continue
+		}
+		q := min(p/remaining, 1)
+		remaining -= p
+		offset := i // The kept total rises by this much (relative to base) for each die kept here
+		next := make([][]float64, keep)
+		for k, state := range states {
+			if state == nil {
+				continue
+			}
+			unassigned := dice.Count - k
+			// c dice land here; only the first keep-k of them are kept, and reaching keep finishes the assignment.
+			var cumulative float64
+			for c := 0; c < keep-k; c++ {
+				chance := binomialChance(unassigned, c, q)
+				cumulative += chance
+				if chance == 0 {
+					continue
+				}
+				target := next[k+c]
+				if target == nil {
+					target = make([]float64, width)
+					next[k+c] = target
+				}
+				shift := c * offset
+				for s, v := range state {
+					if v != 0 {
+						target[s+shift] += v * chance
+					}
+				}
+			}
+			if rest := 1 - cumulative; rest > 0 {
+				shift := (keep - k) * offset
+				for s, v := range state {
+					if v != 0 {
+						done[s+shift] += v * rest
+					}
+				}
+			}
+		}
+		states = next
+	}
+	result := newPMF(base, base+width-1)
+	copy(result.probs, done)
+	// Rounding can leave a sliver of probability in states that never finished; it is far below float64 precision.
+	return result
+}
+
+// selectionFits returns true if selectedDistribution can compute the distribution of keeping the given number of dice
+// that each have size distinct totals. The bound is evaluated in floating point, since the work it measures can be far
+// beyond the range of an int.
+func selectionFits(size, keep int) bool {
+	width := float64(keep)*float64(size-1) + 1
+	return width <= maxSupport && float64(size)*float64(keep)*float64(keep)*width <= maxWork
+}
+
+// binomialChance returns the probability of exactly i successes in n trials that each succeed with probability p.
+func binomialChance(n, i int, p float64) float64 {
+	switch {
+	case i < 0 || i > n:
+		return 0
+	case p <= 0:
+		if i == 0 {
+			return 1
+		}
+		return 0
+	case p >= 1:
+		if i == n {
+			return 1
+		}
+		return 0
+	default:
+		return binomialProbability(n, i, p)
+	}
+}
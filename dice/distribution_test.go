@@ -0,0 +1,213 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestDistribution3d6(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	dist, err := r.Distribution(r.Parse("3d6"))
+	c.NoError(err)
+	c.Equal(3, dist.Minimum())
+	c.Equal(18, dist.Maximum())
+	c.True(near(27.0/216, dist.Probability(10)))
+	c.True(near(1.0/216, dist.Probability(3)))
+	c.Equal(0.0, dist.Probability(2))
+	c.Equal(0.0, dist.Probability(19))
+	c.True(near(0.5, dist.AtLeast(11)))
+	c.True(near(0.5, dist.AtMost(10)))
+	c.Equal(1.0, dist.AtLeast(3))
+	c.Equal(1.0, dist.AtMost(18))
+	c.Equal(0.0, dist.AtMost(2))
+	c.Equal(0.0, dist.AtLeast(19))
+	c.True(near(10.5, dist.Mean()))
+	c.True(near(8.75, dist.Variance()))
+	c.True(near(math.Sqrt(8.75), dist.StandardDeviation()))
+	c.Equal(10, dist.Median())
+	c.Equal(10, dist.Mode())
+	var total float64
+	var count int
+	for _, p := range dist.All() {
+		total += p
+		count++
+	}
+	c.Equal(16, count)
+	c.True(near(1, total))
+}
+
+func TestDistributionModifierAndMultiplier(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	dist, err := r.Distribution(r.Parse("2d6+1x2"))
+	c.NoError(err)
+	c.Equal(6, dist.Minimum())
+	c.Equal(26, dist.Maximum())
+	c.Equal(0.0, dist.Probability(7))
+	c.True(near(6.0/36, dist.Probability(16)))
+	c.True(near(15.0/36, dist.AtMost(15)))
+	c.True(near(21.0/36, dist.AtLeast(15)))
+	c.True(near(16, dist.Mean()))
+	c.True(near(4*35.0/6, dist.Variance()))
+
+	dist, err = r.Distribution(r.Parse("d6-10x3"))
+	c.NoError(err)
+	c.Equal(-27, dist.Minimum())
+	c.Equal(-12, dist.Maximum())
+	c.True(near(5.0/6, dist.AtMost(-13)))
+	c.True(near(1.0/6, dist.AtLeast(-13)))
+	c.True(near(1.0/6, dist.Probability(-15)))
+
+	dist, err = r.Distribution(r.Parse("5"))
+	c.NoError(err)
+	c.Equal(1.0, dist.Probability(5))
+	c.Equal(5, dist.Median())
+	c.Equal(5, dist.Mode())
+	c.Equal(0.0, dist.Variance())
+}
+
+func TestDistributionExtremes(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, text := range []string{"3d6+2", "3d6-2", "2d6+1x3", "d6-10x3", "5"} {
+		desc := fmt.Sprintf("Table index %d: %s", i, text)
+		dist, err := r.Distribution(r.Parse(text))
+		c.NoError(err, desc)
+		c.Equal(1.0, dist.AtLeast(math.MinInt), desc)
+		c.Equal(0.0, dist.AtLeast(math.MaxInt), desc)
+		c.Equal(0.0, dist.AtMost(math.MinInt), desc)
+		c.Equal(1.0, dist.AtMost(math.MaxInt), desc)
+		c.Equal(0.0, dist.Probability(math.MinInt), desc)
+		c.Equal(0.0, dist.Probability(math.MaxInt), desc)
+		c.Equal(1.0, dist.AtLeast(dist.Minimum()), desc)
+		c.Equal(1.0, dist.AtMost(dist.Maximum()), desc)
+	}
+}
+
+// enumerate visits every combination of faces for count dice with the given number of sides.
+func enumerate(count, sides int, visit func(rolls []int)) {
+	rolls := make([]int, count)
+	for i := range rolls {
+		rolls[i] = 1
+	}
+	for {
+		visit(rolls)
+		i := 0
+		for i < count {
+			if rolls[i]++; rolls[i] <= sides {
+				break
+			}
+			rolls[i] = 1
+			i++
+		}
+		if i == count {
+			return
+		}
+	}
+}
+
+func TestDistributionSelection(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for _, text := range []string{"4d6kh3", "4d6kl3", "3d4dl1", "3d4dh2", "2d20kh1", "2d20kl1", "5d3kh2", "3d6kh0"} {
+		d := r.Parse(text)
+		dist, err := r.Distribution(d)
+		c.NoError(err, text)
+		want := make(map[int]float64)
+		var outcomes float64
+		enumerate(d.Count, d.Sides, func(rolls []int) {
+			sorted := slices.Clone(rolls)
+			slices.Sort(sorted)
+			var total int
+			switch d.Selection {
+			case dice.KeepHighest:
+				sorted = sorted[len(sorted)-d.SelectCount:]
+			case dice.KeepLowest:
+				sorted = sorted[:d.SelectCount]
+			case dice.DropHighest:
+				sorted = sorted[:len(sorted)-d.SelectCount]
+			case dice.DropLowest:
+				sorted = sorted[d.SelectCount:]
+			}
+			for _, v := range sorted {
+				total += v
+			}
+			want[total]++
+			outcomes++
+		})
+		for v := dist.Minimum() - 1; v <= dist.Maximum()+1; v++ {
+			c.True(near(want[v]/outcomes, dist.Probability(v)), "%s: P(%d) = %v, want %v", text, v,
+				dist.Probability(v), want[v]/outcomes)
+		}
+	}
+}
+
+func TestDistributionMatchesAverage(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, text := range []string{"d6!", "3d6!!", "2d6!p+3", "d10!>8", "4d6!kh3", "2d20kh1+5", "10d10", "d6!>1"} {
+		desc := fmt.Sprintf("Table index %d: %s", i, text)
+		d := r.Parse(text)
+		dist, err := r.Distribution(d)
+		c.NoError(err, desc)
+		c.Equal(r.Average(d), int(math.Floor(dist.Mean()+1e-9)), desc)
+		c.True(dist.Minimum() >= r.Minimum(d), desc)
+		c.True(dist.Maximum() <= r.Maximum(d), desc)
+	}
+}
+
+func TestDistributionMatchesSelectedAverage(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.CustomDice["Boost"] = []int{0, 0, 1, 2, 2, 5}
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	// The large factor magnifies any difference between the mean Average uses and the exact one.
+	for i, text := range []string{
+		"100000*4d6kh3",     // 0
+		"100000*4d6!kh3",    // 1
+		"100000*5d10!pdl2",  // 2
+		"100000*3d6r<2kl2",  // 3
+		"100000*6d20kh2",    // 4
+		"100000*4dFkh2",     // 5
+		"100000*5dBoostkh3", // 6
+		"100000*4d10!>9dh1", // 7
+		"100000*2d9999kh1",  // 8
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, text)
+		expr, err := r.ParseExpression(text)
+		c.NoError(err, desc)
+		dist, err := r.Distribution(r.Parse(strings.TrimPrefix(text, "100000*")))
+		c.NoError(err, desc)
+		c.Equal(expr.Average(), int(math.Floor(100000*dist.Mean()+1e-6)), desc)
+	}
+}
+
+func TestDistributionTooLarge(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	_, err := r.Distribution(r.Parse("999999d999999"))
+	c.HasError(err)
+	_, err = r.Distribution(r.Parse("999999d999999kh5"))
+	c.HasError(err)
+}
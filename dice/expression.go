@@ -0,0 +1,570 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Operator precedence levels used when parsing and formatting an Expression.
+const (
+	precedenceAdditive = iota + 1
+	precedenceMultiplicative
+	precedenceUnary
+	precedencePrimary
+)
+
+// Expression holds a tree of dice terms and constants combined with the +, -, * and / operators, with parentheses for
+// grouping, such as 2d6+1d4+3 or (1d8+2)*2-1d4. Division truncates toward zero. Create one with Roller.ParseExpression,
+// or by binding the variables of a Formula.
+type Expression struct {
+	roller *Roller
+	root   exprNode
+}
+
+// exprNode is a single node within an Expression's tree. The minimum and maximum of every node are computed once, at
+// parse time, using overflow-checked arithmetic; since every value a node can produce lies within those bounds, rolling
+// a successfully parsed Expression can never overflow an int. The bounds of a node that depends on a variable are not
+// known until the variable is bound, so its checks are deferred until then.
+type exprNode interface {
+	// roll returns a random result. If results is not nil, the details of each dice term rolled are appended to it.
+	roll(r *Roller, results *[]*Result) int
+	bounds() (minimum, maximum int)
+	average(r *Roller) float64
+	precedence() int
+	format(r *Roller, buffer *strings.Builder)
+}
+
+// ParseExpression parses a dice expression, such as 2d6+1d4+3 or (1d8+2)*2-1d4, into an Expression. Each dice term and
+// constant is limited by the Config's Max* values, and an expression that could overflow an int when rolled, or that
+// could divide by zero, is rejected. So is an expression that refers to a variable, which has no value to roll; use
+// ParseFormula for those.
+func (r *Roller) ParseExpression(spec string) (*Expression, error) {
+	p := exprParser{roller: r, cfg: r.config(), in: spec}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{roller: r, root: root}, nil
+}
+
+// Roll the expression.
+func (e *Expression) Roll() int {
+	return e.root.roll(e.roller, nil)
+}
+
+// RollDetailed rolls the expression, just as Roll does, but also returns the details of the roll of each of its dice
+// terms, in the order they appear in the expression.
+func (e *Expression) RollDetailed() (total int, results []*Result) {
+	total = e.root.roll(e.roller, &results)
+	return total, results
+}
+
+// Minimum returns the minimum result.
+func (e *Expression) Minimum() int {
+	minimum, _ := e.root.bounds()
+	return minimum
+}
+
+// Maximum returns the maximum result.
+func (e *Expression) Maximum() int {
+	_, maximum := e.root.bounds()
+	return maximum
+}
+
+// Average returns the average result, rounded down. Division within the expression is averaged by dividing the average
+// of its operands, so the result for an expression containing division is an approximation.
+func (e *Expression) Average() int {
+	return int(math.Floor(e.root.average(e.roller)))
+}
+
+// String returns the expression in its canonical form, with each dice term formatted by the Roller it was parsed with.
+func (e *Expression) String() string {
+	var buffer strings.Builder
+	e.root.format(e.roller, &buffer)
+	return buffer.String()
+}
+
+type diceNode struct {
+	dice     Dice
+	min, max int
+}
+
+func (n *diceNode) roll(r *Roller, results *[]*Result) int {
+	if results == nil {
+		return r.Roll(n.dice)
+	}
+	res := r.RollDetailed(n.dice)
+	*results = append(*results, res)
+	return res.Total
+}
+
+func (n *diceNode) bounds() (minimum, maximum int) {
+	return n.min, n.max
+}
+
+func (n *diceNode) average(r *Roller) float64 {
+	return r.mean(n.dice)
+}
+
+func (n *diceNode) precedence() int {
+	return precedencePrimary
+}
+
+func (n *diceNode) format(r *Roller, buffer *strings.Builder) {
+	buffer.WriteString(r.Format(n.dice))
+}
+
+type constantNode struct {
+	value int
+}
+
+func (n *constantNode) roll(_ *Roller, _ *[]*Result) int {
+	return n.value
+}
+
+func (n *constantNode) bounds() (minimum, maximum int) {
+	return n.value, n.value
+}
+
+func (n *constantNode) average(_ *Roller) float64 {
+	return float64(n.value)
+}
+
+func (n *constantNode) precedence() int {
+	return precedencePrimary
+}
+
+func (n *constantNode) format(_ *Roller, buffer *strings.Builder) {
+	buffer.WriteString(strconv.Itoa(n.value))
+}
+
+type negateNode struct {
+	operand  exprNode
+	min, max int
+	// unbound is true if the operand depends on a variable, leaving the bounds unknown.
+	unbound bool
+}
+
+// newNegateNode returns a node negating the operand, or a reason it cannot be created.
+func newNegateNode(operand exprNode) (node exprNode, reason string) {
+	if isUnbound(operand) {
+		return &negateNode{operand: operand, unbound: true}, ""
+	}
+	minimum, maximum := operand.bounds()
+	// Every bound is a real int, so only negating math.MinInt can overflow.
+	if minimum == math.MinInt {
+		return nil, "expression may overflow"
+	}
+	return &negateNode{operand: operand, min: -maximum, max: -minimum}, ""
+}
+
+func (n *negateNode) roll(r *Roller, results *[]*Result) int {
+	return -n.operand.roll(r, results)
+}
+
+func (n *negateNode) bounds() (minimum, maximum int) {
+	return n.min, n.max
+}
+
+func (n *negateNode) average(r *Roller) float64 {
+	return -n.operand.average(r)
+}
+
+func (n *negateNode) precedence() int {
+	return precedenceUnary
+}
+
+func (n *negateNode) format(r *Roller, buffer *strings.Builder) {
+	buffer.WriteByte('-')
+	formatOperand(r, buffer, n.operand, n.operand.precedence() < precedenceUnary)
+}
+
+type binaryNode struct {
+	left     exprNode
+	right    exprNode
+	min, max int
+	op       byte
+	// unbound is true if either operand depends on a variable, leaving the bounds unknown.
+	unbound bool
+}
+
+// newBinaryNode returns a node combining the operands with the operator, or a reason it cannot be created.
+func newBinaryNode(op byte, left, right exprNode) (node exprNode, reason string) {
+	if isUnbound(left) || isUnbound(right) {
+		return &binaryNode{left: left, right: right, op: op, unbound: true}, ""
+	}
+	leftMin, leftMax := left.bounds()
+	rightMin, rightMax := right.bounds()
+	var minimum, maximum int
+	var ok bool
+	switch op {
+	case '+':
+		minimum, ok = checkedAdd(leftMin, rightMin)
+		if ok {
+			maximum, ok = checkedAdd(leftMax, rightMax)
+		}
+	case '-':
+		minimum, ok = checkedSub(leftMin, rightMax)
+		if ok {
+			maximum, ok = checkedSub(leftMax, rightMin)
+		}
+	case '*':
+		minimum, maximum, ok = productBounds(leftMin, leftMax, rightMin, rightMax)
+	default:
+		if rightMin <= 0 && rightMax >= 0 {
+			return nil, "divisor may be zero"
+		}
+		minimum, maximum, ok = quotientBounds(leftMin, leftMax, rightMin, rightMax)
+	}
+	if !ok {
+		return nil, "expression may overflow"
+	}
+	return &binaryNode{left: left, right: right, op: op, min: minimum, max: maximum}, ""
+}
+
+func (n *binaryNode) roll(r *Roller, results *[]*Result) int {
+	left := n.left.roll(r, results)
+	right := n.right.roll(r, results)
+	switch n.op {
+	case '+':
+		return left + right
+	case '-':
+		return left - right
+	case '*':
+		return left * right
+	default:
+		return left / right
+	}
+}
+
+func (n *binaryNode) bounds() (minimum, maximum int) {
+	return n.min, n.max
+}
+
+func (n *binaryNode) average(r *Roller) float64 {
+	left := n.left.average(r)
+	right := n.right.average(r)
+	switch n.op {
+	case '+':
+		return left + right
+	case '-':
+		return left - right
+	case '*':
+		// The operands are rolled independently, so the average of their product is the product of their averages.
+		return left * right
+	default:
+		return left / right
+	}
+}
+
+func (n *binaryNode) precedence() int {
+	if n.op == '+' || n.op == '-' {
+		return precedenceAdditive
+	}
+	return precedenceMultiplicative
+}
+
+func (n *binaryNode) format(r *Roller, buffer *strings.Builder) {
+	prec := n.precedence()
+	formatOperand(r, buffer, n.left, n.left.precedence() < prec)
+	buffer.WriteByte(n.op)
+	// A right operand of the same precedence keeps its parentheses unless regrouping cannot change the result, which
+	// is only the case for addition and for multiplication by a product; e.g. 1d6-(1d4-1) and 1d6*(2d6/2) need them.
+	rightPrec := n.right.precedence()
+	if rightPrec == prec && n.op == '*' {
+		if right, ok := n.right.(*binaryNode); ok && right.op == '*' {
+			rightPrec++
+		}
+	}
+	formatOperand(r, buffer, n.right, rightPrec < prec || (rightPrec == prec && n.op != '+'))
+}
+
+func formatOperand(r *Roller, buffer *strings.Builder, node exprNode, parenthesize bool) {
+	if parenthesize {
+		buffer.WriteByte('(')
+	}
+	node.format(r, buffer)
+	if parenthesize {
+		buffer.WriteByte(')')
+	}
+}
+
+// exprParser is a recursive descent parser for the dice expression grammar:
+//
+//	expression := term (('+' | '-') term)*
+//	term       := unary (('*' | '/') unary)*
+//	unary      := ('+' | '-') unary | primary
+//	primary    := '(' expression ')' | dice | number | variable
+//	dice       := [number] ('d' | 'D') [number] modifier*
+//	variable   := '@' name
+//
+// Whitespace is permitted between, but not within, tokens.
+type exprParser struct {
+	roller *Roller
+	cfg    *Config
+	in     string
+	// variables holds the name of each distinct variable referenced so far.
+	variables []string
+	pos       int
+	// formula is true if variables are permitted, as they are in a Formula.
+	formula bool
+}
+
+func (p *exprParser) parse() (exprNode, error) {
+	node, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if ch := p.peek(); ch != 0 {
+		if ch == ')' {
+			return nil, p.errorf("unbalanced ')'")
+		}
+		return nil, p.errorf("unexpected %q", ch)
+	}
+	return node, nil
+}
+
+func (p *exprParser) parseExpression() (exprNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if !isSign(rune(op)) {
+			return left, nil
+		}
+		pos := p.pos
+		p.pos++
+		var right exprNode
+		if right, err = p.parseTerm(); err != nil {
+			return nil, err
+		}
+		if left, err = p.newBinary(pos, op, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parseTerm() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return left, nil
+		}
+		pos := p.pos
+		p.pos++
+		var right exprNode
+		if right, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		if left, err = p.newBinary(pos, op, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch p.peek() {
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	case '-':
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		node, reason := newNegateNode(operand)
+		if reason != "" {
+			return nil, p.errorf("%s", reason)
+		}
+		return node, nil
+	default:
+		return p.parsePrimary()
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	ch := p.peek()
+	switch {
+	case ch == '(':
+		p.pos++
+		node, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("missing ')'")
+		}
+		p.pos++
+		return node, nil
+	case isDigit(rune(ch)) || isDieMarker(rune(ch)):
+		return p.parseOperand()
+	case ch == '@':
+		return p.parseVariable()
+	case ch == 0:
+		return nil, p.errorf("unexpected end of expression")
+	default:
+		return nil, p.errorf("unexpected %q", ch)
+	}
+}
+
+func (p *exprParser) parseOperand() (exprNode, error) {
+	start := p.pos
+	count, err := p.parseNumber(max(p.cfg.MaxCount, p.cfg.MaxModifier, p.cfg.MaxMultiplier), "number")
+	if err != nil {
+		return nil, err
+	}
+	hadCount := p.pos != start
+	if p.pos >= len(p.in) || !isDieMarker(rune(p.in[p.pos])) {
+		return &constantNode{value: count}, nil
+	}
+	if count > p.cfg.MaxCount {
+		p.pos = start
+		return nil, p.errorf("die count exceeds %d", p.cfg.MaxCount)
+	}
+	p.pos++
+	sidesStart := p.pos
+	d := Dice{Count: count, Multiplier: 1}
+	if p.pos = parseDigitDie(p.in, p.pos, &d, p.cfg); p.pos == sidesStart {
+		if d.Sides, err = p.parseNumber(p.cfg.MaxSides, "number of sides"); err != nil {
+			return nil, err
+		}
+	}
+	var syntaxErr *syntaxError
+	if p.pos == sidesStart {
+		if p.pos, syntaxErr = parseFaces(p.in, p.pos, &d, p.cfg, true); syntaxErr != nil {
+			return nil, p.errorAtf(syntaxErr.pos, "%s", syntaxErr.reason)
+		}
+	}
+	switch {
+	case p.pos == sidesStart && !hadCount:
+		p.pos = start
+		return nil, p.errorf("die marker needs a count or a number of sides")
+	case p.pos == sidesStart:
+		d.Sides = 6
+	case !hadCount:
+		d.Count = 1
+	}
+	if d.Sides < 1 {
+		return nil, p.errorAtf(sidesStart, "number of sides must be at least 1")
+	}
+	if p.pos, syntaxErr = parseSuffixes(p.in, p.pos, &d, p.cfg, true); syntaxErr != nil {
+		return nil, p.errorAtf(syntaxErr.pos, "%s", syntaxErr.reason)
+	}
+	d = p.roller.Normalize(d)
+	return &diceNode{dice: d, min: p.roller.Minimum(d), max: p.roller.Maximum(d)}, nil
+}
+
+// parseNumber parses the run of digits at the current position, which may be empty, returning 0 in that case. A value
+// greater than maxValue is reported as an error.
+func (p *exprParser) parseNumber(maxValue int, what string) (int, error) {
+	value, end, syntaxErr := parseLimitedValue(p.in, p.pos, maxValue, what, true)
+	if syntaxErr != nil {
+		return 0, p.errorAtf(syntaxErr.pos, "%s", syntaxErr.reason)
+	}
+	p.pos = end
+	return value, nil
+}
+
+func (p *exprParser) newBinary(pos int, op byte, left, right exprNode) (exprNode, error) {
+	node, reason := newBinaryNode(op, left, right)
+	if reason != "" {
+		return nil, p.errorAtf(pos, "%s", reason)
+	}
+	return node, nil
+}
+
+// peek skips any whitespace and returns the character at the current position, or 0 at the end of the input.
+func (p *exprParser) peek() byte {
+	for p.pos < len(p.in) && (p.in[p.pos] == ' ' || p.in[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos < len(p.in) {
+		return p.in[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return p.errorAtf(p.pos, format, args...)
+}
+
+func (p *exprParser) errorAtf(pos int, format string, args ...any) error {
+	return newParseError(p.in, pos, fmt.Sprintf(format, args...))
+}
+
+// productBounds returns the range of a*b for a in [aMin, aMax] and b in [bMin, bMax], which is always found among the
+// products of the range end points. ok is false if any of those products overflows.
+func productBounds(aMin, aMax, bMin, bMax int) (minimum, maximum int, ok bool) {
+	minimum = math.MaxInt
+	maximum = math.MinInt
+	for _, a := range [2]int{aMin, aMax} {
+		for _, b := range [2]int{bMin, bMax} {
+			var v int
+			if v, ok = checkedMul(a, b); !ok {
+				return 0, 0, false
+			}
+			minimum = min(minimum, v)
+			maximum = max(maximum, v)
+		}
+	}
+	return minimum, maximum, true
+}
+
+// quotientBounds returns the range of a/b for a in [aMin, aMax] and b in [bMin, bMax], where the divisor range must not
+// contain zero. For a fixed divisor the quotient is monotonic in a, and for a fixed dividend its magnitude shrinks as the
+// divisor's magnitude grows, so the extremes lie at the end points of both ranges. ok is false only for math.MinInt/-1.
+func quotientBounds(aMin, aMax, bMin, bMax int) (minimum, maximum int, ok bool) {
+	minimum = math.MaxInt
+	maximum = math.MinInt
+	for _, a := range [2]int{aMin, aMax} {
+		for _, b := range [2]int{bMin, bMax} {
+			if a == math.MinInt && b == -1 {
+				return 0, 0, false
+			}
+			v := a / b
+			minimum = min(minimum, v)
+			maximum = max(maximum, v)
+		}
+	}
+	return minimum, maximum, true
+}
+
+func checkedAdd(a, b int) (int, bool) {
+	sum := a + b
+	return sum, (b >= 0) == (sum >= a)
+}
+
+func checkedSub(a, b int) (int, bool) {
+	diff := a - b
+	return diff, (b >= 0) == (diff <= a)
+}
+
+func checkedMul(a, b int) (int, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, false
+	}
+	return product, true
+}
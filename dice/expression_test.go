@@ -0,0 +1,157 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestExpressionParse(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		GURPS    bool
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"2d6+1d4+3", "2d6+d4+3", false, 6, 19, 12},              // 0
+		{"(1d8+2)*2-1d4", "(d8+2)*2-d4", false, 2, 19, 10},       // 1
+		{" 3d6 ", "3d6", false, 3, 18, 10},                       // 2
+		{"3d6", "3d", true, 3, 18, 10},                           // 3
+		{"2d", "2d6", false, 2, 12, 7},                           // 4
+		{"1d6-(1d4-1)", "d6-(d4-1)", false, -2, 6, 2},            // 5
+		{"1d6-1d4-1", "d6-d4-1", false, -4, 4, 0},                // 6
+		{"-(2d6)", "-2d6", false, -12, -2, -7},                   // 7
+		{"-(1d6+1)", "-(d6+1)", false, -7, -2, -5},               // 8
+		{"10/3", "10/3", false, 3, 3, 3},                         // 9
+		{"1d6*(2+1d2)", "d6*(2+d2)", false, 3, 24, 12},           // 10
+		{"(1d6-4)*(1d6-4)", "(d6-4)*(d6-4)", false, -6, 9, 0},    // 11
+		{"1d20 / 2", "d20/2", false, 0, 10, 5},                   // 12
+		{"(2d6)", "2d6", false, 2, 12, 7},                        // 13
+		{"-7/(1d2+1)", "-7/(d2+1)", false, -3, -2, -3},           // 14
+		{"2*3+4", "2*3+4", false, 10, 10, 10},                    // 15
+		{"2*(3+4)", "2*(3+4)", false, 14, 14, 14},                // 16
+		{"100d1", "100d1", false, 100, 100, 100},                 // 17
+		{"(1d4)/(-1d2)", "d4/-d2", false, -4, 0, -2},             // 18
+		{"d100 - 50 * 2", "d100-50*2", false, -99, 0, -50},       // 19
+		{"1d8+1d8+1d8+1d8", "d8+d8+d8+d8", false, 4, 32, 18},     // 20
+		{"((((1d4))))", "d4", false, 1, 4, 2},                    // 21
+		{"5-2-1", "5-2-1", false, 2, 2, 2},                       // 22
+		{"5-(2-1)", "5-(2-1)", false, 4, 4, 4},                   // 23
+		{"12/(6/2)", "12/(6/2)", false, 4, 4, 4},                 // 24
+		{"0", "0", false, 0, 0, 0},                               // 25
+		{"1D6+1d6", "d6+d6", false, 2, 12, 7},                    // 26
+		{"3d6*10", "3d6*10", false, 30, 180, 105},                // 27
+		{"1d2*1d2*1d2", "d2*d2*d2", false, 1, 8, 3},              // 28
+		{"-3", "-3", false, -3, -3, -3},                          // 29
+		{"+3", "3", false, 3, 3, 3},                              // 30
+		{"2d6 + 1d4 + 3", "2d6+d4+3", false, 6, 19, 12},          // 31
+		{"(1d8 + 2) * 2 - 1d4", "(d8+2)*2-d4", false, 2, 19, 10}, // 32
+		{"1d6-(-1d6)", "d6--d6", false, 2, 12, 7},                // 33
+		{"1d6+(2d6*2)", "d6+2d6*2", false, 5, 30, 17},            // 34
+		{"(1d6+2d6)*2", "(d6+2d6)*2", false, 6, 36, 21},          // 35
+		{"1d6*(2d6/2)", "d6*(2d6/2)", false, 1, 36, 12},          // 36
+		{"1d6*2d6/2", "d6*2d6/2", false, 1, 36, 12},              // 37
+		{"4d6-(1d6)", "4d6-d6", false, -2, 23, 10},               // 38
+		{"0d6+3", "0+3", false, 3, 3, 3},                         // 39 - no dice formats as the empty spec
+		{"  (  2d6  )  * 2 ", "2d6*2", false, 4, 24, 14},         // 40
+		{"4d6kh3+1d20", "4d6kh3+d20", false, 4, 38, 22},          // 41
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		r := newRoller(c, nil, one.GURPS, false)
+		e, err := r.ParseExpression(one.Text)
+		c.NoError(err, desc)
+		if err != nil {
+			continue
+		}
+		c.Equal(one.Expected, e.String(), desc)
+		c.Equal(one.Minimum, e.Minimum(), desc)
+		c.Equal(one.Maximum, e.Maximum(), desc)
+		c.Equal(one.Average, e.Average(), desc)
+		for range 50 {
+			v := e.Roll()
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+		again, err := r.ParseExpression(e.String())
+		c.NoError(err, desc)
+		c.Equal(e.String(), again.String(), desc)
+	}
+}
+
+func TestExpressionRollUsesEveryTerm(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, topFaceRandomizer{}, false, false)
+	e, err := r.ParseExpression("(2d6+1d4+3)*2-1d8")
+	c.NoError(err)
+	c.Equal((12+4+3)*2-8, e.Roll())
+}
+
+func TestExpressionErrors(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, text := range []string{
+		"",                            // 0 - empty
+		"2d6+",                        // 1 - dangling operator
+		"(2d6",                        // 2 - missing ')'
+		"2d6)",                        // 3 - unbalanced ')'
+		"3d6+x",                       // 4 - unknown token
+		"d",                           // 5 - lone die marker
+		"2d6 3",                       // 6 - missing operator
+		"1d6/0",                       // 7 - division by zero
+		"1d6/(1d3-2)",                 // 8 - divisor range includes zero
+		"1000000d6",                   // 9 - count exceeds MaxCount
+		"1d1000000",                   // 10 - sides exceed MaxSides
+		"1d6+1000000",                 // 11 - constant exceeds the limits
+		"2d6x2",                       // 12 - the Dice multiplier is not an expression operator
+		"*2",                          // 13 - missing left operand
+		"()",                          // 14 - empty parentheses
+		"2 d6",                        // 15 - a dice term may not contain whitespace
+		"999999*999999*999999*999999", // 16 - overflow
+		"999999d999999*999999*999999", // 17 - overflow
+		"4d6kh3kl1",                   // 18 - only one selection per term
+		"4d6kh5000000",                // 19 - selection count exceeds MaxCount
+		"2d6ro<2r<3",                  // 20 - only one reroll per term
+		"2d6r<",                       // 21 - a reroll comparison needs its value
+		"3d0+1",                       // 22 - dice need at least one side
+		"3d6kh5+1",                    // 23 - selection count exceeds the dice rolled
+		"2*3d6>=9",                    // 24 - success threshold above the highest face
+	} {
+		desc := fmt.Sprintf("Table index %d: %q", i, text)
+		e, err := r.ParseExpression(text)
+		c.HasError(err, desc)
+		c.True(e == nil, desc)
+	}
+}
+
+func TestExpressionRespectsConfigLimits(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.MaxCount = 4
+	cfg.MaxSides = 20
+	cfg.MaxModifier = 10
+	cfg.MaxMultiplier = 3
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	for _, text := range []string{"4d20+10", "4d20*3", "(1d20+4d20)*10"} {
+		_, err = r.ParseExpression(text)
+		c.NoError(err, text)
+	}
+	for _, text := range []string{"5d20", "4d21", "1d20+11"} {
+		_, err = r.ParseExpression(text)
+		c.HasError(err, text)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/richardwilkes/toolbox/v2/xrand"
+)
+
+// validCustomDiceName returns true if the name may be used for a custom die: an uppercase ASCII letter followed by any
+// number of ASCII letters.
+func validCustomDiceName(name string) bool {
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if ch := lowerASCII(name[i]); ch < 'a' || ch > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// digitDice holds the faces of the dice read when the Config's DigitDice is enabled, keyed by the Faces of a Dice that
+// holds one. Each digit of a d66 or d666 is read from a separate d6, while the tens and ones of a d% are read from two
+// d10, with 00 being 100.
+var digitDice = map[string][]int{
+	"%":   digitFaces(1, 100),
+	"66":  digitFaces(2, 6),
+	"666": digitFaces(3, 6),
+}
+
+// digitFaces returns the faces of a die read as the given number of digits, each from 1 to the given value. A single
+// digit is simply the numbers 1 through the value.
+func digitFaces(digits, value int) []int {
+	faces := []int{0}
+	for range digits {
+		next := make([]int, 0, len(faces)*value)
+		for _, face := range faces {
+			for digit := 1; digit <= value; digit++ {
+				next = append(next, face*10+digit)
+			}
+		}
+		faces = next
+	}
+	return faces
+}
+
+// maxDigitFace is the highest face of any of the digit dice, that of a d666.
+const maxDigitFace = 666
+
+// digitDieSpecs holds the text that may follow the die marker of a digit die, each mapped to the key of its faces in
+// digitDice. The forms that begin with '%' are those MarshalText writes, so that a d66 is not read back as a die with
+// 66 sides.
+var digitDieSpecs = []struct{ text, key string }{
+	{"%", "%"},
+	{"66", "66"},
+	{"666", "666"},
+	{"%66", "66"},
+	{"%666", "666"},
+}
+
+// parseDigitDie parses a d66, d666 or d%, written in place of the number of sides, when the Config's DigitDice is
+// enabled. If one is found, its faces and number of sides are set in dice. Otherwise, pos is returned unchanged.
+func parseDigitDie(in string, pos int, dice *Dice, cfg *Config) int {
+	if !cfg.DigitDice {
+		return pos
+	}
+	for _, spec := range digitDieSpecs {
+		end := pos + len(spec.text)
+		if strings.HasPrefix(in[pos:], spec.text) && (end == len(in) || !isDigit(rune(in[end]))) {
+			dice.Faces = spec.key
+			dice.Sides = len(digitDice[spec.key])
+			return end
+		}
+	}
+	return pos
+}
+
+// rollDigitDie rolls each of the dice read as the digits of the digit die described by spec, from the most significant,
+// and returns the value they read. Each d6 of a d66 or d666 reads 1 through 6, while each d10 of a d% reads 0 through
+// 9, with 00 read as 100. If roll is not nil, the face read from each die is recorded in its Digits.
+func rollDigitDie(spec string, rnd xrand.Randomizer, roll *DieRoll) int {
+	digits, sides, first := len(spec), 6, 1
+	if spec == "%" {
+		digits, sides, first = 2, 10, 0
+	}
+	var value int
+	for range digits {
+		digit := rollFace(sides, rnd) - 1 + first
+		if roll != nil {
+			roll.Digits = append(roll.Digits, digit)
+		}
+		value = value*10 + digit
+	}
+	if value == 0 {
+		value = 100
+	}
+	return value
+}
+
+// faces returns the value of each face of the custom die described by spec, which is either the name of one of the
+// Config's CustomDice, a list of faces written in place, such as {0,0,1,1,2,3}, or, when DigitDice is enabled, one of
+// the digit dice, such as 66. nil is returned if spec is empty or describes no valid die.
+func (c *Config) faces(spec string) []int {
+	if faces, ok := digitDice[spec]; ok {
+		if !c.DigitDice {
+			return nil
+		}
+		return faces
+	}
+	if strings.HasPrefix(spec, "{") {
+		faces, end, err := parseFaceList(spec, 0, c.MaxSides, true)
+		if err != nil || end != len(spec) {
+			return nil
+		}
+		return faces
+	}
+	return c.CustomDice[spec]
+}
+
+// parseFaces parses the faces of a custom die, written in place of its number of sides as either the name of one of
+// the Config's CustomDice or a list of faces. If a die is found, its faces and number of sides are set in dice. If no
+// custom die is present, pos is returned unchanged.
+func parseFaces(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	if pos >= len(in) {
+		return pos, nil
+	}
+	if in[pos] == '{' {
+		faces, end, err := parseFaceList(in, pos, cfg.MaxSides, strict)
+		if err != nil {
+			return pos, err
+		}
+		dice.Faces = formatFaceList(faces)
+		dice.Sides = len(faces)
+		return end, nil
+	}
+	// Prefer the longest name, so that one name may begin with another.
+	var name string
+	for one := range cfg.CustomDice {
+		if len(one) > len(name) && strings.HasPrefix(in[pos:], one) {
+			name = one
+		}
+	}
+	if name == "" {
+		return pos, nil
+	}
+	dice.Faces = name
+	dice.Sides = len(cfg.CustomDice[name])
+	return pos + len(name), nil
+}
+
+// parseFaceList parses a list of faces enclosed in braces and separated by commas, such as {-1,0,1}, starting at pos.
+// Each face is a whole number, optionally negative. A face beyond ±maxValue, or more than maxValue faces, is reported
+// as an error, as is a malformed list.
+func parseFaceList(in string, pos, maxValue int, strict bool) (faces []int, end int, err *syntaxError) {
+	start := pos
+	pos++ // Skip the opening brace
+	for {
+		neg := pos < len(in) && in[pos] == '-'
+		if neg {
+			pos++
+		}
+		var value, next int
+		if value, next, err = parseLimitedValue(in, pos, maxValue, "face", strict); err != nil {
+			return nil, start, err
+		}
+		if next == pos {
+			return nil, start, &syntaxError{pos: pos, reason: "face is missing its value"}
+		}
+		if neg {
+			value = -value
+		}
+		if len(faces) == maxValue {
+			return nil, start, &syntaxError{pos: start, reason: "custom die has more than " + strconv.Itoa(maxValue) +
+				" faces"}
+		}
+		faces = append(faces, value)
+		pos = next
+		if pos >= len(in) {
+			return nil, start, &syntaxError{pos: start, reason: "custom die is missing its closing '}'"}
+		}
+		switch in[pos] {
+		case ',':
+			pos++
+		case '}':
+			return faces, pos + 1, nil
+		default:
+			return nil, start, &syntaxError{pos: pos, reason: "custom die faces must be separated by ','"}
+		}
+	}
+}
+
+func formatFaceList(faces []int) string {
+	var buffer strings.Builder
+	buffer.WriteByte('{')
+	for i, face := range faces {
+		if i != 0 {
+			buffer.WriteByte(',')
+		}
+		buffer.WriteString(strconv.Itoa(face))
+	}
+	buffer.WriteByte('}')
+	return buffer.String()
+}
+
+// facesDistribution returns the probability mass function of a single roll of a die with the given faces, or nil if it
+// would be too large to build.
+func facesDistribution(faces []int) *pmf {
+	low, high := slices.Min(faces), slices.Max(faces)
+	if high-low >= maxSupport {
+		return nil
+	}
+	d := newPMF(low, high)
+	for _, face := range faces {
+		d.probs[face-low]++
+	}
+	for i := range d.probs {
+		d.probs[i] /= float64(len(faces))
+	}
+	return d
+}
+
+// facesMean returns the average roll of a die with the given faces.
+func facesMean(faces []int) float64 {
+	var total float64
+	for _, face := range faces {
+		total += float64(face)
+	}
+	return total / float64(len(faces))
+}
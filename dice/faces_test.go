@@ -0,0 +1,344 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestCustomDice(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		GURPS    bool
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"4dF", "4dF", false, -4, 4, 0},                                           // 0
+		{"dF", "dF", false, -1, 1, 0},                                             // 1
+		{"4dF+2", "4dF+2", false, -2, 6, 2},                                       // 2
+		{"2d{0,0,1,1,2,3}", "2d{0,0,1,1,2,3}", false, 0, 6, 2},                    // 3 - 2.33
+		{"2d{ 0,1}", "2d6", false, 2, 12, 7},                                      // 4 - malformed faces end the spec
+		{"3d{-1,-1,5}", "3d{-1,-1,5}", false, -3, 15, 3},                          // 5
+		{"4dF!", "4dF", false, -4, 4, 0},                                          // 6 - custom dice cannot explode
+		{"4dFkh2", "4dFkh2", false, -2, 2, 1},                                     // 7 - 1.09
+		{"4dF>=1", "4dF", false, -4, 4, 0},                                        // 8 - nor count successes
+		{"4dFr", "4dF", false, -4, 4, 0},                                          // 9 - nor be rerolled
+		{"4dF", "4dF", true, -4, 4, 0},                                            // 10
+		{"2d{1,2,3,4,5,6}", "2d{1,2,3,4,5,6}", true, 2, 12, 7},                    // 11 - listed faces are never shortened
+		{"2d{5}", "2d{5}", false, 10, 10, 10},                                     // 12
+		{"4dQ", "4d6", false, 4, 24, 14},                                          // 13 - an unknown name is not a die
+		{"4dFx2", "4dFx2", false, -8, 8, 0},                                       // 14
+		{"4df", "4d6", false, 4, 24, 14},                                          // 15 - names are case-sensitive
+		{"2d{-999999,999999}", "2d{-999999,999999}", false, -1999998, 1999998, 0}, // 16
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		r := newRoller(c, nil, one.GURPS, false)
+		d := r.Parse(one.Text)
+		c.Equal(one.Expected, r.Format(d), desc)
+		c.Equal(one.Minimum, r.Minimum(d), desc)
+		c.Equal(one.Maximum, r.Maximum(d), desc)
+		c.Equal(one.Average, r.Average(d), desc)
+		c.True(r.IsEquivalent(d, r.Parse(r.Format(d))), desc)
+		for range 100 {
+			v := r.Roll(d)
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+	}
+}
+
+func TestCustomDiceRegistration(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.CustomDice["B"] = []int{1}
+	cfg.CustomDice["Boost"] = []int{0, 0, 1, 1, 2, 3}
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	d := r.Parse("2dBoost+1")
+	c.Equal("2dBoost+1", r.Format(d))
+	c.Equal(6, d.Sides)
+	c.Equal(7, r.Maximum(d))
+	d = r.Parse("3dB")
+	c.Equal("3dB", r.Format(d))
+	c.Equal(3, r.Roll(d))
+
+	// The Roller holds its own copy of the Config, so later changes to it have no effect.
+	cfg.CustomDice["Boost"][5] = 100
+	c.Equal(7, r.Maximum(r.Parse("2dBoost+1")))
+	clone := cfg.Clone()
+	clone.CustomDice["Boost"][5] = 3
+	c.Equal(100, cfg.CustomDice["Boost"][5])
+
+	// A Roller without the name does not recognize it.
+	c.Equal("2d6", newRoller(c, nil, false, false).Format(dice.Dice{Count: 2, Sides: 6, Faces: "Boost"}))
+
+	for i, set := range []func(*dice.Config){
+		func(o *dice.Config) { o.CustomDice["boost"] = []int{1} },
+		func(o *dice.Config) { o.CustomDice["B1"] = []int{1} },
+		func(o *dice.Config) { o.CustomDice[""] = []int{1} },
+		func(o *dice.Config) { o.CustomDice["Empty"] = nil },
+		func(o *dice.Config) { o.CustomDice["Big"] = []int{o.MaxSides + 1} },
+		func(o *dice.Config) { o.CustomDice["Small"] = []int{-o.MaxSides - 1} },
+		func(o *dice.Config) {
+			o.MaxSides = 2
+			o.CustomDice["Many"] = []int{1, 1, 1}
+		},
+	} {
+		cfg = dice.DefaultConfig()
+		set(cfg)
+		c.HasError(cfg.Valid(), "case %d", i)
+	}
+	cfg = dice.DefaultConfig()
+	cfg.CustomDice = nil
+	c.NoError(cfg.Valid())
+	r, err = dice.NewRoller(cfg)
+	c.NoError(err)
+	c.Equal("4d6", r.Format(r.Parse("4dF")))
+	c.Equal("4d{-1,0,1}", r.Format(r.Parse("4d{-1,0,1}")))
+}
+
+func TestCustomDiceRoll(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Total    int
+	}{
+		{"4dF", "4dF → [-1, 0, 1, -1] = -1", -1},                                // 0
+		{"4dF+3", "4dF+3 → [-1, 0, 1, -1] + 3 = 2", 2},                          // 1
+		{"4dFkh2", "4dFkh2 → [~~-1~~, 0, 1, ~~-1~~] = 1", 1},                    // 2
+		{"2d{0,0,1,1,2,3}", "2d{0,0,1,1,2,3} → [0, 0] = 0", 0},                  // 3
+		{"3d{10,20,30}x2", "3d{10,20,30}x2 → [10, 20, 30] = 60 x 2 = 120", 120}, // 4
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		rnd := &sequenceRandomizer{values: []int{0, 1, 2, 3}}
+		r := newRoller(c, rnd, false, false)
+		d := r.Parse(one.Text)
+		res := r.RollDetailed(d)
+		c.Equal(one.Expected, res.String(), desc)
+		c.Equal(one.Total, res.Total, desc)
+		rnd.next = 0
+		c.Equal(res.Total, r.Roll(d), desc)
+	}
+	r := newRoller(c, &sequenceRandomizer{values: []int{0, 2}}, false, false)
+	res := r.RollDetailed(r.Parse("2dF"))
+	c.True(res.Rolls[0].Lowest && !res.Rolls[0].Highest)
+	c.True(res.Rolls[1].Highest && !res.Rolls[1].Lowest)
+}
+
+func TestCustomDiceStatistics(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	dist, err := r.Distribution(r.Parse("4dF"))
+	c.NoError(err)
+	c.True(near(19.0/81, dist.Probability(0)))
+	c.True(near(1.0/81, dist.Probability(4)))
+	c.True(near(1.0/81, dist.Probability(-4)))
+	c.True(near(4*2.0/3, dist.Variance()))
+	c.True(near(1-math.Pow(2.0/3, 4), r.PoolProbability(r.Parse("4dF"), 1)))
+
+	faces := []int{0, 0, 1, 1, 2, 3}
+	for _, text := range []string{"3d{0,0,1,1,2,3}kh2", "3d{0,0,1,1,2,3}dh1+1", "4d{0,0,1,1,2,3}"} {
+		d := r.Parse(text)
+		dist, err = r.Distribution(d)
+		c.NoError(err, text)
+		want := make(map[int]float64)
+		var outcomes float64
+		enumerate(d.Count, len(faces), func(rolls []int) {
+			values := make([]int, len(rolls))
+			for i, roll := range rolls {
+				values[i] = faces[roll-1]
+			}
+			slices.Sort(values)
+			switch d.Selection {
+			case dice.KeepHighest:
+				values = values[len(values)-d.SelectCount:]
+			case dice.DropHighest:
+				values = values[:len(values)-d.SelectCount]
+			default:
+			}
+			total := d.Modifier
+			for _, v := range values {
+				total += v
+			}
+			want[total]++
+			outcomes++
+		})
+		var mean float64
+		for v := dist.Minimum() - 1; v <= dist.Maximum()+1; v++ {
+			c.True(near(want[v]/outcomes, dist.Probability(v)), "%s: P(%d)", text, v)
+			mean += float64(v) * want[v] / outcomes
+		}
+		c.Equal(int(math.Floor(mean)), r.Average(d), text)
+	}
+}
+
+func TestCustomDiceStrictParse(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for _, text := range []string{"4dF", "4dF+2d{0,1}", "dF*2", "2d{-1,0,1}kh1"} {
+		_, err := r.ParseExpression(text)
+		c.NoError(err, text)
+	}
+	for _, text := range []string{
+		"4dF!", "4dFr", "4dF>=1", "2d{1,2", "2d{}", "2d{1,,2}", "2d{1000000}", "2d{1;2}", "4dQ",
+	} {
+		_, err := r.ParseExpression(text)
+		c.HasError(err, text)
+	}
+}
+
+func TestCustomDiceText(t *testing.T) {
+	c := check.New(t)
+	for _, text := range []string{"4dF+1", "2d{0,0,1,1,2,3}kh1"} {
+		var d dice.Dice
+		c.NoError(d.UnmarshalText([]byte(text)))
+		out, err := d.MarshalText()
+		c.NoError(err)
+		c.Equal(text, string(out))
+	}
+	hash := func(d dice.Dice) []byte {
+		h := sha256.New()
+		d.Hash(h)
+		return h.Sum(nil)
+	}
+	c.NotEqual(hash(dice.Dice{Count: 4, Sides: 3, Multiplier: 1}), hash(dice.Dice{Count: 4, Sides: 3, Multiplier: 1,
+		Faces: "F"}))
+}
+
+func TestDigitDice(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"d66", "d66", 11, 66, 38},         // 0 - 38.5
+		{"2d66", "2d66", 22, 132, 77},      // 1
+		{"d666", "d666", 111, 666, 388},    // 2 - 388.5
+		{"d%", "d%", 1, 100, 50},           // 3
+		{"D%+5x2", "d%+5x2", 12, 210, 110}, // 4
+		{"3d%kl1", "3d%kl1", 1, 100, 25},   // 5 - 25.5025
+		{"d66!", "d66", 11, 66, 38},        // 6 - digit dice cannot explode
+		{"d66oe", "d66", 11, 66, 38},       // 7 - nor be open-ended
+		{"d6", "d6", 1, 6, 3},              // 8
+		{"d67", "d67", 1, 67, 34},          // 9
+		{"d6666", "d6666", 1, 6666, 3333},  // 10 - only two or three digits are read separately
+		{"3d", "3d6", 3, 18, 10},           // 11
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		cfg := dice.DefaultConfig()
+		cfg.DigitDice = true
+		r, err := dice.NewRoller(cfg)
+		c.NoError(err, desc)
+		d := r.Parse(one.Text)
+		c.Equal(one.Expected, r.Format(d), desc)
+		c.Equal(one.Minimum, r.Minimum(d), desc)
+		c.Equal(one.Maximum, r.Maximum(d), desc)
+		c.Equal(one.Average, r.Average(d), desc)
+		c.True(r.IsEquivalent(d, r.Parse(r.Format(d))), desc)
+		strict, err := r.ParseStrict(one.Expected)
+		c.NoError(err, desc)
+		c.Equal(d, strict, desc)
+		for range 100 {
+			v := r.Roll(d)
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+	}
+
+	// Without DigitDice, the existing behavior is kept, even for a Dice that holds a digit die.
+	r := newRoller(c, nil, false, false)
+	d := r.Parse("d66")
+	c.Equal(66, d.Sides)
+	c.Equal(1, r.Minimum(d))
+	c.Equal("0", r.Format(r.Parse("d%")))
+	c.Equal("0", r.Format(r.Parse("d%66")))
+	digit := dice.Dice{Count: 1, Sides: 36, Multiplier: 1, Faces: "66"}
+	c.Equal("d36", r.Format(digit))
+	c.Equal(36, r.Maximum(digit))
+
+	// A digit die survives a round trip through its text form, without being mistaken for a die with as many sides.
+	for i, one := range []struct {
+		Text string
+		Dice dice.Dice
+	}{
+		{"2d%66+1", dice.Dice{Count: 2, Sides: 36, Modifier: 1, Multiplier: 1, Faces: "66"}}, // 0
+		{"d%666", dice.Dice{Count: 1, Sides: 216, Multiplier: 1, Faces: "666"}},              // 1
+		{"3d%kl1x2", dice.Dice{
+			Count: 3, Sides: 100, Multiplier: 2, Faces: "%", Selection: dice.KeepLowest, SelectCount: 1,
+		}}, // 2
+		{"d66", dice.Dice{Count: 1, Sides: 66, Multiplier: 1}}, // 3
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		text, err := one.Dice.MarshalText()
+		c.NoError(err, desc)
+		c.Equal(one.Text, string(text), desc)
+		var back dice.Dice
+		c.NoError(back.UnmarshalText(text), desc)
+		c.Equal(one.Dice, back, desc)
+	}
+
+	// Every digit die must fit within MaxSides.
+	cfg := dice.DefaultConfig()
+	cfg.DigitDice = true
+	cfg.MaxSides = 665
+	c.HasError(cfg.Valid())
+	cfg.MaxSides = 666
+	c.NoError(cfg.Valid())
+}
+
+func TestDigitDiceRoll(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.DigitDice = true
+	// Each digit is read from a separate die, so each d6 of a d66 gives one digit, from 1 to 6, and each d10 of a d%
+	// gives one digit, from 0 to 9.
+	cfg.Randomizer = &sequenceRandomizer{values: []int{0, 0, 1, 2, 5, 5, 0, 0, 0, 0, 1, 2, 3, 5, 0, 9, 0, 0}}
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	c.Equal("3d66 → [11(1/1), 23(2/3), 66(6/6)] = 100", r.RollDetailed(r.Parse("3d66")).String())
+	c.Equal("2d666 → [111(1/1/1), 123(1/2/3)] = 234", r.RollDetailed(r.Parse("2d666")).String())
+	res := r.RollDetailed(r.Parse("d%"))
+	c.Equal("d% → [35(3/5)] = 35", res.String())
+	c.Equal([]int{3, 5}, res.Rolls[0].Digits)
+	c.Equal("d% → [9(0/9)] = 9", r.RollDetailed(r.Parse("d%")).String())
+	c.Equal("d% → [100(0/0)] = 100", r.RollDetailed(r.Parse("d%")).String())
+
+	dist, err := r.Distribution(r.Parse("d66"))
+	c.NoError(err)
+	c.True(near(1.0/36, dist.Probability(23)))
+	c.True(near(0, dist.Probability(17)))
+	c.True(near(0, dist.Probability(30)))
+	c.True(near(38.5, dist.Mean()))
+
+	for _, text := range []string{"d66+d%", "2d666kh1", "d%*2"} {
+		_, err = r.ParseExpression(text)
+		c.NoError(err, text)
+	}
+	for _, text := range []string{"d66!", "d66r", "d%>=50", "d%oe", "d666oe"} {
+		_, err = r.ParseExpression(text)
+		c.HasError(err, text)
+		_, err = r.ParseStrict(text)
+		c.HasError(err, text)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"slices"
+)
+
+// gurpsTableLimit is the highest ST listed in the GURPS damage table. Each full 10 ST beyond it adds another die to
+// both thrust and swing.
+const gurpsTableLimit = 100
+
+// gurpsDamage holds one row of the GURPS damage table: the thrust and swing damage, in six-sided dice and adds, for a
+// given ST.
+type gurpsDamage struct {
+	st             int
+	thrustCount    int
+	thrustModifier int
+	swingCount     int
+	swingModifier  int
+}
+
+// gurpsDamageTable holds the GURPS Basic Set damage table, which lists every ST from 1 to 40 and every fifth ST from
+// there to 100.
+var gurpsDamageTable = []gurpsDamage{
+	{1, 1, -6, 1, -5},
+	{2, 1, -6, 1, -5},
+	{3, 1, -5, 1, -4},
+	{4, 1, -5, 1, -4},
+	{5, 1, -4, 1, -3},
+	{6, 1, -4, 1, -3},
+	{7, 1, -3, 1, -2},
+	{8, 1, -3, 1, -2},
+	{9, 1, -2, 1, -1},
+	{10, 1, -2, 1, 0},
+	{11, 1, -1, 1, 1},
+	{12, 1, -1, 1, 2},
+	{13, 1, 0, 2, -1},
+	{14, 1, 0, 2, 0},
+	{15, 1, 1, 2, 1},
+	{16, 1, 1, 2, 2},
+	{17, 1, 2, 3, -1},
+	{18, 1, 2, 3, 0},
+	{19, 2, -1, 3, 1},
+	{20, 2, -1, 3, 2},
+	{21, 2, 0, 4, -1},
+	{22, 2, 0, 4, 0},
+	{23, 2, 1, 4, 1},
+	{24, 2, 1, 4, 2},
+	{25, 2, 2, 5, -1},
+	{26, 2, 2, 5, 0},
+	{27, 3, -1, 5, 1},
+	{28, 3, -1, 5, 1},
+	{29, 3, 0, 5, 2},
+	{30, 3, 0, 5, 2},
+	{31, 3, 1, 6, -1},
+	{32, 3, 1, 6, -1},
+	{33, 3, 2, 6, 0},
+	{34, 3, 2, 6, 0},
+	{35, 4, -1, 6, 1},
+	{36, 4, -1, 6, 1},
+	{37, 4, 0, 6, 2},
+	{38, 4, 0, 6, 2},
+	{39, 4, 1, 7, -1},
+	{40, 4, 1, 7, -1},
+	{45, 5, 0, 7, 1},
+	{50, 5, 2, 8, -1},
+	{55, 6, 0, 8, 1},
+	{60, 7, -1, 9, 0},
+	{65, 7, 1, 9, 2},
+	{70, 8, 0, 10, 0},
+	{75, 8, 2, 10, 2},
+	{80, 9, 0, 11, 0},
+	{85, 9, 2, 11, 2},
+	{90, 10, 0, 12, 0},
+	{95, 10, 2, 12, 2},
+	{100, 11, 0, 13, 0},
+}
+
+// GURPSThrust returns the thrust damage of the given ST, as listed in the damage table of the GURPS Basic Set. A ST
+// between two of the table's rows above 40 uses the lower of them, and each full 10 ST above 100 adds another die, so
+// ST 44 gives 4d+1 and ST 125 gives 13d. A ST less than 1 does no damage, so the result has no dice.
+func GURPSThrust(st int) Dice {
+	row, extra := gurpsDamageRow(st)
+	if row == nil {
+		return Dice{Multiplier: 1}
+	}
+	return Dice{Count: row.thrustCount + extra, Sides: 6, Modifier: row.thrustModifier, Multiplier: 1}
+}
+
+// GURPSSwing returns the swing damage of the given ST, as listed in the damage table of the GURPS Basic Set. A ST
+// between two of the table's rows above 40 uses the lower of them, and each full 10 ST above 100 adds another die, so
+// ST 44 gives 7d-1 and ST 125 gives 15d. A ST less than 1 does no damage, so the result has no dice.
+func GURPSSwing(st int) Dice {
+	row, extra := gurpsDamageRow(st)
+	if row == nil {
+		return Dice{Multiplier: 1}
+	}
+	return Dice{Count: row.swingCount + extra, Sides: 6, Modifier: row.swingModifier, Multiplier: 1}
+}
+
+// gurpsDamageRow returns the row of the damage table to use for the ST, along with the number of dice to add to it for
+// the ST beyond the end of the table. The row is nil if the ST is less than 1.
+func gurpsDamageRow(st int) (row *gurpsDamage, extraDice int) {
+	if st < 1 {
+		return nil, 0
+	}
+	if st > gurpsTableLimit {
+		extraDice = (st - gurpsTableLimit) / 10
+		st = gurpsTableLimit
+	}
+	i, found := slices.BinarySearchFunc(gurpsDamageTable, st, func(row gurpsDamage, st int) int { return row.st - st })
+	if !found {
+		i--
+	}
+	return &gurpsDamageTable[i], extraDice
+}
+
+// ApplyModifiersFromDice returns the Dice with up to count of its dice converted into an equal modifier, the reverse of
+// ApplyExtraDiceFromModifiers. Each die is worth its average result, and a die with an even number of sides, whose
+// average falls halfway between two whole numbers, is worth the higher of them when the dice converted leave a half
+// over, so each d6 is worth +3.5: one converts to +4, two to +7 and three to +11, as in GURPS, where 3d6 becomes 2d6+4
+// or 1d6+7. Fewer dice are converted if the modifier would otherwise exceed the Config's MaxModifier. Dice that use
+// any notation beyond their count, sides, modifier and multiplier are returned unconverted.
+func (r *Roller) ApplyModifiersFromDice(dice Dice, count int) Dice {
+	dice = r.Normalize(dice)
+	if !dice.isPlain() || dice.Sides < 2 || count < 1 {
+		return dice
+	}
+	count = min(count, dice.Count)
+	// The room left below MaxModifier limits the dice that may be converted, just as a modifier limits the dice it
+	// converts into.
+	fits, _ := computeExtraDice(dice.Sides, r.config().MaxModifier-dice.Modifier, count)
+	dice.Count -= fits
+	dice.Modifier += extraDiceCost(dice.Sides, fits)
+	return r.Normalize(dice)
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestGURPSDamage(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, true, false)
+	for i, one := range []struct {
+		ST     int
+		Thrust string
+		Swing  string
+	}{
+		{1, "1d-6", "1d-5"},    // 0
+		{9, "1d-2", "1d-1"},    // 1
+		{10, "1d-2", "1d"},     // 2
+		{13, "1d", "2d-1"},     // 3
+		{17, "1d+2", "3d-1"},   // 4
+		{19, "2d-1", "3d+1"},   // 5
+		{28, "3d-1", "5d+1"},   // 6
+		{40, "4d+1", "7d-1"},   // 7
+		{44, "4d+1", "7d-1"},   // 8 - between rows, so the lower is used
+		{45, "5d", "7d+1"},     // 9
+		{60, "7d-1", "9d"},     // 10
+		{99, "10d+2", "12d+2"}, // 11
+		{100, "11d", "13d"},    // 12
+		{109, "11d", "13d"},    // 13
+		{110, "12d", "14d"},    // 14
+		{125, "13d", "15d"},    // 15
+		{0, "0", "0"},          // 16
+		{-5, "0", "0"},         // 17
+		{1000, "101d", "103d"}, // 18
+	} {
+		desc := fmt.Sprintf("Table index %d: ST %d", i, one.ST)
+		c.Equal(one.Thrust, r.Format(dice.GURPSThrust(one.ST)), desc)
+		c.Equal(one.Swing, r.Format(dice.GURPSSwing(one.ST)), desc)
+	}
+
+	// Damage never falls as ST rises, and swing is never less than thrust.
+	previousThrust, previousSwing := math.Inf(-1), math.Inf(-1)
+	for st := 1; st <= 200; st++ {
+		thrust := averageDamage(dice.GURPSThrust(st))
+		swing := averageDamage(dice.GURPSSwing(st))
+		c.True(thrust >= previousThrust, st)
+		c.True(swing >= previousSwing, st)
+		c.True(swing >= thrust, st)
+		previousThrust, previousSwing = thrust, swing
+	}
+	c.Equal(13+(math.MaxInt-100)/10, dice.GURPSSwing(math.MaxInt).Count)
+}
+
+func averageDamage(d dice.Dice) float64 {
+	return float64(d.Count)*3.5 + float64(d.Modifier)
+}
+
+func TestApplyModifiersFromDice(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, true, false)
+	for i, one := range []struct {
+		Text     string
+		Count    int
+		Expected string
+	}{
+		{"3d", 1, "2d+4"},       // 0
+		{"3d", 2, "1d+7"},       // 1
+		{"3d", 3, "11"},         // 2
+		{"3d+2", 1, "2d+6"},     // 3
+		{"3d-1", 2, "1d+6"},     // 4
+		{"3d", 5, "11"},         // 5 - no more dice than there are
+		{"3d", 0, "3d"},         // 6
+		{"3d", -1, "3d"},        // 7
+		{"2d5", 1, "1d5+3"},     // 8
+		{"2d5", 2, "6"},         // 9
+		{"3d!", 1, "3d!"},       // 10 - exploding dice are not converted
+		{"4d6kh3", 1, "4dkh3"},  // 11
+		{"2dF", 1, "2dF"},       // 12
+		{"3d+2x2", 1, "2d+6x2"}, // 13
+	} {
+		desc := fmt.Sprintf("Table index %d: %s less %d", i, one.Text, one.Count)
+		d := r.ApplyModifiersFromDice(r.Parse(one.Text), one.Count)
+		c.Equal(one.Expected, r.Format(d), desc)
+	}
+
+	// Converting the dice back to a modifier and then the modifier back to dice restores the dice.
+	for count := 1; count <= 10; count++ {
+		d := r.Parse(fmt.Sprintf("%dd", count+1))
+		c.Equal(d, r.ApplyExtraDiceFromModifiers(r.ApplyModifiersFromDice(d, count)), count)
+	}
+
+	// No more dice are converted than MaxModifier allows.
+	d := r.ApplyModifiersFromDice(r.Parse("3d+999990"), 3)
+	c.Equal(1, d.Count)
+	c.Equal(999997, d.Modifier)
+}
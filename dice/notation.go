@@ -0,0 +1,498 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+var selectionNotation = [...]string{
+	KeepHighest: "kh",
+	KeepLowest:  "kl",
+	DropHighest: "dh",
+	DropLowest:  "dl",
+}
+
+var rerollNotation = [...]string{
+	RerollOnce:  "ro",
+	RerollUntil: "r",
+}
+
+var explodeNotation = [...]string{
+	Explode:   "!",
+	Compound:  "!!",
+	Penetrate: "!p",
+}
+
+var openEndedNotation = [...]string{
+	OpenEndedHigh: "oeh",
+	OpenEndedLow:  "oel",
+	OpenEndedBoth: "oe",
+}
+
+// ParseError describes a problem found while strictly parsing dice notation with Roller.ParseStrict or
+// Roller.ParseExpression.
+type ParseError struct {
+	// Input is the text that was being parsed.
+	Input string
+	// Token is the text at Offset that could not be parsed, such as a single unexpected character or a run of digits
+	// whose value is too large. It is empty when the problem is at the end of the Input.
+	Token string
+	// Reason describes the problem.
+	Reason string
+	// Offset is the byte offset within Input at which the problem was found.
+	Offset int
+}
+
+func newParseError(in string, pos int, reason string) *ParseError {
+	return &ParseError{Input: in, Token: tokenAt(in, pos), Reason: reason, Offset: pos}
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("invalid dice notation %q at offset %d: %s", e.Input, e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("invalid dice notation %q at offset %d (%q): %s", e.Input, e.Offset, e.Token, e.Reason)
+}
+
+// tokenAt returns the token at pos: a run of digits, a run of ASCII letters, or otherwise a single character.
+func tokenAt(in string, pos int) string {
+	if pos < 0 || pos >= len(in) {
+		return ""
+	}
+	end := pos
+	switch ch := in[pos]; {
+	case isDigit(rune(ch)):
+		for end < len(in) && isDigit(rune(in[end])) {
+			end++
+		}
+	case lowerASCII(ch) >= 'a' && lowerASCII(ch) <= 'z':
+		for end < len(in) && lowerASCII(in[end]) >= 'a' && lowerASCII(in[end]) <= 'z' {
+			end++
+		}
+	default:
+		_, size := utf8.DecodeRuneInString(in[pos:])
+		end += size
+	}
+	return in[pos:end]
+}
+
+// syntaxError describes a problem found while parsing dice notation.
+type syntaxError struct {
+	pos    int
+	reason string
+}
+
+// parseSuffixes parses the modifiers that may follow the sides of a dice term, such as the kh3 in 4d6kh3, starting at
+// pos. It returns the position just past the last modifier it recognized. A malformed or repeated modifier stops the
+// parse and is reported along with the position it started at. When strict is true, a value that exceeds its limit, or
+// that normalization would change or discard because of the dice it applies to, is reported as well.
+func parseSuffixes(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	// The keep or drop count is checked once all the modifiers are known, since an Explode that follows it may add dice.
+	selectCountPos := -1
+	for pos < len(in) {
+		start := pos
+		var err *syntaxError
+		switch lowerASCII(in[pos]) {
+		case 'k', 'd':
+			if hasPrefixFold(in[pos:], "dbl") {
+				pos, err = parseDouble(in, pos, dice, cfg, strict)
+			} else {
+				if pos, err = parseSelection(in, pos, dice, cfg, strict); err == nil && pos != start {
+					selectCountPos = digitsStart(in, start, pos)
+				}
+			}
+		case '!':
+			pos, err = parseExplode(in, pos, dice, cfg, strict)
+		case '>':
+			pos, err = parseSuccess(in, pos, dice, cfg, strict)
+		case 'f':
+			pos, err = parseFailure(in, pos, dice, cfg, strict)
+		case 'r':
+			pos, err = parseReroll(in, pos, dice, cfg, strict)
+		case 'o':
+			pos, err = parseOpenEnded(in, pos, dice, strict)
+		default:
+			return pos, checkSelectCount(selectCountPos, dice, strict)
+		}
+		if err != nil {
+			return start, err
+		}
+		if pos == start {
+			break
+		}
+	}
+	return pos, checkSelectCount(selectCountPos, dice, strict)
+}
+
+// checkSelectCount reports a keep or drop count, found at pos, that exceeds the number of dice when strict is true.
+// Normalization caps such a count, except for an Explode, whose explosions add dice that may be kept or dropped. pos is
+// -1 if there is no keep or drop modifier.
+func checkSelectCount(pos int, dice *Dice, strict bool) *syntaxError {
+	if !strict || pos == -1 || dice.Explode == Explode || dice.SelectCount <= dice.Count {
+		return nil
+	}
+	return errExceeds(pos, "keep or drop count", dice.Count)
+}
+
+// parseSelection parses a keep or drop modifier: 'k' or 'kh' keeps the highest dice, 'kl' keeps the lowest, 'dh' drops
+// the highest and 'dl' drops the lowest. The number of dice to keep or drop follows and defaults to 1 when omitted. A
+// 'd' not followed by 'h' or 'l' is not a modifier, so pos is returned unchanged for it.
+func parseSelection(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	start := pos
+	drop := lowerASCII(in[pos]) == 'd'
+	pos++
+	var selection Selection
+	switch peekLower(in, pos) {
+	case 'h':
+		pos++
+		selection = KeepHighest
+	case 'l':
+		pos++
+		selection = KeepLowest
+	default:
+		if drop {
+			return start, nil
+		}
+		selection = KeepHighest
+	}
+	if drop {
+		selection += DropHighest - KeepHighest
+	}
+	if dice.Selection != SelectAll {
+		return start, &syntaxError{pos: start, reason: "only one keep or drop modifier is permitted"}
+	}
+	if dice.SuccessThreshold != 0 {
+		return start, errSelectionWithSuccesses(start)
+	}
+	count, end, err := parseLimitedValue(in, pos, cfg.MaxCount, "keep or drop count", strict)
+	if err != nil {
+		return start, err
+	}
+	if end == pos {
+		count = 1
+	}
+	dice.Selection = selection
+	dice.SelectCount = count
+	return end, nil
+}
+
+// parseExplode parses an explosion modifier: '!' explodes, '!!' compounds and '!p' penetrates. An optional threshold
+// may follow, written as '>' or '>=' and the lowest face that explodes; without one, only the highest face explodes.
+func parseExplode(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	start := pos
+	pos++
+	mode := Explode
+	switch peekLower(in, pos) {
+	case '!':
+		pos++
+		mode = Compound
+	case 'p':
+		pos++
+		mode = Penetrate
+	}
+	if dice.Explode != NoExplode {
+		return start, &syntaxError{pos: start, reason: "only one explosion modifier is permitted"}
+	}
+	if mode == Compound && dice.SuccessThreshold != 0 {
+		return start, errCompoundWithSuccesses(start)
+	}
+	if dice.Faces != "" {
+		return start, errWithFaces(start, "explode")
+	}
+	if dice.OpenEnded != NoOpenEnded {
+		return start, errWithOpenEnded(start, "explode")
+	}
+	threshold, end, err := parseThreshold(in, pos, '>', cfg.MaxSides, "explosion threshold", strict)
+	if err != nil {
+		return start, err
+	}
+	if strict && threshold > dice.Sides {
+		// No face can reach such a threshold, so normalization would discard the explosion.
+		return start, errExceeds(digitsStart(in, start, end), "explosion threshold", dice.Sides)
+	}
+	if end != pos && threshold < 1 {
+		// An explicit threshold of 0 explodes on every face, just as 1 does; 0 itself is reserved for the default.
+		threshold = 1
+	}
+	dice.Explode = mode
+	dice.ExplodeThreshold = threshold
+	return end, nil
+}
+
+// parseReroll parses a reroll modifier: 'ro' rolls a die again once and 'r' keeps rolling it again. Either is
+// followed by an optional '<' or '<=' and the highest face that is rolled again, which defaults to 1 when omitted.
+func parseReroll(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	start := pos
+	pos++
+	mode := RerollUntil
+	if peekLower(in, pos) == 'o' {
+		pos++
+		mode = RerollOnce
+	}
+	if dice.Reroll != NoReroll {
+		return start, &syntaxError{pos: start, reason: "only one reroll modifier is permitted"}
+	}
+	if dice.Faces != "" {
+		return start, errWithFaces(start, "be rerolled")
+	}
+	if dice.OpenEnded != NoOpenEnded {
+		return start, errWithOpenEnded(start, "be rerolled")
+	}
+	threshold, end, err := parseCeiling(in, pos, cfg.MaxSides, "reroll threshold", strict)
+	if err != nil {
+		return start, err
+	}
+	if strict && threshold > dice.Sides {
+		return start, errExceeds(digitsStart(in, start, end), "reroll threshold", dice.Sides)
+	}
+	dice.Reroll = mode
+	dice.RerollThreshold = threshold
+	return end, nil
+}
+
+// parseSuccess parses a success threshold, written as '>' or '>=' and the lowest face that counts as a success.
+func parseSuccess(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	start := pos
+	switch {
+	case dice.SuccessThreshold != 0:
+		return start, &syntaxError{pos: start, reason: "only one success threshold is permitted"}
+	case dice.Selection != SelectAll:
+		return start, errSelectionWithSuccesses(start)
+	case dice.Explode == Compound:
+		return start, errCompoundWithSuccesses(start)
+	case dice.Faces != "":
+		return start, errWithFaces(start, "count successes")
+	case dice.OpenEnded != NoOpenEnded:
+		return start, errWithOpenEnded(start, "count successes")
+	}
+	threshold, end, err := parseThreshold(in, pos, '>', cfg.MaxSides, "success threshold", strict)
+	if err != nil {
+		return start, err
+	}
+	if strict && threshold > dice.Sides+1 {
+		// Every threshold above the highest face counts no successes, so normalization lowers them all to Sides+1.
+		return start, errExceeds(digitsStart(in, start, end), "success threshold", dice.Sides+1)
+	}
+	// An explicit threshold of 0 counts every face, just as 1 does; 0 itself means successes are not counted.
+	dice.SuccessThreshold = max(threshold, 1)
+	return end, nil
+}
+
+// parseOpenEnded parses an open-ended modifier: 'oe' rolls again on both high and low rolls, 'oeh' only on high rolls
+// and 'oel' only on low rolls. An 'o' not followed by 'e' is not a modifier, so pos is returned unchanged for it.
+// When strict is true, a die with fewer than 2 sides, which normalization would stop being open-ended, is reported.
+func parseOpenEnded(in string, pos int, dice *Dice, strict bool) (int, *syntaxError) {
+	start := pos
+	if peekLower(in, pos+1) != 'e' {
+		return start, nil
+	}
+	pos += 2
+	mode := OpenEndedBoth
+	switch peekLower(in, pos) {
+	case 'h':
+		pos++
+		mode = OpenEndedHigh
+	case 'l':
+		pos++
+		mode = OpenEndedLow
+	}
+	switch {
+	case dice.OpenEnded != NoOpenEnded:
+		return start, &syntaxError{pos: start, reason: "only one open-ended modifier is permitted"}
+	case dice.Explode != NoExplode:
+		return start, errWithOpenEnded(start, "explode")
+	case dice.Reroll != NoReroll:
+		return start, errWithOpenEnded(start, "be rerolled")
+	case dice.SuccessThreshold != 0:
+		return start, errWithOpenEnded(start, "count successes")
+	case dice.Faces != "":
+		return start, errWithFaces(start, "be open-ended")
+	case strict && dice.Sides < 2:
+		return start, &syntaxError{pos: start, reason: "open-ended dice need at least 2 sides"}
+	}
+	dice.OpenEnded = mode
+	return pos, nil
+}
+
+// parseDouble parses a double success threshold, written as 'dbl' and the lowest face that counts as two successes.
+func parseDouble(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	start := pos
+	switch {
+	case dice.SuccessThreshold == 0:
+		return start, &syntaxError{pos: start, reason: "double successes require a success threshold"}
+	case dice.DoubleThreshold != 0:
+		return start, &syntaxError{pos: start, reason: "only one double success threshold is permitted"}
+	}
+	pos += len("dbl")
+	threshold, end, err := parseLimitedValue(in, pos, cfg.MaxSides, "double success threshold", strict)
+	if err != nil {
+		return start, err
+	}
+	if end == pos {
+		return start, &syntaxError{pos: start, reason: "double success threshold is missing its value"}
+	}
+	threshold = max(threshold, 1)
+	if strict {
+		// Normalization discards a threshold no face can reach, and raises one below the success threshold to meet it.
+		switch {
+		case threshold > dice.Sides:
+			return start, errExceeds(pos, "double success threshold", dice.Sides)
+		case threshold < dice.SuccessThreshold:
+			return start, &syntaxError{pos: pos, reason: "double success threshold is below the success threshold"}
+		}
+	}
+	dice.DoubleThreshold = threshold
+	return end, nil
+}
+
+// parseFailure parses a failure threshold, written as 'f', optionally followed by '<' or '<=', and the highest face
+// that takes a success away. The face defaults to 1 when omitted.
+func parseFailure(in string, pos int, dice *Dice, cfg *Config, strict bool) (int, *syntaxError) {
+	start := pos
+	switch {
+	case dice.SuccessThreshold == 0:
+		return start, &syntaxError{pos: start, reason: "failures require a success threshold"}
+	case dice.FailureThreshold != 0:
+		return start, &syntaxError{pos: start, reason: "only one failure threshold is permitted"}
+	}
+	threshold, end, err := parseCeiling(in, pos+1, cfg.MaxSides, "failure threshold", strict)
+	if err != nil {
+		return start, err
+	}
+	if strict && threshold >= dice.SuccessThreshold {
+		// A face cannot be both a success and a failure, so normalization lowers such a threshold below the success
+		// threshold.
+		return start, &syntaxError{pos: digitsStart(in, start, end), reason: "failure threshold must be below the " +
+			"success threshold"}
+	}
+	dice.FailureThreshold = threshold
+	return end, nil
+}
+
+// parseCeiling parses the highest face a modifier applies to, written as an optional '<' or '<=' followed by the face.
+// The face defaults to 1 when omitted, and is never less than 1.
+func parseCeiling(in string, pos, maxValue int, what string, strict bool) (value, end int, err *syntaxError) {
+	if value, end, err = parseThreshold(in, pos, '<', maxValue, what, strict); err != nil {
+		return 0, pos, err
+	}
+	if end == pos {
+		if value, end, err = parseLimitedValue(in, pos, maxValue, what, strict); err != nil {
+			return 0, pos, err
+		}
+		if end == pos {
+			value = 1
+		}
+	}
+	return max(value, 1), end, nil
+}
+
+func errSelectionWithSuccesses(pos int) *syntaxError {
+	return &syntaxError{pos: pos, reason: "keep and drop modifiers cannot be combined with a success threshold"}
+}
+
+func errCompoundWithSuccesses(pos int) *syntaxError {
+	return &syntaxError{pos: pos, reason: "compounding explosions cannot be combined with a success threshold"}
+}
+
+func errWithFaces(pos int, action string) *syntaxError {
+	return &syntaxError{pos: pos, reason: "custom dice cannot " + action}
+}
+
+func errWithOpenEnded(pos int, action string) *syntaxError {
+	return &syntaxError{pos: pos, reason: "open-ended dice cannot " + action}
+}
+
+// parseThreshold parses an optional comparison, written as the given comparison character, optionally followed by
+// '=', and then a value, returning 0 when no comparison is present. The comparison is inclusive either way, so '>8'
+// and '>=8' both mean 8 or higher.
+func parseThreshold(in string, pos int, comparison byte, maxValue int, what string, strict bool) (value, end int,
+	err *syntaxError,
+) {
+	if pos >= len(in) || in[pos] != comparison {
+		return 0, pos, nil
+	}
+	start := pos
+	pos++
+	if pos < len(in) && in[pos] == '=' {
+		pos++
+	}
+	if value, end, err = parseLimitedValue(in, pos, maxValue, what, strict); err != nil {
+		return 0, start, err
+	}
+	if end == pos {
+		return 0, start, &syntaxError{pos: start, reason: what + " is missing its value"}
+	}
+	return value, end, nil
+}
+
+// parseLimitedValue parses the run of digits at pos, which may be empty, in which case 0 is returned. A value greater
+// than maxValue is capped, or, when strict is true, reported as an error.
+func parseLimitedValue(in string, pos, maxValue int, what string, strict bool) (value, end int, err *syntaxError) {
+	if !strict {
+		value, end = extractValue(in, pos, maxValue)
+		return value, end, nil
+	}
+	// maxValue is at most maxFieldValue, so maxValue+1 cannot overflow; capping there distinguishes a value that is
+	// too large from one that exactly reaches the limit.
+	if value, end = extractValue(in, pos, maxValue+1); value > maxValue {
+		return 0, pos, errExceeds(pos, what, maxValue)
+	}
+	return value, end, nil
+}
+
+func errExceeds(pos int, what string, limit int) *syntaxError {
+	return &syntaxError{pos: pos, reason: what + " exceeds " + strconv.Itoa(limit)}
+}
+
+// digitsStart returns the position of the run of digits that ends at end, or start if there is none, such as when a
+// modifier that begins at start was written without its value.
+func digitsStart(in string, start, end int) int {
+	pos := end
+	for pos > start && isDigit(rune(in[pos-1])) {
+		pos--
+	}
+	if pos == end {
+		return start
+	}
+	return pos
+}
+
+// hasPrefixFold reports whether in begins with prefix, ignoring the case of ASCII letters. The prefix must be
+// lowercase.
+func hasPrefixFold(in, prefix string) bool {
+	if len(in) < len(prefix) {
+		return false
+	}
+	for i := range len(prefix) {
+		if lowerASCII(in[i]) != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func peekLower(in string, pos int) byte {
+	if pos < len(in) {
+		return lowerASCII(in[pos])
+	}
+	return 0
+}
+
+func lowerASCII(ch byte) byte {
+	if ch >= 'A' && ch <= 'Z' {
+		return ch + 'a' - 'A'
+	}
+	return ch
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestParseStrict(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, text := range []string{
+		"3d6",              // 0
+		"3D6+2",            // 1
+		"d20",              // 2
+		"2d",               // 3
+		"5",                // 4
+		"5+3",              // 5
+		"-2",               // 6
+		"  4d6kh3  ",       // 7
+		"2d6-1x3",          // 8
+		"3d6!p>5",          // 9
+		"10d10>=7dbl10f1",  // 10
+		"4d6ro<2",          // 11
+		"4dF+1",            // 12
+		"2d{0,1,1,2}",      // 13
+		"999999d999999",    // 14
+		"d6+999999x999999", // 15
+		"0d6",              // 16
+		"1d6+1d4",          // 17 - the lenient Parse ignores the second term, so strict parsing must not
+		"3d6kh3",           // 18 - keeping every die is the same as keeping them all
+		"3d6!kh5",          // 19 - an Explode may keep more dice than are rolled
+		"3d6>=7",           // 20 - a success threshold just above the highest face
+		"3d6!>6",           // 21 - an explosion threshold at the highest face
+		"10d10>=7dbl7f6",   // 22 - double and failure thresholds beside the success threshold
+		"1d6r<6",           // 23 - a reroll threshold at the highest face
+	} {
+		desc := fmt.Sprintf("Table index %d: %q", i, text)
+		d, err := r.ParseStrict(text)
+		if i == 17 {
+			c.HasError(err, desc)
+			continue
+		}
+		c.NoError(err, desc)
+		c.Equal(r.Parse(text), d, desc)
+	}
+}
+
+func TestParseStrictErrors(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		Text   string
+		Offset int
+		Token  string
+	}{
+		{"3d6+x", 4, "x"},              // 0 - a modifier sign with no value
+		{"3d6++2", 4, "+"},             // 1 - a doubled sign
+		{"3d1000000", 2, "1000000"},    // 2 - sides exceed MaxSides
+		{"1000000d6", 0, "1000000"},    // 3 - count exceeds MaxCount
+		{"3d6+1000000", 4, "1000000"},  // 4 - modifier exceeds MaxModifier
+		{"2d6x1000000", 4, "1000000"},  // 5 - multiplier exceeds MaxMultiplier
+		{"2d6x", 4, ""},                // 6 - a multiplier with no value
+		{"2d6x0", 4, "0"},              // 7 - a zero multiplier
+		{"", 0, ""},                    // 8 - empty
+		{"   ", 3, ""},                 // 9 - only whitespace
+		{"d", 0, "d"},                  // 10 - a lone die marker
+		{"3d6 + 2", 3, " "},            // 11 - whitespace within the specification
+		{"3d6q", 3, "q"},               // 12 - an unknown suffix
+		{"4d6kh3kl1", 6, "kl"},         // 13 - a repeated selection
+		{"4d6kh5000000", 5, "5000000"}, // 14 - selection count exceeds MaxCount
+		{"2d6!!>=", 5, ">"},            // 15 - an explosion threshold with no value
+		{"4dF!", 3, "!"},               // 16 - custom dice do not explode
+		{"2d{1,2", 2, "{"},             // 17 - an unclosed face list
+		{"3d6+2+1", 5, "+"},            // 18 - a second modifier
+		{"3d6x2x2", 5, "x"},            // 19 - a second multiplier
+		{"3d6é", 3, "é"},               // 20 - a multi-byte character
+		{"3d0", 2, "0"},                // 21 - zero sides
+		{"d0+2", 1, "0"},               // 22 - zero sides without a count
+		{"3d6kh5", 5, "5"},             // 23 - keeps more dice than are rolled
+		{"3d6dl4", 5, "4"},             // 24 - drops more dice than are rolled
+		{"3d6kh5!!", 5, "5"},           // 25 - compounding adds no dice to keep
+		{"3d6>=9", 5, "9"},             // 26 - success threshold would become >=7
+		{"3d6>8", 4, "8"},              // 27 - success threshold without '='
+		{"10d10>=7dbl11", 11, "11"},    // 28 - double success threshold above the highest face
+		{"10d10>=7dbl5", 11, "5"},      // 29 - double success threshold below the success threshold
+		{"10d10>=7f7", 9, "7"},         // 30 - failure threshold at the success threshold
+		{"10d10>=1f", 8, "f"},          // 31 - default failure threshold at the success threshold
+		{"4d6r<7", 5, "7"},             // 32 - reroll threshold above the highest face
+		{"4d6ro7", 5, "7"},             // 33 - reroll threshold without '<'
+		{"3d6!>7", 5, "7"},             // 34 - explosion threshold above the highest face
+		{"3d6!!>=8", 7, "8"},           // 35 - compounding threshold above the highest face
+		{"3d1oe", 3, "oe"},             // 36 - open-ended dice with a single side
+		{"0x3", 2, "3"},                // 37 - a multiplier with nothing to multiply
+	} {
+		desc := fmt.Sprintf("Table index %d: %q", i, one.Text)
+		d, err := r.ParseStrict(one.Text)
+		c.HasError(err, desc)
+		c.Equal(dice.Dice{}, d, desc)
+		var parseErr *dice.ParseError
+		c.True(errors.As(err, &parseErr), desc)
+		if parseErr != nil {
+			c.Equal(one.Text, parseErr.Input, desc)
+			c.Equal(one.Offset, parseErr.Offset, desc)
+			c.Equal(one.Token, parseErr.Token, desc)
+			c.NotEqual("", parseErr.Reason, desc)
+		}
+	}
+
+	_, err := r.ParseStrict("3d1000000")
+	c.Equal(`invalid dice notation "3d1000000" at offset 2 ("1000000"): number of sides exceeds 999999`, err.Error())
+	_, err = r.ParseStrict("2d6x")
+	c.Equal(`invalid dice notation "2d6x" at offset 4: multiplier is missing its value`, err.Error())
+	_, err = r.ParseStrict("3d6>=9")
+	c.Equal(`invalid dice notation "3d6>=9" at offset 5 ("9"): success threshold exceeds 7`, err.Error())
+
+	// Expressions report their problems the same way.
+	_, err = r.ParseExpression("2d6+(1d4")
+	var parseErr *dice.ParseError
+	c.True(errors.As(err, &parseErr))
+	c.Equal(8, parseErr.Offset)
+}
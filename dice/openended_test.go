@@ -0,0 +1,158 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestOpenEnded(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"d100oe", "d100oe", -9999, 10100, 50},           // 0
+		{"d100oeh", "d100oeh", 1, 10100, 53},             // 1 - 50.5 * (1 + 0.05/0.95)
+		{"d100oel", "d100oel", -9999, 100, 47},           // 2
+		{"D100OE", "d100oe", -9999, 10100, 50},           // 3
+		{"d20oe", "d20oe", -1999, 2020, 10},              // 4 - a single face at each end
+		{"2d100oekh1", "2d100oekh1", -9999, 10100, 72},   // 5
+		{"d100oe+5x2", "d100oe+5x2", -19988, 20210, 110}, // 6
+		{"d100oe!", "d100oe", -9999, 10100, 50},          // 7 - open-ended dice cannot also explode
+		{"d100oeoe", "d100oe", -9999, 10100, 50},         // 8 - a second open-ended modifier ends the spec
+		{"d100o", "d100", 1, 100, 50},                    // 9 - an 'o' alone is not a modifier
+		{"d1oe", "d1", 1, 1, 1},                          // 10 - a single face cannot open-end
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		r := newRoller(c, nil, false, false)
+		d := r.Parse(one.Text)
+		c.Equal(one.Expected, r.Format(d), desc)
+		c.Equal(one.Minimum, r.Minimum(d), desc)
+		c.Equal(one.Maximum, r.Maximum(d), desc)
+		c.Equal(one.Average, r.Average(d), desc)
+		c.True(r.IsEquivalent(d, r.Parse(r.Format(d))), desc)
+		for range 100 {
+			v := r.Roll(d)
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+	}
+}
+
+func TestOpenEndedParseStrictErrors(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, text := range []string{
+		"d100oe!",   // 0
+		"d100!oe",   // 1
+		"d100oer",   // 2
+		"d100roe",   // 3
+		"d100oe>50", // 4
+		"d100>50oe", // 5
+		"d100oeoeh", // 6
+		"d100oq",    // 7
+	} {
+		_, err := r.ParseStrict(text)
+		c.HasError(err, "Table index %d: %s", i, text)
+	}
+}
+
+func TestOpenEndedRollDetailed(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Values   []int
+		Expected string
+		Total    int
+	}{
+		{"d100oe", []int{97, 99, 6}, "d100oe → [98!+100!+7] = 205", 205},     // 0
+		{"d100oe", []int{2, 96, 44}, "d100oe → [3!-97!-45] = -139", -139},    // 1
+		{"d100oe", []int{49}, "d100oe → [50] = 50", 50},                      // 2
+		{"d100oeh", []int{2}, "d100oeh → [3] = 3", 3},                        // 3
+		{"d100oel", []int{97}, "d100oel → [98] = 98", 98},                    // 4
+		{"d100oel", []int{0, 50}, "d100oel → [1!-51] = -50", -50},            // 5
+		{"d100oeh+10", []int{95, 4}, "d100oeh+10 → [96!+5] + 10 = 111", 111}, // 6
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		rnd := &sequenceRandomizer{values: one.Values}
+		r := newRoller(c, rnd, false, false)
+		d := r.Parse(one.Text)
+		res := r.RollDetailed(d)
+		c.Equal(one.Expected, res.String(), desc)
+		c.Equal(one.Total, res.Total, desc)
+		c.Equal(one.Total < 0, res.Rolls[0].Subtracted, desc)
+
+		rnd.next = 0
+		c.Equal(res.Total, r.Roll(d), desc)
+	}
+}
+
+func TestOpenEndedChainLimit(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.MaxExplosions = 2
+	cfg.Randomizer = topFaceRandomizer{}
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	d := r.Parse("d100oe")
+	c.Equal(300, r.Roll(d))
+	c.Equal(300, r.Maximum(d))
+	c.Equal(-199, r.Minimum(d))
+	res := r.RollDetailed(d)
+	c.Equal([]int{100, 100, 100}, res.Rolls[0].Faces)
+
+	cfg.MaxExplosions = 0
+	r, err = dice.NewRoller(cfg)
+	c.NoError(err)
+	c.Equal(100, r.Roll(d))
+	c.Equal(1, r.Minimum(d))
+}
+
+func TestOpenEndedDistribution(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		Text string
+		Mean float64
+	}{
+		{"d100oe", 50.5},            // 0
+		{"d100oeh", 50.5 + 50.5/19}, // 1
+		{"d100oel", 50.5 - 50.5/19}, // 2
+		{"d20oe", 10.5},             // 3
+		{"2d100oe+3", 2*50.5 + 3},   // 4
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		dist, err := r.Distribution(r.Parse(one.Text))
+		c.NoError(err, desc)
+		var sum float64
+		for _, p := range dist.All() {
+			sum += p
+		}
+		c.True(near(1, sum), desc)
+		c.True(near(one.Mean, dist.Mean()), "%s: mean %v", desc, dist.Mean())
+	}
+
+	dist, err := r.Distribution(r.Parse("d100oe"))
+	c.NoError(err)
+	c.True(near(0.01, dist.Probability(50)))
+	// 148 is any of the five high faces plus a roll of 48 to 52, and -47 is any of the five low faces less one.
+	c.True(near(5*0.01*0.01, dist.Probability(148)))
+	c.True(near(5*0.01*0.01, dist.Probability(-47)))
+	// 193 is a high face plus 93 to 95, or 96 plus 97, which is itself 96 and then a 1.
+	c.True(near(3*0.01*0.01+0.01*0.01*0.01, dist.Probability(193)))
+}
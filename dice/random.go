@@ -0,0 +1,219 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"encoding/hex"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"github.com/richardwilkes/toolbox/v2/errs"
+	"github.com/richardwilkes/toolbox/v2/xrand"
+)
+
+// seedStream is mixed into a seed to form the second half of the state of a SeededRandomizer's generator.
+const seedStream = 0x9e3779b97f4a7c15
+
+var (
+	_ xrand.Randomizer = &SeededRandomizer{}
+	_ xrand.Randomizer = &RecordingRandomizer{}
+	_ xrand.Randomizer = &ReplayRandomizer{}
+)
+
+// SeededRandomizer is a deterministic Randomizer: two created with the same seed produce the same sequence of values.
+// The sequence is fixed by the seed alone, so it does not change between releases of Go or of this package. It is safe
+// for concurrent use, although concurrent callers will interleave their draws unpredictably.
+type SeededRandomizer struct {
+	lock sync.Mutex
+	pcg  *rand.PCG
+	seed uint64
+}
+
+// NewSeededRandomizer creates a new SeededRandomizer that produces the sequence of values identified by seed.
+func NewSeededRandomizer(seed uint64) *SeededRandomizer {
+	return &SeededRandomizer{pcg: rand.NewPCG(seed, seed^seedStream), seed: seed}
+}
+
+// Seed returns the seed the SeededRandomizer was created with.
+func (s *SeededRandomizer) Seed() uint64 {
+	return s.seed
+}
+
+// Derive returns a new SeededRandomizer whose sequence is identified by this one's seed and the given id. The derived
+// sequence depends on neither this SeededRandomizer's position within its own sequence nor on any other derived from it,
+// so each worker of a concurrent simulation may be given its own, and the simulation reproduced from the one seed.
+func (s *SeededRandomizer) Derive(id uint64) *SeededRandomizer {
+	return NewSeededRandomizer(mix64(s.seed ^ mix64(id^seedStream)))
+}
+
+// mix64 scrambles the bits of x, using the finalizer of the SplitMix64 generator, so that nearby inputs give unrelated
+// outputs.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// state returns the current position within the sequence, encoded as text.
+func (s *SeededRandomizer) state() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, err := s.pcg.MarshalBinary()
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(data)
+}
+
+// restoreState moves to the position within the sequence previously returned by state.
+func (s *SeededRandomizer) restoreState(state string) error {
+	data, err := hex.DecodeString(state)
+	if err != nil {
+		return errs.NewWithCause("invalid randomizer state", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err = s.pcg.UnmarshalBinary(data); err != nil {
+		return errs.NewWithCause("invalid randomizer state", err)
+	}
+	return nil
+}
+
+// Intn returns a non-negative random number from 0 to n-1. If n <= 0, 0 is returned without advancing the sequence.
+func (s *SeededRandomizer) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// Reject the values from the partial span at the top of the range so that every result is equally likely.
+	bound := uint64(n)
+	limit := math.MaxUint64 - math.MaxUint64%bound
+	for {
+		if v := s.pcg.Uint64(); v < limit {
+			return int(v % bound)
+		}
+	}
+}
+
+// Draw holds a single value drawn from a Randomizer.
+type Draw struct {
+	// Sequence is the position of the draw within the log, starting at 1.
+	Sequence int `json:"seq"`
+	// N is the number of possible values that were requested.
+	N int `json:"n"`
+	// Value is the value that was returned, from 0 to N-1.
+	Value int `json:"value"`
+}
+
+// RecordingRandomizer wraps another Randomizer, logging every value drawn from it. Setting one as a Config's Randomizer
+// captures the raw draws behind each roll, so a roll can later be audited or replayed with a ReplayRandomizer. It is
+// safe for concurrent use.
+type RecordingRandomizer struct {
+	lock   sync.Mutex
+	source xrand.Randomizer
+	draws  []Draw
+}
+
+// NewRecordingRandomizer creates a new RecordingRandomizer that draws its values from source.
+func NewRecordingRandomizer(source xrand.Randomizer) *RecordingRandomizer {
+	return &RecordingRandomizer{source: source}
+}
+
+// Intn returns a non-negative random number from 0 to n-1, drawn from the wrapped Randomizer, and logs it. If n <= 0, 0
+// is returned and nothing is logged.
+func (r *RecordingRandomizer) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	v := r.source.Intn(n)
+	r.draws = append(r.draws, Draw{Sequence: len(r.draws) + 1, N: n, Value: v})
+	return v
+}
+
+// Draws returns a copy of the log of the values drawn so far, in the order they were drawn.
+func (r *RecordingRandomizer) Draws() []Draw {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return slices.Clone(r.draws)
+}
+
+// Reset clears the log, so that the next value drawn is logged with a Sequence of 1.
+func (r *RecordingRandomizer) Reset() {
+	r.lock.Lock()
+	r.draws = nil
+	r.lock.Unlock()
+}
+
+// ReplayRandomizer is a Randomizer that returns the values from a log captured by a RecordingRandomizer, in order.
+// Performing the same rolls with the same Config that produced the log reproduces their results exactly. Should the
+// rolls request something other than what the log holds, or run past its end, Err reports the first such mismatch and
+// every value returned from then on is 0. It is safe for concurrent use.
+type ReplayRandomizer struct {
+	lock  sync.Mutex
+	err   error
+	draws []Draw
+	next  int
+}
+
+// NewReplayRandomizer creates a new ReplayRandomizer that returns the values from draws. A copy of draws is made.
+func NewReplayRandomizer(draws []Draw) *ReplayRandomizer {
+	return &ReplayRandomizer{draws: slices.Clone(draws)}
+}
+
+// Intn returns the next value from the log. If n <= 0, 0 is returned without advancing through the log.
+func (r *ReplayRandomizer) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return 0
+	}
+	if r.next >= len(r.draws) {
+		r.err = errs.Newf("replay log exhausted after %d draws", len(r.draws))
+		return 0
+	}
+	d := r.draws[r.next]
+	switch {
+	case d.Sequence != r.next+1:
+		r.err = errs.Newf("replay draw %d has sequence %d", r.next+1, d.Sequence)
+	case d.N != n:
+		r.err = errs.Newf("replay draw %d was from %d values, but %d were requested", d.Sequence, d.N, n)
+	case d.Value < 0 || d.Value >= n:
+		r.err = errs.Newf("replay draw %d has value %d, which is outside 0 to %d", d.Sequence, d.Value, n-1)
+	default:
+		r.next++
+		return d.Value
+	}
+	return 0
+}
+
+// Remaining returns the number of values in the log that have not yet been returned.
+func (r *ReplayRandomizer) Remaining() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.draws) - r.next
+}
+
+// Err returns nil if every value requested so far matched the log, or an error describing the first that did not.
+func (r *ReplayRandomizer) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
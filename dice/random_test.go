@@ -0,0 +1,120 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestSeededRandomizer(t *testing.T) {
+	c := check.New(t)
+	a := dice.NewSeededRandomizer(42)
+	b := dice.NewSeededRandomizer(42)
+	other := dice.NewSeededRandomizer(43)
+	c.Equal(uint64(42), a.Seed())
+	var differs bool
+	counts := make([]int, 6)
+	for range 6000 {
+		v := a.Intn(6)
+		c.True(v >= 0 && v < 6, "value %d out of range", v)
+		c.Equal(v, b.Intn(6))
+		if v != other.Intn(6) {
+			differs = true
+		}
+		counts[v]++
+	}
+	c.True(differs, "different seeds should produce different sequences")
+	for face, count := range counts {
+		c.True(count > 800 && count < 1200, "face %d drawn %d times", face, count)
+	}
+	c.Equal(0, a.Intn(0))
+	c.Equal(0, a.Intn(-3))
+	c.Equal(0, a.Intn(1))
+
+	// The sequence is fixed by the seed, so it must never change.
+	s := dice.NewSeededRandomizer(1)
+	got := make([]int, 10)
+	for i := range got {
+		got[i] = s.Intn(20)
+	}
+	c.Equal([]int{19, 8, 2, 18, 16, 10, 14, 12, 14, 6}, got)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	c := check.New(t)
+	rec := dice.NewRecordingRandomizer(dice.NewSeededRandomizer(7))
+	r := newRoller(c, rec, false, false)
+	texts := []string{"3d6+2", "4d6kh3", "3d6!", "2d10!!>9", "5d10>=7dbl10f1", "4d6ro<1", "4dF", "d20r<2!p"}
+	expr, err := r.ParseExpression("2d8+1d6*2")
+	c.NoError(err)
+	results := make([]int, 0, len(texts)+1)
+	details := make([]string, 0, len(texts))
+	for _, text := range texts {
+		results = append(results, r.Roll(r.Parse(text)))
+		details = append(details, r.RollDetailed(r.Parse(text)).String())
+	}
+	results = append(results, expr.Roll())
+	draws := rec.Draws()
+	c.True(len(draws) > 0)
+	for i, d := range draws {
+		c.Equal(i+1, d.Sequence)
+		c.True(d.Value >= 0 && d.Value < d.N, "draw %d", d.Sequence)
+	}
+
+	replay := dice.NewReplayRandomizer(draws)
+	r = newRoller(c, replay, false, false)
+	expr, err = r.ParseExpression("2d8+1d6*2")
+	c.NoError(err)
+	for i, text := range texts {
+		c.Equal(results[i], r.Roll(r.Parse(text)), text)
+		c.Equal(details[i], r.RollDetailed(r.Parse(text)).String(), text)
+	}
+	c.Equal(results[len(texts)], expr.Roll())
+	c.NoError(replay.Err())
+	c.Equal(0, replay.Remaining())
+
+	// Running past the end of the log is reported.
+	c.Equal(0, replay.Intn(6))
+	c.HasError(replay.Err())
+
+	rec.Reset()
+	c.Equal(0, len(rec.Draws()))
+	rec.Intn(6)
+	c.Equal(1, rec.Draws()[0].Sequence)
+}
+
+func TestReplayMismatch(t *testing.T) {
+	c := check.New(t)
+	draws := []dice.Draw{{Sequence: 1, N: 6, Value: 5}, {Sequence: 2, N: 6, Value: 2}}
+
+	replay := dice.NewReplayRandomizer(draws)
+	c.Equal(5, replay.Intn(6))
+	c.Equal(0, replay.Intn(20)) // The log holds a draw from 6 values, not 20
+	c.HasError(replay.Err())
+	c.Equal(0, replay.Intn(6)) // Every draw after a mismatch is 0
+	c.Equal(1, replay.Remaining())
+
+	replay = dice.NewReplayRandomizer([]dice.Draw{{Sequence: 2, N: 6, Value: 1}})
+	c.Equal(0, replay.Intn(6))
+	c.HasError(replay.Err())
+
+	replay = dice.NewReplayRandomizer([]dice.Draw{{Sequence: 1, N: 6, Value: 6}})
+	c.Equal(0, replay.Intn(6))
+	c.HasError(replay.Err())
+
+	// The log is copied, so later changes to it do not affect the replay.
+	replay = dice.NewReplayRandomizer(draws)
+	draws[0].Value = 0
+	c.Equal(5, replay.Intn(6))
+	c.NoError(replay.Err())
+}
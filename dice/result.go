@@ -0,0 +1,270 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/richardwilkes/toolbox/v2/xrand"
+)
+
+// Result holds the details of a single roll of a Dice.
+type Result struct {
+	// Spec is the Dice as formatted by the Roller that rolled it.
+	Spec string
+	// Dice is the Dice that was rolled, after the Roller normalized it and applied its configuration.
+	Dice Dice
+	// Rolls holds one entry for each die, in the order the dice were rolled, with each die an Explode adds following the
+	// die that exploded. It is nil if the dice were Sampled.
+	Rolls      []DieRoll
+	Modifier   int
+	Multiplier int
+	// Sampled is true if the Dice had more dice than the Config's FastRollThreshold, so the total of the kept dice was
+	// sampled from its distribution rather than rolled one die at a time, leaving no individual dice to report.
+	Sampled bool
+	// Subtotal is the total of the kept dice plus the Modifier, before the Multiplier is applied.
+	Subtotal int
+	Total    int
+}
+
+// DieRoll holds the details of a single die within a Result.
+type DieRoll struct {
+	// Faces holds each face rolled for the die: the initial roll followed by one roll for each time it compounded or
+	// penetrated. A die that exploded by way of an Explode instead has an Added die following it in the Result.
+	Faces []int
+	// Digits holds the face read from each of the dice that make up a digit die, from the most significant, such as the
+	// 2 and 6 of a d66 that read 26. It is nil for any other die.
+	Digits []int
+	// Rerolled holds, for each entry in Faces, the faces rolled and then rolled again before it was kept. It is nil if
+	// the die was never rolled again.
+	Rerolled [][]int
+	// Total is the die's contribution to the result when it is kept, including any explosions. For Dice that count
+	// successes, it is the number of successes the die scored.
+	Total int
+	// Kept is true if the die contributes to the result and false if it was dropped.
+	Kept bool
+	// Highest is true if the die's initial roll was its highest face.
+	Highest bool
+	// Lowest is true if the die's initial roll was its lowest face.
+	Lowest bool
+	// Subtracted is true if the die is open-ended and its initial roll landed low, so each face rolled after it was
+	// subtracted rather than added.
+	Subtracted bool
+	// Added is true if the die was rolled because the die before it exploded, rather than being one of the Dice's own
+	// dice.
+	Added bool
+	// explodes is true if the die's face caused the next die in the Result to be Added.
+	explodes bool
+}
+
+// Successes returns the number of successes scored by the kept dice, not including the Modifier. It is only meaningful
+// for Dice that count successes.
+func (res *Result) Successes() int {
+	if res.Sampled {
+		return res.Subtotal - res.Modifier
+	}
+	var successes int
+	for i := range res.Rolls {
+		if res.Rolls[i].Kept {
+			successes += res.Rolls[i].Total
+		}
+	}
+	return successes
+}
+
+// Exploded returns true if the die exploded at least once.
+func (d *DieRoll) Exploded() bool {
+	return len(d.Faces) > 1 || d.explodes
+}
+
+// RollDetailed rolls the dice, just as Roll does, but returns the details of the roll rather than just its total. Both
+// draw the same random values from the Randomizer, so a deterministic Randomizer produces the same total from either.
+// Dice with more dice than the Config's FastRollThreshold are sampled, just as Roll samples them, so their Result is
+// Sampled and holds no individual dice.
+func (r *Roller) RollDetailed(dice Dice) *Result {
+	dice = r.prepare(dice)
+	res := &Result{
+		Spec:       dice.format(r.config().GURPSFormat),
+		Dice:       dice,
+		Modifier:   dice.Modifier,
+		Multiplier: dice.Multiplier,
+	}
+	res.Subtotal = dice.Modifier
+	switch {
+	case dice.Count == 0:
+	case r.samples(dice):
+		res.Sampled = true
+		res.Subtotal += r.sampleDice(dice)
+	default:
+		rnd := r.config().Randomizer
+		explosions := r.explosionLimit(dice)
+		rerolls := r.rerollLimit(dice)
+		faces := r.config().faces(dice.Faces)
+		lowest, highest := 1, dice.Sides
+		if faces != nil {
+			lowest, highest = slices.Min(faces), slices.Max(faces)
+		}
+		res.Rolls = make([]DieRoll, 0, dice.Count)
+		for range dice.Count {
+			if dice.Explode == Explode {
+				res.Rolls = appendAddedDice(res.Rolls, dice, rnd, explosions, rerolls)
+				continue
+			}
+			var roll DieRoll
+			roll.Total = rollDie(dice, faces, rnd, explosions, rerolls, &roll)
+			res.Rolls = append(res.Rolls, roll)
+		}
+		for i := range res.Rolls {
+			roll := &res.Rolls[i]
+			roll.Highest = roll.Faces[0] == highest
+			roll.Lowest = roll.Faces[0] == lowest
+		}
+		res.markKept()
+		for i := range res.Rolls {
+			if res.Rolls[i].Kept {
+				res.Subtotal += res.Rolls[i].Total
+			}
+		}
+	}
+	res.Total = res.Subtotal * res.Multiplier
+	return res
+}
+
+// appendAddedDice rolls a single die of Dice that Explode, just as rollDie does, and appends it to rolls, followed by
+// another die for each time it explodes, up to the given number of explosions. The extended rolls are returned.
+func appendAddedDice(rolls []DieRoll, dice Dice, rnd xrand.Randomizer, explosions, rerolls int) []DieRoll {
+	threshold := dice.explodesAt()
+	for i := 0; ; i++ {
+		roll := DieRoll{Added: i != 0}
+		value := rollKeptFace(dice, rnd, rerolls, &roll)
+		roll.Total = dice.score(value)
+		roll.explodes = i < explosions && value >= threshold
+		rolls = append(rolls, roll)
+		if !roll.explodes {
+			return rolls
+		}
+	}
+}
+
+// markKept marks the dice the Selection keeps. Among dice with equal totals, the one rolled earlier is treated as the
+// lower of the two.
+func (res *Result) markKept() {
+	order := make([]int, len(res.Rolls))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(res.Rolls[a].Total, res.Rolls[b].Total) })
+	from, to := res.Dice.keptRangeOf(len(res.Rolls))
+	for _, i := range order[from:to] {
+		res.Rolls[i].Kept = true
+	}
+}
+
+// String returns a human-readable description of the roll, such as "3d6+2 → [4, 1, 6] + 2 = 13". A Sampled roll shows
+// the total of its kept dice in place of each die, as in "20000d6 → [sampled 70012] + 2 = 70014". Dropped dice are
+// surrounded by "~~" and each face that caused a die to explode is followed by "!". Each die an Explode adds is shown
+// on its own, as in "[6!, 6!, 3]", while a compounding die shows every face rolled for it, as in "[6!+6!+3]", and a
+// penetrating die also shows the penalty subtracted from it, as in "[6!+6!+3-2]". A face that was rolled again is
+// followed by "r" and the roll that replaced it, as in "[1r5, 3]". A digit die shows the face read from each of its
+// dice, as in "d66 → [26(2/6)] = 26". An open-ended die whose initial roll landed low shows the faces subtracted from
+// it, as in "[3!-97!-45]". For Dice that count successes, each die that scored or lost successes is followed by the
+// number it scored, as in "4d10>=7f1! → [3, 8{1}, 10!{2}, 7{1}, 1{-1}] = 3".
+func (res *Result) String() string {
+	var buffer strings.Builder
+	buffer.WriteString(res.Spec)
+	buffer.WriteString(" → ")
+	hasDice := res.Sampled || len(res.Rolls) != 0
+	if hasDice {
+		buffer.WriteByte('[')
+		if res.Sampled {
+			buffer.WriteString("sampled ")
+			buffer.WriteString(strconv.Itoa(res.Subtotal - res.Modifier))
+		}
+		for i := range res.Rolls {
+			if i != 0 {
+				buffer.WriteString(", ")
+			}
+			res.Rolls[i].write(&buffer, res.Dice)
+		}
+		buffer.WriteByte(']')
+		switch {
+		case res.Modifier > 0:
+			buffer.WriteString(" + ")
+			buffer.WriteString(strconv.Itoa(res.Modifier))
+		case res.Modifier < 0:
+			buffer.WriteString(" - ")
+			buffer.WriteString(strconv.Itoa(-res.Modifier))
+		}
+	} else {
+		buffer.WriteString(strconv.Itoa(res.Modifier))
+	}
+	if res.Multiplier != 1 {
+		buffer.WriteString(" = ")
+		buffer.WriteString(strconv.Itoa(res.Subtotal))
+		buffer.WriteString(" x ")
+		buffer.WriteString(strconv.Itoa(res.Multiplier))
+	}
+	if hasDice || res.Multiplier != 1 {
+		buffer.WriteString(" = ")
+		buffer.WriteString(strconv.Itoa(res.Total))
+	}
+	return buffer.String()
+}
+
+func (d *DieRoll) write(buffer *strings.Builder, dice Dice) {
+	if !d.Kept {
+		buffer.WriteString("~~")
+	}
+	for i, face := range d.Faces {
+		if i != 0 {
+			if d.Subtracted {
+				buffer.WriteByte('-')
+			} else {
+				buffer.WriteByte('+')
+			}
+		}
+		if i < len(d.Rerolled) {
+			for _, discarded := range d.Rerolled[i] {
+				buffer.WriteString(strconv.Itoa(discarded))
+				buffer.WriteByte('r')
+			}
+		}
+		buffer.WriteString(strconv.Itoa(face))
+		if i == 0 && len(d.Digits) != 0 {
+			buffer.WriteByte('(')
+			for j, digit := range d.Digits {
+				if j != 0 {
+					buffer.WriteByte('/')
+				}
+				buffer.WriteString(strconv.Itoa(digit))
+			}
+			buffer.WriteByte(')')
+		}
+		if i < len(d.Faces)-1 || d.explodes {
+			buffer.WriteByte('!')
+		}
+	}
+	if dice.Explode == Penetrate && len(d.Faces) > 1 {
+		buffer.WriteByte('-')
+		buffer.WriteString(strconv.Itoa(len(d.Faces) - 1))
+	}
+	if dice.countsSuccesses() && d.Total != 0 {
+		buffer.WriteByte('{')
+		buffer.WriteString(strconv.Itoa(d.Total))
+		buffer.WriteByte('}')
+	}
+	if !d.Kept {
+		buffer.WriteString("~~")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestRollDetailed(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Total    int
+		GURPS    bool
+	}{
+		{"3d6+2", "3d6+2 → [4, 1, 6] + 2 = 13", 13, false},                          // 0
+		{"3d6+2", "3d+2 → [4, 1, 6] + 2 = 13", 13, true},                            // 1
+		{"3d6-2", "3d6-2 → [4, 1, 6] - 2 = 9", 9, false},                            // 2
+		{"2d6+1x2", "2d6+1x2 → [4, 1] + 1 = 6 x 2 = 12", 12, false},                 // 3
+		{"3d6kh2", "3d6kh2 → [4, ~~1~~, 6] = 10", 10, false},                        // 4
+		{"3d6dh1", "3d6dh1 → [4, 1, ~~6~~] = 5", 5, false},                          // 5
+		{"3d6!", "3d6! → [4, 1, 6!, 6!, 2] = 19", 19, false},                        // 6
+		{"3d6!p", "3d6!p → [4, 1, 6!+6!+2-2] = 17", 17, false},                      // 7
+		{"4d6!kl2", "4d6!kl2 → [~~4~~, 1, ~~6!~~, ~~6!~~, 2, ~~5~~] = 3", 3, false}, // 8
+		{"5", "5 → 5", 5, false},                                                    // 9
+		{"5x3", "5x3 → 5 = 5 x 3 = 15", 15, false},                                  // 10
+		{"2d1+1", "2d1+1 → [1, 1] + 1 = 3", 3, false},                               // 11
+		{"4d6!!kl2", "4d6!!kl2 → [4, 1, ~~6!+6!+2~~, ~~5~~] = 5", 5, false},         // 12
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		rnd := &sequenceRandomizer{values: []int{3, 0, 5, 5, 1, 4}}
+		r := newRoller(c, rnd, one.GURPS, false)
+		d := r.Parse(one.Text)
+		res := r.RollDetailed(d)
+		c.Equal(one.Expected, res.String(), desc)
+		c.Equal(one.Total, res.Total, desc)
+
+		// Roll draws exactly the same values, so it must reach the same total.
+		rnd.next = 0
+		c.Equal(res.Total, r.Roll(d), desc)
+	}
+}
+
+func TestRollDetailedDieFlags(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, &sequenceRandomizer{values: []int{5, 2, 0, 5, 3}}, false, false)
+	res := r.RollDetailed(r.Parse("3d6!kh2"))
+	c.Equal(5, len(res.Rolls))
+	c.Equal([]int{6}, res.Rolls[0].Faces)
+	c.True(res.Rolls[0].Exploded())
+	c.True(res.Rolls[0].Highest)
+	c.False(res.Rolls[0].Lowest)
+	c.False(res.Rolls[0].Added)
+	c.True(res.Rolls[0].Kept)
+	c.Equal([]int{3}, res.Rolls[1].Faces)
+	c.True(res.Rolls[1].Added)
+	c.False(res.Rolls[1].Exploded())
+	c.False(res.Rolls[1].Kept)
+	c.Equal([]int{1}, res.Rolls[2].Faces)
+	c.True(res.Rolls[2].Lowest)
+	c.False(res.Rolls[2].Added)
+	c.False(res.Rolls[2].Kept)
+	c.True(res.Rolls[3].Exploded())
+	c.True(res.Rolls[3].Kept)
+	c.Equal([]int{4}, res.Rolls[4].Faces)
+	c.True(res.Rolls[4].Added)
+	c.False(res.Rolls[4].Kept)
+	c.Equal(12, res.Total)
+
+	// The same rolls compounded stay within the dice that exploded.
+	r = newRoller(c, &sequenceRandomizer{values: []int{5, 2, 0, 5, 3}}, false, false)
+	res = r.RollDetailed(r.Parse("3d6!!kh2"))
+	c.Equal(3, len(res.Rolls))
+	c.Equal([]int{6, 3}, res.Rolls[0].Faces)
+	c.True(res.Rolls[0].Exploded())
+	c.True(res.Rolls[0].Kept)
+	c.Equal([]int{1}, res.Rolls[1].Faces)
+	c.False(res.Rolls[1].Kept)
+	c.Equal([]int{6, 4}, res.Rolls[2].Faces)
+	c.True(res.Rolls[2].Kept)
+	c.Equal(19, res.Total)
+
+	// Dice that tie are dropped in the order they were rolled.
+	r = newRoller(c, &sequenceRandomizer{values: []int{2, 2, 2}}, false, false)
+	res = r.RollDetailed(r.Parse("3d6dl1"))
+	c.False(res.Rolls[0].Kept)
+	c.True(res.Rolls[1].Kept)
+	c.True(res.Rolls[2].Kept)
+}
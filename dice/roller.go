@@ -12,9 +12,17 @@ package dice
 
 import (
 	"math"
+	"slices"
+	"strings"
+
+	"github.com/richardwilkes/toolbox/v2/xrand"
 )
 
-// Roller provides the ability to parse, roll, and manipulate dice.
+// Roller provides the ability to parse, roll, and manipulate dice. A Roller never changes once created, so it is safe
+// to share across goroutines, provided its Randomizer is. Every Randomizer in this package is, although concurrent
+// rolls then interleave their draws unpredictably; use Derive to give each goroutine a reproducible stream of its own.
+// The zero Roller uses the default Config, and may instead be given one by UnmarshalJSON or UnmarshalYAML before it is
+// shared.
 type Roller struct {
 	cfg *Config
 }
@@ -46,8 +54,39 @@ func (r *Roller) Format(dice Dice) string {
 
 // Parse a dice string in the form 3d6+1x2 and turns it into a Dice.
 func (r *Roller) Parse(spec string) Dice {
-	cfg := r.config()
-	return r.Normalize(parseDice(spec, cfg.MaxCount, cfg.MaxSides, cfg.MaxModifier, cfg.MaxMultiplier))
+	return r.Normalize(parseDice(spec, r.config()))
+}
+
+// ParseStrict parses a dice string in the form 3d6+1x2 and turns it into a Dice, just as Parse does. Unlike Parse,
+// which ignores anything it does not recognize, caps any value that exceeds the Config's limits and adjusts any value
+// that does not suit the dice, such as the 9 in 3d6>=9, ParseStrict returns a *ParseError describing the first such
+// problem.
+func (r *Roller) ParseStrict(spec string) (Dice, error) {
+	dice, err := parseDiceStrict(spec, r.config())
+	if err != nil {
+		return Dice{}, newParseError(spec, err.pos, err.reason)
+	}
+	return r.Normalize(dice), nil
+}
+
+// ReplaceDice returns a copy of the text with each Dice specification found by DicePositions replaced by the text
+// returned from calling replace with the parsed Dice and the specification as it appeared in the text. For example,
+// replace might roll the Dice and return the result, or wrap the specification in markup. Custom and digit dice are
+// recognized as this Roller's Config describes them.
+func (r *Roller) ReplaceDice(text string, replace func(dice Dice, spec string) string) string {
+	var buffer strings.Builder
+	last := 0
+	for start, end := range dicePositions(text, r.config()) {
+		buffer.WriteString(text[last:start])
+		spec := text[start:end]
+		buffer.WriteString(replace(r.Parse(spec), spec))
+		last = end
+	}
+	if last == 0 {
+		return text
+	}
+	buffer.WriteString(text[last:])
+	return buffer.String()
 }
 
 func nextChar(in string, inPos int) (ch byte, outPos int) {
@@ -80,23 +119,254 @@ func extractValue(in string, inPos, maxValue int) (value, outPos int) {
 
 // Roll the dice.
 func (r *Roller) Roll(dice Dice) int {
-	dice = r.prepare(dice)
+	return r.rollPrepared(r.prepare(dice))
+}
+
+// rollPrepared rolls the prepared Dice.
+func (r *Roller) rollPrepared(dice Dice) int {
 	result := dice.Modifier
-	switch {
-	case dice.Sides > 1:
-		cfg := r.config()
+	if dice.Count > 0 {
+		result += r.rollDice(dice)
+	}
+	return result * dice.Multiplier
+}
+
+// rollDice rolls the dice of a prepared Dice with at least one die, returning the total of the dice it keeps.
+func (r *Roller) rollDice(dice Dice) int {
+	if r.samples(dice) {
+		return r.sampleDice(dice)
+	}
+	return r.rollEachDie(dice)
+}
+
+// samples returns true if the prepared Dice has more dice than the Config's FastRollThreshold, so its total is sampled
+// rather than rolled one die at a time.
+func (r *Roller) samples(dice Dice) bool {
+	threshold := r.config().FastRollThreshold
+	return threshold > 0 && dice.Count > threshold
+}
+
+// rollEachDie rolls each die of a prepared Dice with at least one die, returning the total of the dice it keeps.
+func (r *Roller) rollEachDie(dice Dice) int {
+	rnd := r.config().Randomizer
+	explosions := r.explosionLimit(dice)
+	rerolls := r.rerollLimit(dice)
+	faces := r.config().faces(dice.Faces)
+	if dice.Selection == SelectAll {
+		if dice.isPlain() && dice.Sides == 1 {
+			return dice.Count
+		}
+		var total int
 		for range dice.Count {
-			result += 1 + cfg.Randomizer.Intn(dice.Sides)
+			total += rollDie(dice, faces, rnd, explosions, rerolls, nil)
 		}
-	case dice.Sides == 1:
-		result += dice.Count
+		return total
 	}
-	return result * dice.Multiplier
+	rolls := make([]int, 0, dice.Count)
+	for range dice.Count {
+		if dice.addsDice() {
+			rolls = rollAddedDice(dice, rnd, explosions, rerolls, rolls)
+		} else {
+			rolls = append(rolls, rollDie(dice, faces, rnd, explosions, rerolls, nil))
+		}
+	}
+	slices.Sort(rolls)
+	from, to := dice.keptRangeOf(len(rolls))
+	var total int
+	for _, v := range rolls[from:to] {
+		total += v
+	}
+	return total
+}
+
+// rollDie rolls a single die of the Dice, following up to the given number of explosions and rerolling each roll up to
+// the given number of times, and returns its total, or when counting successes, the number of successes it scored. The
+// faces of a custom die must be provided, and nil otherwise. If roll is not nil, each face rolled is recorded in it.
+func rollDie(dice Dice, faces []int, rnd xrand.Randomizer, explosions, rerolls int, roll *DieRoll) int {
+	if faces != nil {
+		// Custom dice neither explode nor reroll.
+		var value int
+		if _, ok := digitDice[dice.Faces]; ok {
+			value = rollDigitDie(dice.Faces, rnd, roll)
+		} else {
+			value = faces[rollFace(len(faces), rnd)-1]
+		}
+		if roll != nil {
+			roll.Faces = append(roll.Faces, value)
+		}
+		return value
+	}
+	if dice.OpenEnded != NoOpenEnded {
+		return rollOpenEnded(dice, rnd, explosions, roll)
+	}
+	value := rollKeptFace(dice, rnd, rerolls, roll)
+	total := dice.score(value)
+	if dice.Explode != NoExplode {
+		threshold := dice.explodesAt()
+		for range explosions {
+			if value < threshold {
+				break
+			}
+			value = rollKeptFace(dice, rnd, rerolls, roll)
+			if dice.Explode == Penetrate {
+				total += dice.score(value - 1)
+			} else {
+				total += dice.score(value)
+			}
+		}
+	}
+	return total
+}
+
+// rollAddedDice rolls a single die of Dice that Explode, rerolling each roll up to the given number of times, and
+// appends its face to rolls, followed by the face of another die for each time it explodes, up to the given number of
+// explosions. The extended rolls are returned.
+func rollAddedDice(dice Dice, rnd xrand.Randomizer, explosions, rerolls int, rolls []int) []int {
+	value := rollKeptFace(dice, rnd, rerolls, nil)
+	rolls = append(rolls, value)
+	threshold := dice.explodesAt()
+	for range explosions {
+		if value < threshold {
+			break
+		}
+		value = rollKeptFace(dice, rnd, rerolls, nil)
+		rolls = append(rolls, value)
+	}
+	return rolls
+}
+
+// rollOpenEnded rolls a single open-ended die of the Dice, rolling it again up to the given number of times, and
+// returns its total. If roll is not nil, each face rolled is recorded in it, and it is marked as Subtracted when the
+// die's first roll landed low.
+func rollOpenEnded(dice Dice, rnd xrand.Randomizer, explosions int, roll *DieRoll) int {
+	low, high := dice.openEndedThresholds()
+	value := rollFace(dice.Sides, rnd)
+	if roll != nil {
+		roll.Faces = append(roll.Faces, value)
+	}
+	total := value
+	sign := 1
+	switch {
+	case value >= high && dice.OpenEnded != OpenEndedLow:
+	case value <= low && dice.OpenEnded != OpenEndedHigh:
+		sign = -1
+		if roll != nil {
+			roll.Subtracted = true
+		}
+	default:
+		return total
+	}
+	for range explosions {
+		value = rollFace(dice.Sides, rnd)
+		if roll != nil {
+			roll.Faces = append(roll.Faces, value)
+		}
+		total += sign * value
+		if value < high {
+			break
+		}
+	}
+	return total
+}
+
+// rollKeptFace returns a random face of a die of the Dice, rolling it again up to the given number of times while it
+// lands at or below the reroll threshold. If roll is not nil, the face is appended to its Faces and the faces rolled
+// again are recorded in its Rerolled.
+func rollKeptFace(dice Dice, rnd xrand.Randomizer, rerolls int, roll *DieRoll) int {
+	value := rollFace(dice.Sides, rnd)
+	var discarded []int
+	for range rerolls {
+		if value > dice.RerollThreshold {
+			break
+		}
+		if roll != nil {
+			discarded = append(discarded, value)
+		}
+		value = rollFace(dice.Sides, rnd)
+	}
+	if roll != nil {
+		if discarded != nil && roll.Rerolled == nil {
+			roll.Rerolled = make([][]int, len(roll.Faces), cap(roll.Faces))
+		}
+		if roll.Rerolled != nil {
+			roll.Rerolled = append(roll.Rerolled, discarded)
+		}
+		roll.Faces = append(roll.Faces, value)
+	}
+	return value
+}
+
+// rollFace returns a random face of a die with the given number of sides. A single-sided die always rolls a 1, so it
+// does not consume a random value.
+func rollFace(sides int, rnd xrand.Randomizer) int {
+	if sides == 1 {
+		return 1
+	}
+	return 1 + rnd.Intn(sides)
+}
+
+// rerollLimit returns the most times a single roll of a die of the prepared Dice may be rolled again.
+func (r *Roller) rerollLimit(dice Dice) int {
+	switch dice.Reroll {
+	case RerollOnce:
+		return min(1, r.config().MaxRerolls)
+	case RerollUntil:
+		return r.config().MaxRerolls
+	default:
+		return 0
+	}
+}
+
+// explosionLimit returns the most times a single die of the prepared Dice may explode: the configured MaxExplosions,
+// reduced if necessary so that even a roll in which every kept die explodes that many times on its highest face cannot
+// overflow an int once the modifier and multiplier are applied. The Config's overflow checks already guarantee that a
+// roll without any explosions fits, so the limit is never negative.
+func (r *Roller) explosionLimit(dice Dice) int {
+	if dice.Explode == NoExplode && dice.OpenEnded == NoOpenEnded {
+		return 0
+	}
+	limit := r.config().MaxExplosions
+	first := dice.Sides  // The most the initial roll can add
+	growth := dice.Sides // The most a single explosion can add
+	if dice.Explode == Penetrate {
+		growth--
+	}
+	modifier := max(dice.Modifier, 0)
+	if dice.OpenEnded != NoOpenEnded {
+		// Rolls that land low are subtracted, so the result may grow in either direction.
+		modifier = max(dice.Modifier, -dice.Modifier)
+	}
+	if dice.countsSuccesses() {
+		// Each roll scores at most two successes or takes one away, so the result may grow in either direction.
+		first = 2
+		growth = 2
+		modifier = max(dice.Modifier, -dice.Modifier)
+	}
+	kept := dice.kept()
+	if dice.addsDice() {
+		// Every die rolled may be kept, and there may be as many as one for each roll of each die.
+		kept = dice.Count
+	}
+	if growth < 1 || kept < 1 {
+		return limit
+	}
+	room := (math.MaxInt/dice.Multiplier-modifier)/kept - first
+	return min(limit, room/growth)
 }
 
 // Normalize the provided Dice, ensuring all values are within permitted ranges, and return the modified copy.
 func (r *Roller) Normalize(dice Dice) Dice {
 	cfg := r.config()
+	if dice.Faces != "" {
+		if faces := cfg.faces(dice.Faces); faces != nil {
+			dice.Sides = len(faces)
+			if strings.HasPrefix(dice.Faces, "{") {
+				dice.Faces = formatFaceList(faces)
+			}
+		} else {
+			dice.Faces = ""
+		}
+	}
 	dice.Count = min(max(dice.Count, 0), cfg.MaxCount)
 	dice.Sides = min(max(dice.Sides, 0), cfg.MaxSides)
 	dice.Modifier = min(max(dice.Modifier, -cfg.MaxModifier), cfg.MaxModifier)
@@ -106,9 +376,13 @@ func (r *Roller) Normalize(dice Dice) Dice {
 
 // ApplyExtraDiceFromModifiers returns the Dice as if the ExtraDiceFromModifiers configuration option had been applied
 // to its components. No more dice are added than the configured MaxCount allows: once the count would reach MaxCount,
-// any modifier that would have converted into further dice is left in the modifier instead.
+// any modifier that would have converted into further dice is left in the modifier instead. Dice that keep or drop
+// some of their dice are returned unconverted, since adding dice to them would change which dice are kept.
 func (r *Roller) ApplyExtraDiceFromModifiers(dice Dice) Dice {
 	dice = r.Normalize(dice)
+	if !dice.isPlain() {
+		return dice
+	}
 	var adjustment int
 	adjustment, dice.Modifier = computeExtraDice(dice.Sides, dice.Modifier, r.config().MaxCount-dice.Count)
 	dice.Count += adjustment
@@ -131,8 +405,9 @@ func (r *Roller) IsEquivalent(d1, d2 Dice) bool {
 func (r *Roller) Minimum(dice Dice) int {
 	dice = r.prepare(dice)
 	result := dice.Modifier
-	if dice.Sides > 0 {
-		result += dice.Count
+	if dice.Count > 0 {
+		low, _ := r.diceRange(dice)
+		result += low
 	}
 	return result * dice.Multiplier
 }
@@ -141,23 +416,92 @@ func (r *Roller) Minimum(dice Dice) int {
 func (r *Roller) Average(dice Dice) int {
 	dice = r.prepare(dice)
 	result := dice.Modifier
-	if dice.Count > 0 && dice.Sides > 0 {
-		result += dice.Count * (dice.Sides + 1) / 2
+	if dice.Count > 0 {
+		if dice.isPlain() {
+			result += dice.Count * (dice.Sides + 1) / 2
+		} else {
+			result += floorMean(r.diceMean(dice))
+		}
 	}
 	return result * dice.Multiplier
 }
 
+// mean returns the exact average result of the Dice.
+func (r *Roller) mean(dice Dice) float64 {
+	dice = r.prepare(dice)
+	result := float64(dice.Modifier)
+	if dice.Count > 0 {
+		result += r.diceMean(dice)
+	}
+	return result * float64(dice.Multiplier)
+}
+
+// dieRange returns the lowest and highest total a single die of the prepared Dice can produce.
+func (r *Roller) dieRange(dice Dice) (low, high int) {
+	if faces := r.config().faces(dice.Faces); faces != nil {
+		return slices.Min(faces), slices.Max(faces)
+	}
+	return dieRange(dice, r.explosionLimit(dice))
+}
+
+// diceRange returns the lowest and highest total of the dice the prepared Dice keeps, ignoring its modifier and
+// multiplier.
+func (r *Roller) diceRange(dice Dice) (low, high int) {
+	if dice.addsDice() {
+		return addedDiceRange(dice, r.explosionLimit(dice))
+	}
+	low, high = r.dieRange(dice)
+	kept := dice.kept()
+	return kept * low, kept * high
+}
+
+// diceMean returns the average total of the dice the prepared Dice keeps, ignoring its modifier and multiplier. The
+// Dice must have at least one die.
+func (r *Roller) diceMean(dice Dice) float64 {
+	faces := r.config().faces(dice.Faces)
+	if faces == nil {
+		return diceMean(dice, r.explosionLimit(dice), r.rerollLimit(dice))
+	}
+	if dice.Selection != SelectAll {
+		if die := facesDistribution(faces); die != nil {
+			return keptMean(dice, die)
+		}
+	}
+	return float64(dice.kept()) * facesMean(faces)
+}
+
+// dieDistribution returns the probability mass function of the total of a single die of the prepared Dice, or nil if
+// it would be too large to build.
+func (r *Roller) dieDistribution(dice Dice) *pmf {
+	if faces := r.config().faces(dice.Faces); faces != nil {
+		return facesDistribution(faces)
+	}
+	return dieDistribution(dice, r.explosionLimit(dice), r.rerollLimit(dice))
+}
+
 // Maximum returns the maximum result.
 func (r *Roller) Maximum(dice Dice) int {
 	dice = r.prepare(dice)
 	result := dice.Modifier
-	result += dice.Count * dice.Sides
+	if dice.Count > 0 {
+		_, high := r.diceRange(dice)
+		result += high
+	}
 	return result * dice.Multiplier
 }
 
 // PoolProbability return the probability that at least one die will be equal to or greater than the target value.
 func (r *Roller) PoolProbability(dice Dice, target int) float64 {
 	dice = r.Normalize(dice)
+	if faces := r.config().faces(dice.Faces); faces != nil && dice.Count > 0 {
+		var hits int
+		for _, face := range faces {
+			if face >= target {
+				hits++
+			}
+		}
+		return 1 - math.Pow(1-float64(hits)/float64(len(faces)), float64(dice.Count))
+	}
 	if dice.Count < 1 || dice.Sides < 1 || dice.Sides < target {
 		return 0
 	}
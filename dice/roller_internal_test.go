@@ -10,7 +10,9 @@
 package dice
 
 import (
+	"fmt"
 	"math"
+	"slices"
 	"strconv"
 	"testing"
 
@@ -42,3 +44,269 @@ func TestExtractValueCapsWithoutOverflow(t *testing.T) {
 		c.True(got >= 0, "case %d produced a negative value %d", i, got)
 	}
 }
+
+func TestSelectedMeanMatchesEnumeration(t *testing.T) {
+	c := check.New(t)
+	for _, one := range []struct {
+		count, sides int
+	}{
+		{1, 6}, {2, 6}, {3, 6}, {4, 6}, {2, 20}, {3, 8}, {5, 4}, {2, 2},
+	} {
+		for selection := KeepHighest; selection <= DropLowest; selection++ {
+			for n := 0; n <= one.count; n++ {
+				d := Dice{Count: one.count, Sides: one.sides, Multiplier: 1, Selection: selection, SelectCount: n}
+				d = d.normalize()
+				if d.Selection == SelectAll {
+					continue
+				}
+				want := enumerateSelectedMean(d)
+				got := selectedMean(d, uniformDie(d.Sides))
+				c.True(math.Abs(want-got) < 1e-9, "%s: want %v, got %v", d.format(false), want, got)
+			}
+		}
+	}
+}
+
+// enumerateSelectedMean computes the expected kept total by visiting every possible roll.
+func enumerateSelectedMean(d Dice) float64 {
+	rolls := make([]int, d.Count)
+	sorted := make([]int, d.Count)
+	from, to := d.keptRange()
+	var total, outcomes float64
+	for {
+		copy(sorted, rolls)
+		slices.Sort(sorted)
+		for _, v := range sorted[from:to] {
+			total += float64(v + 1)
+		}
+		outcomes++
+		i := 0
+		for i < len(rolls) {
+			rolls[i]++
+			if rolls[i] < d.Sides {
+				break
+			}
+			rolls[i] = 0
+			i++
+		}
+		if i == len(rolls) {
+			return total / outcomes
+		}
+	}
+}
+
+func TestSelectedMeanLargePools(t *testing.T) {
+	c := check.New(t)
+	// Keeping the highest half of a large pool of large dice must stay fast and land near the analytic expectation of
+	// the upper half of a uniform distribution: about 3/4 of the sides per kept die.
+	d := Dice{Count: 100_000, Sides: 999_999, Multiplier: 1, Selection: KeepHighest, SelectCount: 50_000}
+	got := selectedMean(d, uniformDie(d.Sides)) / 50_000
+	c.True(math.Abs(got-750_000) < 1_000, "got %v", got)
+}
+
+func TestDieDistributionMatchesMean(t *testing.T) {
+	c := check.New(t)
+	for _, one := range []struct {
+		text       string
+		explosions int
+		rerolls    int
+	}{
+		{"d6", 100, 0}, {"d6!", 100, 0}, {"d6!!", 3, 0}, {"d6!p", 100, 0}, {"d10!>8", 100, 0}, {"d6!>1", 5, 0},
+		{"d2!p>1", 7, 0}, {"d1!", 4, 0}, {"d20!", 0, 0}, {"d6ro<2", 0, 1}, {"d6r<3!", 100, 100}, {"d10r<9!p>8", 100, 3},
+		{"d4r<4", 0, 5}, {"d6r!!", 100, 0},
+	} {
+		d := parseDice(one.text, DefaultConfig())
+		dist := dieDistribution(d, one.explosions, one.rerolls)
+		c.NotNil(dist, one.text)
+		var total, mean float64
+		for i, p := range dist.probs {
+			total += p
+			mean += p * float64(dist.lo+i)
+		}
+		low, high := dieRange(d, one.explosions)
+		distLow, distHigh := dist.bounds()
+		c.True(math.Abs(total-1) < 1e-12, "%s: probabilities sum to %v", one.text, total)
+		c.True(math.Abs(mean-dieMean(d, one.explosions, one.rerolls)) < 1e-9, "%s: mean %v, want %v", one.text, mean,
+			dieMean(d, one.explosions, one.rerolls))
+		c.True(distLow >= low && distHigh <= high, "%s: [%d,%d] outside [%d,%d]", one.text, distLow, distHigh, low, high)
+	}
+}
+
+func TestDieMomentsMatchDistribution(t *testing.T) {
+	c := check.New(t)
+	for _, one := range []struct {
+		text       string
+		explosions int
+		rerolls    int
+	}{
+		{"d6", 100, 0}, {"d6!", 100, 0}, {"d6!!", 3, 0}, {"d6!p", 100, 0}, {"d10!>8", 100, 0}, {"d6!>1", 5, 0},
+		{"d2!p>1", 7, 0}, {"d1!", 4, 0}, {"d20!", 0, 0}, {"d6ro<2", 0, 1}, {"d6r<3!", 100, 100}, {"d10r<9!p>8", 100, 3},
+		{"d4r<4", 0, 5}, {"d6r!!", 100, 0}, {"d100oe", 100, 0}, {"d20oeh", 3, 0}, {"d20oel", 2, 0}, {"d10oe", 0, 0},
+		{"d6>=4!", 100, 0}, {"d6>=4dbl6f1!p", 5, 0}, {"d6>=5f1r<3!", 100, 2}, {"d10>=7", 0, 0},
+	} {
+		d := parseDice(one.text, DefaultConfig())
+		dist := dieDistribution(d, one.explosions, one.rerolls)
+		c.NotNil(dist, one.text)
+		want := dist.mean()
+		var wantVariance float64
+		for i, p := range dist.probs {
+			delta := float64(dist.lo+i) - want
+			wantVariance += p * delta * delta
+		}
+		mean, variance := dieMoments(d, one.explosions, one.rerolls)
+		c.True(math.Abs(mean-want) < 1e-9, "%s: mean %v, want %v", one.text, mean, want)
+		c.True(math.Abs(variance-wantVariance) < 1e-7*max(wantVariance, 1), "%s: variance %v, want %v", one.text,
+			variance, wantVariance)
+	}
+}
+
+func TestSuccessDistributionMatchesEnumeration(t *testing.T) {
+	c := check.New(t)
+	r, err := NewRoller(DefaultConfig())
+	c.NoError(err)
+	for _, text := range []string{
+		"d6>=4!", "d6>=4dbl6f1!", "d6>=5f2!p", "d4>=3!>3", "d6>=2f1!>1", "d5>=3dbl4f1!p>4", "d6>=4ro<2!",
+		"d6>=5f1r<3!p", "d4>=4r<3",
+	} {
+		d := r.Parse(text)
+		for explosions := range 4 {
+			for rerolls := range 3 {
+				desc := fmt.Sprintf("%s with %d explosions and %d rerolls", text, explosions, rerolls)
+				want := make(map[int]float64)
+				enumerateSuccesses(d, explosions, rerolls, true, 0, 1, want)
+				dist := successDistribution(d, explosions, rerolls)
+				var mean float64
+				low, high := math.MaxInt, math.MinInt
+				for v, p := range want {
+					c.True(math.Abs(p-pmfAt(dist, v)) < 1e-12, "%s: P(%d)", desc, v)
+					mean += float64(v) * p
+					low = min(low, v)
+					high = max(high, v)
+				}
+				c.True(math.Abs(mean-successMean(d, explosions, rerolls)) < 1e-12, "%s: mean", desc)
+				gotLow, gotHigh := successRange(d, explosions)
+				c.Equal(low, gotLow, "%s: low", desc)
+				c.Equal(high, gotHigh, "%s: high", desc)
+			}
+		}
+	}
+}
+
+func TestAddedDiceDistributionMatchesEnumeration(t *testing.T) {
+	c := check.New(t)
+	r, err := NewRoller(DefaultConfig())
+	c.NoError(err)
+	for _, text := range []string{
+		"2d6!kl1", "3d4!kh2", "2d6!>5dl1", "3d3!>2dh1", "2d4r<2!kh1", "2d3!>1kl2", "d6!kh1", "2d4!dh3", "2d4!>3kh3",
+		"3d2!kl1",
+	} {
+		d := r.Parse(text)
+		c.True(d.addsDice(), text)
+		// Keep the number of rolls to follow small, as every combination of them is visited.
+		rerolls := min(r.rerollLimit(d), 1)
+		for explosions := range 4 {
+			desc := fmt.Sprintf("%s with %d explosions", text, explosions)
+			want := make(map[int]float64)
+			enumerateAddedDice(d, explosions, rerolls, nil, 1, want)
+			added := newAddedDice(d, explosions, rerolls)
+			dist := added.distribution()
+			c.NotNil(dist, desc)
+			var mean float64
+			for v, p := range want {
+				c.True(math.Abs(p-pmfAt(dist, v)) < 1e-12, "%s: P(%d)", desc, v)
+				mean += float64(v) * p
+			}
+			low, high := dist.bounds()
+			for v := low; v <= high; v++ {
+				_, ok := want[v]
+				c.True(ok || pmfAt(dist, v) < 1e-12, "%s: P(%d) should be 0", desc, v)
+			}
+			c.True(math.Abs(mean-added.mean()) < 1e-9, "%s: mean", desc)
+		}
+	}
+}
+
+// enumerateAddedDice adds the chance of every kept total of the Dice to want, by following every face of every roll of
+// each die and of the dice its explosions add.
+func enumerateAddedDice(d Dice, explosions, rerolls int, rolled []int, chance float64, want map[int]float64) {
+	dice := 0
+	for _, face := range rolled {
+		if face < 0 {
+			dice++
+		}
+	}
+	if dice == d.Count {
+		faces := make([]int, 0, len(rolled))
+		for _, face := range rolled {
+			if face < 0 {
+				face = -face
+			}
+			faces = append(faces, face)
+		}
+		slices.Sort(faces)
+		from, to := d.keptRangeOf(len(faces))
+		var total int
+		for _, face := range faces[from:to] {
+			total += face
+		}
+		want[total] += chance
+		return
+	}
+	// Each die's first face is recorded as negative, so the dice rolled so far can be counted.
+	var chain func(rolled []int, left int, first bool, chance float64)
+	chain = func(rolled []int, left int, first bool, chance float64) {
+		enumerateFaces(d, rerolls, chance, func(face int, p float64) {
+			next := slices.Clone(rolled)
+			if first {
+				next = append(next, -face)
+			} else {
+				next = append(next, face)
+			}
+			if left > 0 && face >= d.explodesAt() {
+				chain(next, left-1, false, p)
+			} else {
+				enumerateAddedDice(d, explosions, rerolls, next, p, want)
+			}
+		})
+	}
+	chain(rolled, explosions, true, chance)
+}
+
+// enumerateSuccesses adds the chance of every number of successes a single die can score to want, by following every
+// face of every roll.
+func enumerateSuccesses(d Dice, explosions, rerolls int, first bool, successes int, chance float64,
+	want map[int]float64,
+) {
+	enumerateFaces(d, rerolls, chance, func(face int, p float64) {
+		value := face
+		if !first && d.Explode == Penetrate {
+			value--
+		}
+		total := successes + d.score(value)
+		if d.Explode != NoExplode && explosions > 0 && face >= d.explodesAt() {
+			enumerateSuccesses(d, explosions-1, rerolls, false, total, p, want)
+		} else {
+			want[total] += p
+		}
+	})
+}
+
+// enumerateFaces calls visit with every face a single roll of a die can keep, along with the chance of keeping it, by
+// following every reroll.
+func enumerateFaces(d Dice, rerolls int, chance float64, visit func(face int, p float64)) {
+	for face := 1; face <= d.Sides; face++ {
+		p := chance / float64(d.Sides)
+		if d.Reroll != NoReroll && rerolls > 0 && face <= d.RerollThreshold {
+			enumerateFaces(d, rerolls-1, p, visit)
+		} else {
+			visit(face, p)
+		}
+	}
+}
+
+func pmfAt(d *pmf, v int) float64 {
+	if i := v - d.lo; i >= 0 && i < len(d.probs) {
+		return d.probs[i]
+	}
+	return 0
+}
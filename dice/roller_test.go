@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"strconv"
 	"testing"
 	"time"
 
@@ -282,7 +283,11 @@ func (topFaceRandomizer) Intn(n int) int {
 func TestRollTerminatesOnHugeCount(t *testing.T) {
 	c := check.New(t)
 	r := newRoller(c, nil, false, false)
-	rd := newRoller(c, topFaceRandomizer{}, false, false)
+	cfg := dice.DefaultConfig()
+	cfg.Randomizer = topFaceRandomizer{}
+	cfg.FastRollThreshold = 0 // Roll every die, rather than sampling their total
+	rd, err := dice.NewRoller(cfg)
+	c.NoError(err)
 	// Regression: a roll iterates Count times, so Count must be clamped to dice.MaxValue before the loop runs;
 	// otherwise an enormous count is an effective hang. Cover both the parsed spec (extractValue caps the number) and a
 	// field set directly to math.MaxInt, which bypasses the parser's cap and relies solely on the clamp inside the
@@ -579,3 +584,398 @@ func TestExtractedSpanIsCanonical(t *testing.T) {
 		c.Equal(span, r.Format(r.Parse(span)), text)
 	}
 }
+
+func TestDicePositions(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text  string
+		Specs []string
+	}{
+		{"deals 2d6 fire plus 1d4 cold", []string{"2d6", "1d4"}}, // 0
+		{"Just text", nil},     // 1
+		{"d6", []string{"d6"}}, // 2
+		{"2d6+2, d8x2 and 3d", []string{"2d6+2", "d8x2", "3d"}},                       // 3
+		{"13 years later, roll 2d6", []string{"2d6"}},                                 // 4 - a bare number followed by prose is ignored
+		{"roll 2d6 plus 5", []string{"2d6", "5"}},                                     // 5 - a final bare number is reported
+		{"roll 2d6 then read 5", []string{"2d6", "5"}},                                // 6 - the 'd' of "read" is not a die marker
+		{"roll 2d6 then d 5", []string{"2d6"}},                                        // 7 - a discarded 'd' suppresses a bare number
+		{"4d6kh3 for each stat", []string{"4d6kh3"}},                                  // 8 - modifiers are part of the spec
+		{"(2d6) or [1d8]", []string{"2d6", "1d8"}},                                    // 9
+		{"roll 3d6+ 2 or 2d6", []string{"3d6", "2d6"}},                                // 10
+		{"d6d8", []string{"d6"}},                                                      // 11
+		{"5 5", []string{"5"}},                                                        // 12
+		{"1d6/1d4", []string{"1d6", "1d4"}},                                           // 13
+		{"hits for 2d6 fire damage, 1d4 cold", []string{"2d6", "1d4"}},                // 14
+		{"roll 4d6kl3, 4d6dh1 or 4d6dl1 now", []string{"4d6kl3", "4d6dh1", "4d6dl1"}}, // 15
+		{"roll 2d6! then 2d6!! then 2d6!p", []string{"2d6!", "2d6!!", "2d6!p"}},       // 16
+		{"roll 2d6r1 or 2d6ro<2 again", []string{"2d6r1", "2d6ro<2"}},                 // 17
+		{"fate 4dF now", []string{"4dF"}},                                             // 18
+		{"roll 2d{1,3,5}+1 for luck", []string{"2d{1,3,5}+1"}},                        // 19
+		{"roll 3d6>=5 or 2d10!>9+2x2 now", []string{"3d6>=5", "2d10!>9+2x2"}},         // 20
+		{"roll 4d6kh3more", []string{"4d6"}},                                          // 21 - a modifier running into a word is not a modifier
+		{"fate 4dFox", []string{"4d"}},                                                // 22
+		{"roll d66 now", []string{"d66"}},                                             // 23 - a 66-sided die without DigitDice
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		var specs []string
+		for start, end := range dice.DicePositions(one.Text) {
+			specs = append(specs, one.Text[start:end])
+		}
+		c.Equal(one.Specs, specs, desc)
+		// The first position always agrees with ExtractDicePosition.
+		start, end := dice.ExtractDicePosition(one.Text)
+		if len(specs) == 0 {
+			c.Equal(-1, start, desc)
+		} else {
+			c.Equal(one.Specs[0], one.Text[start:end], desc)
+		}
+	}
+
+	// The Roller's digit dice are recognized when replacing dice.
+	cfg := dice.DefaultConfig()
+	cfg.DigitDice = true
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	var specs []string
+	r.ReplaceDice("roll d66 then d% or 2d666 now", func(_ dice.Dice, spec string) string {
+		specs = append(specs, spec)
+		return spec
+	})
+	c.Equal([]string{"d66", "d%", "2d666"}, specs)
+
+	// Stopping early ends the iteration.
+	var count int
+	for range dice.DicePositions("1d4 2d4 3d4") {
+		count++
+		break
+	}
+	c.Equal(1, count)
+}
+
+func TestReplaceDice(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, topFaceRandomizer{}, false, false)
+	rollInline := func(d dice.Dice, _ string) string { return strconv.Itoa(r.Roll(d)) }
+	c.Equal("deals 12 fire plus 4 cold", r.ReplaceDice("deals 2d6 fire plus 1d4 cold", rollInline))
+	c.Equal("no dice here", r.ReplaceDice("no dice here", rollInline))
+	c.Equal("20", r.ReplaceDice("d20", rollInline))
+
+	c.Equal("roll 18 for stats", r.ReplaceDice("roll 4d6kh3 for stats", rollInline))
+	c.Equal("fate 4 now", r.ReplaceDice("fate 4dF now", rollInline))
+	c.Equal("roll [2d6!] or [2d6ro<2]", r.ReplaceDice("roll 2d6! or 2d6ro<2", func(d dice.Dice, _ string) string {
+		return "[" + r.Format(d) + "]"
+	}))
+
+	markup := func(d dice.Dice, spec string) string { return "<dice spec=\"" + r.Format(d) + "\">" + spec + "</dice>" }
+	c.Equal(`read <dice spec="2d6+1">2d6+1</dice>, then <dice spec="3d6">3D6</dice>`,
+		r.ReplaceDice("read 2d6+1, then 3D6", markup))
+}
+
+func TestSelection(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		GURPS    bool
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"4d6kh3", "4d6kh3", false, 3, 18, 12},          // 0 - 12.24
+		{"4d6k3", "4d6kh3", false, 3, 18, 12},           // 1 - 'k' alone keeps the highest
+		{"4d6dl1", "4d6dl1", false, 3, 18, 12},          // 2 - same dice kept as 4d6kh3
+		{"2d20kl1", "2d20kl1", false, 1, 20, 7},         // 3 - 7.175
+		{"2d20kh", "2d20kh1", false, 1, 20, 13},         // 4 - 13.825; count defaults to 1
+		{"2d20dh1", "2d20dh1", false, 1, 20, 7},         // 5 - same dice kept as 2d20kl1
+		{"4d6KH3+2x2", "4d6kh3+2x2", false, 10, 40, 28}, // 6 - (12.24 floors to 12, +2) x2
+		{"4d6kh3", "4dkh3", true, 3, 18, 12},            // 7 - GURPS formatting
+		{"4dkh3", "4d6kh3", false, 3, 18, 12},           // 8 - GURPS input
+		{"4d6kh4", "4d6", false, 4, 24, 14},             // 9 - keeping every die is no selection at all
+		{"4d6kh9", "4d6", false, 4, 24, 14},             // 10 - the count is clamped to the number of dice
+		{"4d6dl0", "4d6", false, 4, 24, 14},             // 11 - dropping nothing is no selection at all
+		{"4d6dl4", "4d6dl4", false, 0, 0, 0},            // 12 - dropping everything leaves nothing
+		{"4d6kh0", "4d6kh0", false, 0, 0, 0},            // 13
+		{"4d6kh3kl1", "4d6kh3", false, 3, 18, 12},       // 14 - a second selection ends the spec
+		{"4d6d1", "4d6", false, 4, 24, 14},              // 15 - a bare 'd' is not a drop modifier
+		{"3d1kh2+1", "3d1kh2+1", false, 3, 3, 3},        // 16 - single-sided dice
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		r := newRoller(c, nil, one.GURPS, false)
+		d := r.Parse(one.Text)
+		c.Equal(one.Expected, r.Format(d), desc)
+		c.Equal(one.Minimum, r.Minimum(d), desc)
+		c.Equal(one.Maximum, r.Maximum(d), desc)
+		c.Equal(one.Average, r.Average(d), desc)
+		c.True(r.IsEquivalent(d, r.Parse(r.Format(d))), desc)
+		for range 100 {
+			v := r.Roll(d)
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+	}
+}
+
+// sequenceRandomizer returns the values it holds, in order, wrapping around when it runs out.
+type sequenceRandomizer struct {
+	values []int
+	next   int
+}
+
+func (s *sequenceRandomizer) Intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	v := s.values[s.next%len(s.values)]
+	s.next++
+	return v % n
+}
+
+func TestSelectionRoll(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected int
+	}{
+		{"4d6kh3", 5 + 4 + 2},   // 0
+		{"4d6kl3", 1 + 4 + 2},   // 1
+		{"4d6dh1", 1 + 4 + 2},   // 2
+		{"4d6dl1", 5 + 4 + 2},   // 3
+		{"4d6kh1", 5},           // 4
+		{"4d6kl2+1", 1 + 2 + 1}, // 5
+		{"4d6", 1 + 4 + 2 + 5},  // 6
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		// Rolls 4, 1, 5, 2
+		r := newRoller(c, &sequenceRandomizer{values: []int{3, 0, 4, 1}}, false, false)
+		c.Equal(one.Expected, r.Roll(r.Parse(one.Text)), desc)
+	}
+}
+
+func TestSelectionIgnoresExtraDiceFromModifiers(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, true)
+	d := r.Parse("4d6kh3+8")
+	c.Equal("4d6kh3+8", r.Format(d))
+	c.Equal(d, r.ApplyExtraDiceFromModifiers(d))
+}
+
+func TestExplode(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"d6!", "d6!", 1, 606, 4},             // 0 - 3.5 * 6/5
+		{"3d6!!", "3d6!!", 3, 1818, 12},       // 1
+		{"2d6!p", "2d6!p", 2, 1012, 8},        // 2 - (3.5*6/5 - 1/5) * 2
+		{"d10!>8", "d10!>8", 1, 1010, 7},      // 3 - 5.5 * 10/7
+		{"d10!>=8", "d10!>8", 1, 1010, 7},     // 4
+		{"d10!>10", "d10!", 1, 1010, 6},       // 5 - the highest face is the default threshold
+		{"d10!>11", "d10", 1, 10, 5},          // 6 - a threshold no face reaches never explodes
+		{"d6!>0", "d6!>1", 101, 606, 353},     // 7 - every face explodes
+		{"d1!", "d1!", 101, 101, 101},         // 8 - capped rather than looping forever
+		{"d1!p", "d1!p", 1, 1, 1},             // 9
+		{"2d6!+1x2", "2d6!+1x2", 6, 2426, 18}, // 10
+		{"4d6!kh3", "4d6!kh3", 3, 18, 12},     // 11 - the dice an explosion adds are kept or dropped on their own
+		{"3D6!P", "3d6!p", 3, 1518, 12},       // 12
+		{"d6!!!", "d6!!", 1, 606, 4},          // 13 - a second explosion modifier ends the spec
+		{"d6!>", "d6", 1, 6, 3},               // 14 - a threshold needs a value
+		{"d6!!kh1", "d6!!", 1, 606, 4},        // 15 - keeping every die is no selection at all
+		{"4d6!!kh3", "4d6!!kh3", 3, 1818, 15}, // 16 - ... while compounded rolls stay in the die that exploded
+		{"3d6!dl1", "3d6!dl1", 2, 1812, 10},   // 17
+		{"2d6!>1kl1", "2d6!>1kl1", 1, 6, 1},   // 18 - every face explodes, so at least 202 dice are rolled
+		{"d6!kh1", "d6!kh1", 1, 6, 3},         // 19 - ... but the dice an explosion adds may be dropped
+		{"2d6!dh3", "2d6!dh3", 0, 1194, 0},    // 20 - and more dice than were rolled may be dropped
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		r := newRoller(c, nil, false, false)
+		d := r.Parse(one.Text)
+		c.Equal(one.Expected, r.Format(d), desc)
+		c.Equal(one.Minimum, r.Minimum(d), desc)
+		c.Equal(one.Maximum, r.Maximum(d), desc)
+		c.Equal(one.Average, r.Average(d), desc)
+		c.True(r.IsEquivalent(d, r.Parse(r.Format(d))), desc)
+		for range 100 {
+			v := r.Roll(d)
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+	}
+}
+
+func TestExplodeRoll(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected int
+	}{
+		{"d6!", 6 + 6 + 3},             // 0
+		{"d6!!", 6 + 6 + 3},            // 1
+		{"d6!p", 6 + 5 + 2},            // 2
+		{"d6!>3", 6 + 6 + 3 + 2},       // 3
+		{"2d6!", 6 + 6 + 3 + 2},        // 4
+		{"2d6!kl1", 2},                 // 5
+		{"2d6!kh1", 6},                 // 6 - each explosion adds a die of its own
+		{"2d6!!kh1", 6 + 6 + 3},        // 7 - ... while a compounding die keeps its explosions
+		{"2d6!!kl1", 2},                // 8
+		{"d6", 6},                      // 9
+		{"2d6!p+1", 6 + 5 + 2 + 2 + 1}, // 10
+		{"2d6!>3kh2", 6 + 6},           // 11 - keeps two of the four 6s rolled
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		// Rolls 6, 6, 3, 2
+		r := newRoller(c, &sequenceRandomizer{values: []int{5, 5, 2, 1}}, false, false)
+		c.Equal(one.Expected, r.Roll(r.Parse(one.Text)), desc)
+	}
+}
+
+func TestMaxExplosions(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.MaxExplosions = -1
+	c.HasError(cfg.Valid())
+
+	cfg.MaxExplosions = 0
+	c.NoError(cfg.Valid())
+	cfg.Randomizer = topFaceRandomizer{}
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	c.Equal(6, r.Roll(r.Parse("d6!")))
+	c.Equal(6, r.Maximum(r.Parse("d6!")))
+
+	cfg.MaxExplosions = 2
+	r, err = dice.NewRoller(cfg)
+	c.NoError(err)
+	c.Equal(18, r.Roll(r.Parse("d6!")))
+	c.Equal(18, r.Maximum(r.Parse("d6!")))
+	c.Equal(16, r.Roll(r.Parse("d6!p")))
+	c.Equal(36, r.Roll(r.Parse("2d6!!")))
+
+	// Explosions are limited further when the result could otherwise overflow an int.
+	cfg.MaxCount = 1
+	cfg.MaxSides = math.MaxInt - 1
+	cfg.MaxModifier = 0
+	cfg.MaxMultiplier = 1
+	cfg.MaxExplosions = 100
+	r, err = dice.NewRoller(cfg)
+	c.NoError(err)
+	d := dice.Dice{Count: 1, Sides: math.MaxInt - 1, Multiplier: 1, Explode: dice.Explode}
+	c.Equal(math.MaxInt-1, r.Maximum(d))
+	c.Equal(math.MaxInt-1, r.Roll(d))
+	cfg.MaxSides = math.MaxInt / 4
+	r, err = dice.NewRoller(cfg)
+	c.NoError(err)
+	d.Sides = cfg.MaxSides
+	c.Equal(4*cfg.MaxSides, r.Maximum(d))
+	c.Equal(4*cfg.MaxSides, r.Roll(d))
+}
+
+func TestReroll(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"2d6ro<2", "2d6ro<2", 2, 12, 8},            // 0 - 8.33
+		{"2d6ro2", "2d6ro<2", 2, 12, 8},             // 1
+		{"2d6RO<=2", "2d6ro<2", 2, 12, 8},           // 2
+		{"d20r<3", "d20r<3", 1, 20, 12},             // 3 - a 1 remains possible once the rerolls run out
+		{"d20r", "d20r<1", 1, 20, 11},               // 4 - the face defaults to 1
+		{"d20ro", "d20ro<1", 1, 20, 10},             // 5 - 10.975
+		{"d6r<6", "d6r<6", 1, 6, 3},                 // 6 - rerolling every face changes nothing
+		{"d6r<9", "d6r<6", 1, 6, 3},                 // 7
+		{"d6r<0", "d6r<1", 1, 6, 4},                 // 8
+		{"4d6ro<1kh3", "4d6ro<1kh3", 3, 18, 13},     // 9 - 13.27
+		{"d6r<2!", "d6r<2!", 1, 606, 6},             // 10
+		{"d6ro<2r<3", "d6ro<2", 1, 6, 4},            // 11 - a second reroll modifier ends the spec
+		{"8d10>=7r<1", "8d10>=7r<1", 0, 8, 3},       // 12 - 3.56
+		{"8d10r<1>=7", "8d10>=7r<1", 0, 8, 3},       // 13
+		{"2d6ro<2+1x2", "2d6ro<2+1x2", 6, 26, 18},   // 14
+		{"d1r", "d1r<1", 1, 1, 1},                   // 15
+		{"3d8ro<2!p>7", "3d8ro<2!p>7", 3, 2124, 21}, // 16
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		r := newRoller(c, nil, false, false)
+		d := r.Parse(one.Text)
+		c.Equal(one.Expected, r.Format(d), desc)
+		c.Equal(one.Minimum, r.Minimum(d), desc)
+		c.Equal(one.Maximum, r.Maximum(d), desc)
+		c.Equal(one.Average, r.Average(d), desc)
+		c.True(r.IsEquivalent(d, r.Parse(r.Format(d))), desc)
+		dist, err := r.Distribution(d)
+		c.NoError(err, desc)
+		c.Equal(one.Average, int(math.Floor(dist.Mean()/float64(d.Multiplier)+1e-9))*d.Multiplier, desc)
+		for range 100 {
+			v := r.Roll(d)
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+	}
+}
+
+func TestRerollRoll(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Total    int
+	}{
+		{"2d6ro<2", "2d6ro<2 → [1r2, 5] = 7", 7},                               // 0
+		{"2d6r<2", "2d6r<2 → [1r2r5, 6] = 11", 11},                             // 1
+		{"d6r<2!>5", "d6r<2!>5 → [1r2r5!, 6!, 3] = 14", 14},                    // 2
+		{"3d6ro<2kh2", "3d6ro<2kh2 → [~~1r2~~, 5, 6] = 11", 11},                // 3
+		{"2d6>=5r<2", "2d6>=5r<2 → [1r2r5{1}, 6{1}] = 2", 2},                   // 4
+		{"3d6", "3d6 → [1, 2, 5] = 8", 8},                                      // 5
+		{"2d6r<2!p>5", "2d6r<2!p>5 → [1r2r5!+6!+3-2, 1r2r5!+6!+3-2] = 24", 24}, // 6
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		// Rolls 1, 2, 5, 6, 3
+		rnd := &sequenceRandomizer{values: []int{0, 1, 4, 5, 2}}
+		r := newRoller(c, rnd, false, false)
+		d := r.Parse(one.Text)
+		res := r.RollDetailed(d)
+		c.Equal(one.Expected, res.String(), desc)
+		c.Equal(one.Total, res.Total, desc)
+		rnd.next = 0
+		c.Equal(res.Total, r.Roll(d), desc)
+	}
+}
+
+func TestMaxRerolls(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.MaxRerolls = -1
+	c.HasError(cfg.Valid())
+
+	// Rolls 1, 1, 1, 6
+	rnd := &sequenceRandomizer{values: []int{0, 0, 0, 5}}
+	cfg.Randomizer = rnd
+	for _, one := range []struct {
+		maxRerolls int
+		text       string
+		expected   int
+	}{
+		{0, "d6r<2", 1},
+		{0, "d6ro<2", 1},
+		{1, "d6r<2", 1},
+		{2, "d6r<2", 1},
+		{3, "d6r<2", 6},
+		{3, "d6ro<2", 1},
+	} {
+		cfg.MaxRerolls = one.maxRerolls
+		r, err := dice.NewRoller(cfg)
+		c.NoError(err)
+		rnd.next = 0
+		c.Equal(one.expected, r.Roll(r.Parse(one.text)), "%s with MaxRerolls %d", one.text, one.maxRerolls)
+	}
+	cfg.MaxRerolls = 0
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	c.Equal(3, r.Average(r.Parse("d6r<5")))
+}
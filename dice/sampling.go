@@ -0,0 +1,273 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"math"
+	"slices"
+
+	"github.com/richardwilkes/toolbox/v2/xrand"
+)
+
+// maxExactBinomialMean is the largest expected count for which sampleBinomial counts individual successes rather than
+// using a normal approximation.
+const maxExactBinomialMean = 30
+
+// estimatedDieRolls is the number of dice rolled one at a time to estimate the distribution of a single die that is too
+// large to build exactly, such as a die with hundreds of thousands of sides that explodes on nearly every roll, when
+// some of the dice are kept or dropped.
+const estimatedDieRolls = 1000
+
+// sampleDice returns the total of the dice the prepared Dice keeps, sampled from the distribution of that total rather
+// than by rolling each die.
+//
+// Dice that keep all of their dice are sampled from a normal distribution with the same mean and variance as their
+// total, which for the many dice this is used for is a very close approximation. The result is always a total the dice
+// could actually produce. The mean and variance are exact, and are computed directly from the faces of a die when its
+// distribution is too large to build. Dice that keep or drop some of their dice instead sample how many dice land on
+// each possible total, then add up the kept ones, which takes time proportional to the number of totals a single die
+// can produce. When the distribution of a single die is too large to build for those, it is estimated from
+// estimatedDieRolls dice rolled one at a time, so the time taken never grows with the number of dice. Dice whose
+// explosions add dice sample how many dice they end up with first; those with more sides than a distribution may hold
+// are rolled one die at a time.
+func (r *Roller) sampleDice(dice Dice) int {
+	rnd := r.config().Randomizer
+	if dice.Selection == SelectAll && dice.isPlain() {
+		sides := float64(dice.Sides)
+		return sampleSum(dice.Count, (sides+1)/2, (sides*sides-1)/12, 1, dice.Sides, 1, rnd)
+	}
+	if dice.addsDice() {
+		if added := newAddedDice(dice, r.explosionLimit(dice), r.rerollLimit(dice)); added != nil {
+			return added.sample(rnd)
+		}
+		return r.rollEachDie(dice)
+	}
+	var die *sampledDie
+	faces := r.config().faces(dice.Faces)
+	switch exact := r.dieDistribution(dice); {
+	case exact != nil:
+		die = pmfDie(exact)
+	case faces != nil:
+		die = facesDie(faces)
+	case dice.Selection == SelectAll:
+		mean, variance := dieMoments(dice, r.explosionLimit(dice), r.rerollLimit(dice))
+		low, high := r.dieRange(dice)
+		return sampleSum(dice.Count, mean, variance, low, high, 1, rnd)
+	case dice.Count <= estimatedDieRolls:
+		return r.rollEachDie(dice)
+	default:
+		die = r.estimateDie(dice)
+	}
+	if dice.Selection == SelectAll {
+		mean, variance, step := die.moments()
+		low, high := die.bounds()
+		return sampleSum(dice.Count, mean, variance, low, high, step, rnd)
+	}
+	from, to := dice.keptRange()
+	s := newKeptSample(dice.Count, from, to)
+	s.add(die, dice.Count, rnd)
+	return s.total
+}
+
+// keptSample accumulates a sample of the total of the kept dice of a sorted pool of dice, given the number of dice that
+// land on each total. Only the kept dice matter, so it works inward from whichever end of the sorted dice reaches the
+// last of them sooner.
+type keptSample struct {
+	// from and to are the range of indexes of the kept dice, counted from the end being worked from.
+	from, to int
+	// seen is the number of dice placed so far.
+	seen  int
+	total int
+	// topDown is true if the dice are placed from the highest total down.
+	topDown bool
+}
+
+// newKeptSample returns a keptSample for a pool of count dice that keeps those at the sorted indexes from up to to.
+func newKeptSample(count, from, to int) *keptSample {
+	s := &keptSample{from: from, to: to}
+	if s.topDown = count-from < to; s.topDown {
+		s.from, s.to = count-to, count-from
+	}
+	return s
+}
+
+// add places count dice, each distributed as die, as the next dice from the end being worked from, sampling how many
+// land on each total. The totals must not overlap those of the dice already placed, and must lie further from that
+// end.
+func (s *keptSample) add(die *sampledDie, count int, rnd xrand.Randomizer) {
+	remaining := count
+	var mass float64
+	for _, p := range die.probs {
+		mass += p
+	}
+	last := len(die.probs) - 1
+	for step := 0; step <= last && remaining > 0 && s.seen < s.to; step++ {
+		i := step
+		if s.topDown {
+			i = last - step
+		}
+		p := die.probs[i]
+		var n int
+		if step == last || mass <= p {
+			n = remaining
+		} else {
+			n = sampleBinomial(remaining, p/mass, rnd)
+		}
+		mass -= p
+		remaining -= n
+		// The dice landing on this total occupy the next n positions; add those that are kept.
+		if kept := min(s.seen+n, s.to) - max(s.seen, s.from); kept > 0 {
+			s.total += kept * die.total(i)
+		}
+		s.seen += n
+	}
+	s.seen += remaining
+}
+
+// sampledDie holds the chance of each total a single die can produce, in ascending order.
+type sampledDie struct {
+	probs []float64
+	// totals holds the total for each entry in probs, or is nil if they are the consecutive integers starting at lo.
+	totals []int
+	lo     int
+}
+
+// pmfDie returns the distribution of the totals of a single die held in d.
+func pmfDie(d *pmf) *sampledDie {
+	return &sampledDie{probs: d.probs, lo: d.lo}
+}
+
+// total returns the total for the i-th entry in probs.
+func (d *sampledDie) total(i int) int {
+	if d.totals != nil {
+		return d.totals[i]
+	}
+	return d.lo + i
+}
+
+func (d *sampledDie) bounds() (low, high int) {
+	return d.total(0), d.total(len(d.probs) - 1)
+}
+
+// moments returns the mean and variance of the die's total, along with the largest step that every total with a
+// non-zero chance is a multiple of when measured from the lowest total, or 1 if there is only one.
+func (d *sampledDie) moments() (mean, variance float64, step int) {
+	low := d.total(0)
+	var mass float64
+	for i, p := range d.probs {
+		if p > 0 {
+			mass += p
+			mean += p * float64(d.total(i)-low)
+			step = gcd(step, d.total(i)-low)
+		}
+	}
+	mean /= mass
+	for i, p := range d.probs {
+		delta := float64(d.total(i)-low) - mean
+		variance += p * delta * delta
+	}
+	return mean + float64(low), variance / mass, max(step, 1)
+}
+
+// estimateDie returns the distribution of the totals a single die of the prepared Dice produced over estimatedDieRolls
+// rolls.
+func (r *Roller) estimateDie(dice Dice) *sampledDie {
+	rnd := r.config().Randomizer
+	explosions := r.explosionLimit(dice)
+	rerolls := r.rerollLimit(dice)
+	rolls := make([]int, estimatedDieRolls)
+	for i := range rolls {
+		rolls[i] = rollDie(dice, nil, rnd, explosions, rerolls, nil)
+	}
+	return totalsDie(rolls)
+}
+
+// facesDie returns the exact distribution of the faces of a custom die, however widely spread its faces are.
+func facesDie(faces []int) *sampledDie {
+	return totalsDie(slices.Clone(faces))
+}
+
+// totalsDie returns the distribution of the given totals, each of which is equally likely. The totals are sorted in
+// place.
+func totalsDie(totals []int) *sampledDie {
+	slices.Sort(totals)
+	d := &sampledDie{}
+	for i, v := range totals {
+		if i == 0 || v != totals[i-1] {
+			d.totals = append(d.totals, v)
+			d.probs = append(d.probs, 0)
+		}
+		d.probs[len(d.probs)-1] += 1 / float64(len(totals))
+	}
+	return d
+}
+
+// sampleSum returns a sample of the total of count dice, each of which produces totals from low to high in multiples of
+// step from low, with the given mean and variance.
+func sampleSum(count int, mean, variance float64, low, high, step int, rnd xrand.Randomizer) int {
+	n := float64(count)
+	x := n*mean + math.Sqrt(n*variance)*sampleNormal(rnd)
+	base := count * low
+	steps := int(math.Round((x - float64(base)) / float64(step)))
+	return base + min(max(steps, 0), count*((high-low)/step))*step
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// sampleBinomial returns the number of successes in n trials that each succeed with probability p. When few successes
+// or failures are expected, they are counted individually by jumping from one to the next; otherwise, the count is
+// taken from a normal approximation.
+func sampleBinomial(n int, p float64, rnd xrand.Randomizer) int {
+	switch {
+	case p <= 0:
+		return 0
+	case p >= 1:
+		return n
+	case p > 0.5:
+		return n - sampleBinomial(n, 1-p, rnd)
+	}
+	mean := float64(n) * p
+	if mean > maxExactBinomialMean {
+		x := math.Round(mean + math.Sqrt(mean*(1-p))*sampleNormal(rnd))
+		return int(min(max(x, 0), float64(n)))
+	}
+	// The gap between successes is geometrically distributed, so sum gaps until they pass the last trial.
+	logFail := math.Log1p(-p)
+	var count int
+	var trial float64
+	for {
+		trial += math.Floor(math.Log(sampleUniform(rnd))/logFail) + 1
+		if trial > float64(n) {
+			return count
+		}
+		count++
+	}
+}
+
+// sampleNormal returns a sample from the standard normal distribution.
+func sampleNormal(rnd xrand.Randomizer) float64 {
+	u := sampleUniform(rnd)
+	v := sampleUniform(rnd)
+	return math.Sqrt(-2*math.Log(u)) * math.Cos(2*math.Pi*v)
+}
+
+// sampleUniform returns a sample from the uniform distribution over the open interval (0, 1), with 53 bits of
+// precision.
+func sampleUniform(rnd xrand.Randomizer) float64 {
+	// Intn takes an int, which may be only 32 bits wide, so the bits are drawn in pieces and assembled in a uint64.
+	bits := uint64(rnd.Intn(1<<26))<<27 | uint64(rnd.Intn(1<<27))
+	return (float64(bits) + 0.5) / (1 << 53)
+}
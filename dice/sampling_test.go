@@ -0,0 +1,205 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func newSamplingRoller(c check.Checker, threshold int, seed uint64) *dice.Roller {
+	c.Helper()
+	cfg := dice.DefaultConfig()
+	cfg.FastRollThreshold = threshold
+	cfg.Randomizer = dice.NewSeededRandomizer(seed)
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	return r
+}
+
+func TestFastRollThreshold(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.FastRollThreshold = -1
+	c.HasError(cfg.Valid())
+	cfg.FastRollThreshold = math.MaxInt
+	c.HasError(cfg.Valid())
+	cfg.FastRollThreshold = 1000
+	c.NoError(cfg.Valid())
+	c.Equal(10_000, dice.DefaultConfig().FastRollThreshold)
+
+	// Rolls at or below the threshold draw exactly the same values as they do without one.
+	plain := newSamplingRoller(c, 0, 42)
+	fast := newSamplingRoller(c, 1000, 42)
+	for _, text := range []string{"1000d6", "3d6", "1000d6kh3", "1000d10>=7", "10d6!"} {
+		d := plain.Parse(text)
+		for range 10 {
+			c.Equal(plain.Roll(d), fast.Roll(d), text)
+		}
+	}
+}
+
+func TestFastRollHugeCount(t *testing.T) {
+	c := check.New(t)
+	r := newSamplingRoller(c, 1000, 7)
+	for i, text := range []string{
+		"999999d999999",          // 0
+		"999999d999999kh3",       // 1
+		"999999d6dl1",            // 2
+		"999999d6!",              // 3
+		"999999d10>=7dbl10f1",    // 4
+		"999999dF+5x3",           // 5
+		"999999d100oe",           // 6
+		"999999d{0,2,4}kl500000", // 7
+		"999999d999999!>2",       // 8 - a single die's distribution is too large to compute
+		"999999d999999!>2kh10",   // 9 - ... and with some dice dropped, it is estimated
+		"999999d999999!dl10",     // 10 - the dice an explosion adds are sorted in with the rest
+		"999999d6!>2kl3",         // 11
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, text)
+		d := r.Parse(text)
+		minimum := r.Minimum(d)
+		maximum := r.Maximum(d)
+		start := time.Now()
+		for range 10 {
+			v := r.Roll(d)
+			c.True(v >= minimum && v <= maximum, "%s: roll %d outside [%d,%d]", desc, v, minimum, maximum)
+		}
+		c.True(time.Since(start) < 5*time.Second, desc)
+	}
+}
+
+func TestFastRollDetailed(t *testing.T) {
+	c := check.New(t)
+	// RollDetailed, and so Check and Contest, sample exactly as Roll does.
+	plain := newSamplingRoller(c, 1000, 5)
+	detailed := newSamplingRoller(c, 1000, 5)
+	for i, text := range []string{"5000d6+2", "5000d6kh3", "5000d10>=7", "1000d6", "10d6!"} {
+		desc := fmt.Sprintf("Table index %d: %s", i, text)
+		d := plain.Parse(text)
+		sampled := d.Count > 1000
+		for range 5 {
+			res := detailed.RollDetailed(d)
+			c.Equal(plain.Roll(d), res.Total, desc)
+			c.Equal(sampled, res.Sampled, desc)
+			if sampled {
+				c.Equal(0, len(res.Rolls), desc)
+				c.True(strings.Contains(res.String(), "[sampled "+strconv.Itoa(res.Subtotal-res.Modifier)+"]"), desc)
+			} else {
+				var own int
+				for _, roll := range res.Rolls {
+					if !roll.Added {
+						own++
+					}
+				}
+				c.Equal(d.Count, own, desc)
+			}
+		}
+	}
+	d := plain.Parse("5000d10>=7")
+	res := detailed.RollDetailed(d)
+	c.Equal(res.Total, res.Successes())
+
+	r := newSamplingRoller(c, 1000, 6)
+	d = r.Parse("999999d6")
+	start := time.Now()
+	check := r.Check(dice.Check{Dice: d, Target: 3_500_000})
+	c.True(check.Result.Sampled)
+	c.True(check.Total >= r.Minimum(d) && check.Total <= r.Maximum(d))
+	contest := r.Contest(dice.Contest{First: dice.Contestant{Dice: d}, Second: dice.Contestant{Dice: d}})
+	c.True(contest.First.Sampled && contest.Second.Sampled)
+	c.True(time.Since(start) < 5*time.Second)
+}
+
+func TestFastRollDistribution(t *testing.T) {
+	c := check.New(t)
+	plain := newSamplingRoller(c, 0, 98)
+	fast := newSamplingRoller(c, 100, 99)
+	for i, one := range []struct {
+		Text string
+		Step int
+	}{
+		{"1000d6", 1},           // 0
+		{"500d6kh250", 1},       // 1
+		{"500d6dh490", 1},       // 2
+		{"1000d10>=7", 1},       // 3
+		{"1000d{0,2}", 2},       // 4 - every total is even
+		{"200d6!", 1},           // 5
+		{"500d20kl10", 1},       // 6
+		{"1000d{0,3,9}+1x2", 6}, // 7
+		{"500d6!kh50", 1},       // 8
+		{"500d6!>4dl300", 1},    // 9
+		{"500d4r<2!kl10", 1},    // 10
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		d := plain.Parse(one.Text)
+		minimum := plain.Minimum(d)
+		wantMean, wantSD := sampleStats(func() int { return plain.Roll(d) })
+		mean, sd := sampleStats(func() int {
+			v := fast.Roll(d)
+			c.Equal(0, (v-minimum)%one.Step, "%s: roll %d cannot occur", desc, v)
+			return v
+		})
+		spread := max(wantSD, 1)
+		c.True(math.Abs(mean-wantMean) < 6*spread/math.Sqrt(samplingRolls), "%s: mean %v, expected %v", desc, mean,
+			wantMean)
+		c.True(math.Abs(sd-wantSD) < 0.15*spread, "%s: standard deviation %v, expected %v", desc, sd, wantSD)
+	}
+}
+
+func TestFastRollUnbuildableDie(t *testing.T) {
+	c := check.New(t)
+	r := newSamplingRoller(c, 1000, 11)
+	const sides = 999_999
+	for i, one := range []struct {
+		Text string
+		// Rolls is about how many times each die is rolled, as nearly every roll of a die that explodes on a 2 or more
+		// explodes until it reaches the default limit of 100 explosions.
+		Rolls float64
+	}{
+		{"999999d999999!", 1},      // 0
+		{"999999d999999!>2", 101},  // 1
+		{"999999d999999!p>2", 101}, // 2
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		d := r.Parse(one.Text)
+		// The distribution of a single die is too large to compute, so the total is sampled from its moments.
+		single := d
+		single.Count = 1
+		_, err := r.Distribution(single)
+		c.HasError(err, desc)
+		mean, sd := sampleStats(func() int { return r.Roll(d) })
+		wantSD := math.Sqrt(float64(d.Count) * one.Rolls * (sides*sides - 1) / 12)
+		c.True(math.Abs(mean-float64(r.Average(d))) < 6*wantSD/math.Sqrt(samplingRolls), "%s: mean %v, expected %v",
+			desc, mean, r.Average(d))
+		c.True(math.Abs(sd-wantSD) < 0.1*wantSD, "%s: standard deviation %v, expected %v", desc, sd, wantSD)
+	}
+}
+
+const samplingRolls = 2000
+
+// sampleStats returns the mean and standard deviation of the values returned by samplingRolls calls to roll.
+func sampleStats(roll func() int) (mean, sd float64) {
+	var sum, sumSquares float64
+	for range samplingRolls {
+		v := float64(roll())
+		sum += v
+		sumSquares += v * v
+	}
+	mean = sum / samplingRolls
+	return mean, math.Sqrt(max(sumSquares/samplingRolls-mean*mean, 0))
+}
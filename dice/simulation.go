@@ -0,0 +1,267 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"math"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/richardwilkes/toolbox/v2/errs"
+)
+
+const (
+	// simulationChunk is the number of trials each derived random stream of a simulation performs.
+	simulationChunk = 1024
+	// maxHistogramRows is the most rows Histogram.Text writes; wider ranges of outcomes are grouped.
+	maxHistogramRows = 40
+	// histogramBarWidth is the width of the longest bar Histogram.Text writes.
+	histogramBarWidth = 50
+)
+
+// Trial performs a single trial of a simulation, rolling any dice it needs with the given Roller, and returns its
+// outcome.
+type Trial func(r *Roller) int
+
+// DiceTrial returns a Trial that rolls the dice.
+func DiceTrial(dice Dice) Trial {
+	return func(r *Roller) int {
+		return r.Roll(dice)
+	}
+}
+
+// Trial returns a Trial that rolls the expression.
+func (e *Expression) Trial() Trial {
+	return func(r *Roller) int {
+		return e.root.roll(r, nil)
+	}
+}
+
+// Simulation describes a Monte Carlo simulation: a number of independent trials whose outcomes are collected into a
+// Histogram.
+type Simulation struct {
+	// Trial performs a single trial.
+	Trial Trial
+	// Trials is the number of trials to perform.
+	Trials int
+	// Workers is the number of goroutines to perform the trials across. A value less than 1 uses one goroutine for each
+	// available CPU.
+	Workers int
+	// Seed identifies the random values the trials draw. The trials are divided into fixed blocks, each of which draws
+	// from its own stream derived from the Seed, so the outcome of a simulation depends only on its Seed and Trials, and
+	// not on its Workers or how they happen to be scheduled.
+	Seed uint64
+}
+
+// Simulate performs the simulation using this Roller's Config, but drawing random values as determined by the
+// simulation's Seed rather than from the Config's Randomizer. An error is returned if the simulation has no Trial or
+// fewer than one trial to perform.
+func (r *Roller) Simulate(sim Simulation) (*Histogram, error) {
+	if sim.Trial == nil {
+		return nil, errs.New("simulation must have a Trial")
+	}
+	if sim.Trials < 1 {
+		return nil, errs.New("simulation must have at least one trial")
+	}
+	cfg := r.config().Clone()
+	cfg.Randomizer = NewSeededRandomizer(sim.Seed)
+	base := &Roller{cfg: cfg}
+	chunks := (sim.Trials + simulationChunk - 1) / simulationChunk
+	workers := sim.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, chunks)
+	counts := make([]map[int]int, workers)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for i := range counts {
+		local := make(map[int]int)
+		counts[i] = local
+		wg.Go(func() {
+			for {
+				chunk := int(next.Add(1) - 1)
+				if chunk >= chunks {
+					return
+				}
+				roller := base.Derive(uint64(chunk))
+				for range min(simulationChunk, sim.Trials-chunk*simulationChunk) {
+					local[sim.Trial(roller)]++
+				}
+			}
+		})
+	}
+	wg.Wait()
+	merged := counts[0]
+	for _, one := range counts[1:] {
+		for v, n := range one {
+			merged[v] += n
+		}
+	}
+	return newHistogram(merged), nil
+}
+
+// Histogram holds the number of times each outcome occurred over the trials of a simulation.
+type Histogram struct {
+	// values holds each distinct outcome, in ascending order.
+	values []int
+	// counts[i] holds the number of trials whose outcome was values[i].
+	counts []int
+	trials int
+}
+
+func newHistogram(counts map[int]int) *Histogram {
+	h := &Histogram{values: slices.Sorted(maps.Keys(counts))}
+	h.counts = make([]int, len(h.values))
+	for i, v := range h.values {
+		h.counts[i] = counts[v]
+		h.trials += h.counts[i]
+	}
+	return h
+}
+
+// Trials returns the number of trials.
+func (h *Histogram) Trials() int {
+	return h.trials
+}
+
+// Count returns the number of trials whose outcome was v.
+func (h *Histogram) Count(v int) int {
+	if i, found := slices.BinarySearch(h.values, v); found {
+		return h.counts[i]
+	}
+	return 0
+}
+
+// Minimum returns the lowest outcome.
+func (h *Histogram) Minimum() int {
+	return h.values[0]
+}
+
+// Maximum returns the highest outcome.
+func (h *Histogram) Maximum() int {
+	return h.values[len(h.values)-1]
+}
+
+// Mean returns the average outcome.
+func (h *Histogram) Mean() float64 {
+	var sum float64
+	for i, v := range h.values {
+		sum += float64(v) * float64(h.counts[i])
+	}
+	return sum / float64(h.trials)
+}
+
+// Variance returns the variance of the outcomes.
+func (h *Histogram) Variance() float64 {
+	mean := h.Mean()
+	var sum float64
+	for i, v := range h.values {
+		delta := float64(v) - mean
+		sum += delta * delta * float64(h.counts[i])
+	}
+	return sum / float64(h.trials)
+}
+
+// StandardDeviation returns the standard deviation of the outcomes.
+func (h *Histogram) StandardDeviation() float64 {
+	return math.Sqrt(h.Variance())
+}
+
+// Median returns the median outcome.
+func (h *Histogram) Median() int {
+	return h.Percentile(50)
+}
+
+// Percentile returns the lowest outcome that at least p percent of the trials did not exceed. p is limited to the range
+// 0 to 100, with 0 giving the Minimum and 100 the Maximum.
+func (h *Histogram) Percentile(p float64) int {
+	target := max(math.Ceil(min(max(p, 0), 100)/100*float64(h.trials)), 1)
+	var seen int
+	for i, n := range h.counts {
+		if seen += n; float64(seen) >= target {
+			return h.values[i]
+		}
+	}
+	return h.Maximum()
+}
+
+// ConfidenceInterval returns the range within which the true average outcome lies with the given confidence, such as
+// 0.95 for 95%, estimated from the Mean and StandardDeviation of the trials. The confidence is limited to the range 0
+// to 0.9999.
+func (h *Histogram) ConfidenceInterval(confidence float64) (low, high float64) {
+	z := math.Sqrt2 * math.Erfinv(min(max(confidence, 0), 0.9999))
+	mean := h.Mean()
+	margin := z * h.StandardDeviation() / math.Sqrt(float64(h.trials))
+	return mean - margin, mean + margin
+}
+
+// All returns an iterator over each outcome that occurred and the number of trials that produced it, in ascending order
+// of outcome.
+func (h *Histogram) All() iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		for i, v := range h.values {
+			if !yield(v, h.counts[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Text writes a text representation of the histogram, with its summary statistics followed by a bar for each outcome.
+// When the outcomes span more than 40 values, each bar covers an equal range of them instead.
+func (h *Histogram) Text(w io.Writer) {
+	fmt.Fprintf(w, "Trials: %d\n", h.trials)
+	fmt.Fprintf(w, "Mean: %.2f\n", h.Mean())
+	low, high := h.ConfidenceInterval(0.95)
+	fmt.Fprintf(w, "95%% Confidence Interval: %.2f to %.2f\n", low, high)
+	fmt.Fprintf(w, "Standard Deviation: %.2f\n", h.StandardDeviation())
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Percentiles:")
+	for _, p := range []int{5, 25, 50, 75, 95} {
+		fmt.Fprintf(w, "  %2d%%: %d\n", p, h.Percentile(float64(p)))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Histogram:")
+	// Work with unsigned offsets from the Minimum, so that even outcomes spanning the whole range of an int are grouped
+	// without overflowing.
+	minimum := h.Minimum()
+	span := uint64(h.Maximum() - minimum)
+	step := span/maxHistogramRows + 1
+	rows := make([]int, span/step+1)
+	for i, v := range h.values {
+		rows[uint64(v-minimum)/step] += h.counts[i]
+	}
+	labels := make([]string, len(rows))
+	var width int
+	for i := range rows {
+		first := uint64(i) * step
+		labels[i] = strconv.Itoa(minimum + int(first))
+		if last := min(first+step-1, span); last != first {
+			labels[i] += " to " + strconv.Itoa(minimum+int(last))
+		}
+		width = max(width, len(labels[i]))
+	}
+	largest := slices.Max(rows)
+	for i, n := range rows {
+		bar := strings.Repeat("#", int(math.Round(float64(n)*histogramBarWidth/float64(largest))))
+		fmt.Fprintf(w, "  %[1]*s | %-[3]*s %6.2f%%\n", width, labels[i], histogramBarWidth, bar,
+			float64(n)*100/float64(h.trials))
+	}
+}
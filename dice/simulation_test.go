@@ -0,0 +1,149 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestSimulate(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	sim := dice.Simulation{Trial: dice.DiceTrial(r.Parse("3d6")), Trials: 20000, Seed: 2}
+	h, err := r.Simulate(sim)
+	c.NoError(err)
+	c.Equal(20000, h.Trials())
+	c.Equal(3, h.Minimum())
+	c.Equal(18, h.Maximum())
+	c.True(math.Abs(h.Mean()-10.5) < 0.1)
+	c.True(math.Abs(h.Variance()-8.75) < 0.3)
+	// Exactly half of all rolls of 3d6 are 10 or less, so the median of the trials may be either 10 or 11.
+	c.True(h.Median() == 10 || h.Median() == 11)
+	c.Equal(3, h.Percentile(0))
+	c.Equal(18, h.Percentile(100))
+	low, high := h.ConfidenceInterval(0.95)
+	c.True(low < 10.5 && high > 10.5)
+	c.True(high-low < 0.1)
+	var total int
+	for v, n := range h.All() {
+		c.Equal(n, h.Count(v))
+		total += n
+	}
+	c.Equal(h.Trials(), total)
+	c.Equal(0, h.Count(2))
+
+	// The outcome depends on the Seed, but not on the number of workers.
+	for _, workers := range []int{1, 3, 64, 0} {
+		sim.Workers = workers
+		other, err := r.Simulate(sim)
+		c.NoError(err, workers)
+		c.Equal(h, other, workers)
+	}
+	sim.Seed = 3
+	other, err := r.Simulate(sim)
+	c.NoError(err)
+	c.NotEqual(h, other)
+
+	_, err = r.Simulate(dice.Simulation{Trials: 10})
+	c.HasError(err)
+	_, err = r.Simulate(dice.Simulation{Trial: dice.DiceTrial(r.Parse("d6"))})
+	c.HasError(err)
+}
+
+func TestSimulateCallback(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	// Count the successes in a pool of 5d10, where each 10 adds another die to the pool.
+	pool := r.Parse("d10")
+	h, err := r.Simulate(dice.Simulation{Trials: 5000, Seed: 9, Trial: func(r *dice.Roller) int {
+		var successes int
+		for dieCount := 5; dieCount > 0; dieCount-- {
+			v := r.Roll(pool)
+			if v >= 8 {
+				successes++
+			}
+			if v == 10 {
+				dieCount++
+			}
+		}
+		return successes
+	}})
+	c.NoError(err)
+	c.Equal(0, h.Minimum())
+	// Each die scores 0.3 successes, and 5 dice become 5/0.9 dice on average.
+	c.True(math.Abs(h.Mean()-0.3*5/0.9) < 0.1)
+
+	expr, err := r.ParseExpression("2d6*2+1")
+	c.NoError(err)
+	h, err = r.Simulate(dice.Simulation{Trial: expr.Trial(), Trials: 5000, Seed: 9})
+	c.NoError(err)
+	c.Equal(5, h.Minimum())
+	c.Equal(25, h.Maximum())
+	c.Equal(0, h.Count(6))
+
+	formula, err := r.ParseFormula("d6+@bonus")
+	c.NoError(err)
+	expr, err = formula.Bind(dice.VariableMap{"bonus": 10})
+	c.NoError(err)
+	h, err = r.Simulate(dice.Simulation{Trial: expr.Trial(), Trials: 100, Seed: 9})
+	c.NoError(err)
+	c.Equal(11, h.Minimum())
+	c.Equal(16, h.Maximum())
+}
+
+func TestHistogramText(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	h, err := r.Simulate(dice.Simulation{Trial: dice.DiceTrial(r.Parse("7")), Trials: 10})
+	c.NoError(err)
+	var buffer strings.Builder
+	h.Text(&buffer)
+	c.Equal(`Trials: 10
+Mean: 7.00
+95% Confidence Interval: 7.00 to 7.00
+Standard Deviation: 0.00
+
+Percentiles:
+   5%: 7
+  25%: 7
+  50%: 7
+  75%: 7
+  95%: 7
+
+Histogram:
+  7 | ################################################## 100.00%
+`, buffer.String())
+
+	h, err = r.Simulate(dice.Simulation{Trial: dice.DiceTrial(r.Parse("2d6")), Trials: 1000, Seed: 4})
+	c.NoError(err)
+	buffer.Reset()
+	h.Text(&buffer)
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	c.Equal("Histogram:", lines[len(lines)-12])
+	c.True(strings.HasPrefix(lines[len(lines)-11], "   2 | #"))
+	c.True(strings.HasPrefix(lines[len(lines)-1], "  12 | #"))
+
+	// Wide ranges of outcomes are grouped, even when they span the whole range of an int.
+	h, err = r.Simulate(dice.Simulation{Trials: 2, Trial: func(r *dice.Roller) int {
+		if r.Roll(r.Parse("d2")) == 1 {
+			return math.MinInt
+		}
+		return math.MaxInt
+	}})
+	c.NoError(err)
+	buffer.Reset()
+	h.Text(&buffer)
+	c.True(strings.Count(buffer.String(), " | ") <= 40)
+}
@@ -0,0 +1,646 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import "math"
+
+const (
+	// maxExactThresholds is the most distinct die totals selectedMean evaluates individually. Larger dice are evaluated
+	// in evenly sized blocks of totals instead, which approximates the result very closely. selectedMean is only used
+	// when the exact distribution of the kept dice is too large to compute.
+	maxExactThresholds = 4096
+	// maxExactBinomialTerms is the most binomial terms expectedMinBinomial sums before switching to a normal
+	// approximation.
+	maxExactBinomialTerms = 256
+	// maxSupport is the most distinct totals a probability mass function may hold.
+	maxSupport = 1 << 22
+	// maxWork bounds the number of multiply-adds spent building a probability mass function.
+	maxWork = 1 << 27
+	// negligible is the probability below which an explosion chain is considered to have ended. Its contribution is far
+	// below the precision of a float64 sum of probabilities.
+	negligible = 1e-18
+)
+
+// dieRange returns the lowest and highest total a single die of the prepared Dice can produce when it may explode up
+// to the given number of times. For Dice that count successes, the totals are numbers of successes.
+func dieRange(dice Dice, explosions int) (low, high int) {
+	if dice.countsSuccesses() {
+		return successRange(dice, explosions)
+	}
+	low = 1
+	high = dice.Sides
+	if dice.OpenEnded != NoOpenEnded {
+		// Each roll after the first adds or subtracts at most a full die.
+		if dice.OpenEnded != OpenEndedLow {
+			high += explosions * dice.Sides
+		}
+		if dice.OpenEnded != OpenEndedHigh {
+			low -= explosions * dice.Sides
+		}
+		return low, high
+	}
+	if dice.Explode != NoExplode {
+		growth := dice.Sides
+		if dice.Explode == Penetrate {
+			growth--
+		} else if dice.explodesAt() == 1 {
+			// Every face explodes, so every die rolls as many times as it may.
+			low += explosions
+		}
+		high += explosions * growth
+	}
+	return low, high
+}
+
+// dieMean returns the average total of a single die of the prepared Dice when it may explode up to the given number of
+// times and each roll may be rolled again up to the given number of rerolls, or for Dice that count successes, the
+// average number of successes. The k-th additional roll happens only if each of the k rolls before it exploded, which
+// happens with probability q^k, and is otherwise an ordinary roll of the die, so the average is the sum of the ordinary
+// average weighted by each of those chances.
+func dieMean(dice Dice, explosions, rerolls int) float64 {
+	if dice.countsSuccesses() {
+		return successMean(dice, explosions, rerolls)
+	}
+	if dice.OpenEnded != NoOpenEnded {
+		return openEndedMean(dice, explosions)
+	}
+	mean := faceMean(dice, rerolls)
+	if dice.Explode == NoExplode || explosions == 0 {
+		return mean
+	}
+	extra := expectedExplosions(chanceAtLeast(dice, rerolls, dice.explodesAt()), explosions)
+	total := mean * (1 + extra)
+	if dice.Explode == Penetrate {
+		total -= extra
+	}
+	return total
+}
+
+// rollMoments describes a single roll of a die as the start of a chain of rolls that continues while they explode. It
+// holds the chance of the roll exploding, along with the sums, each weighted by the chance of the face, of the amounts
+// every face and the exploding faces contribute, and of the squares of the amounts every face contributes.
+type rollMoments struct {
+	explodes   float64
+	sum        float64
+	explodeSum float64
+	sumSq      float64
+}
+
+// chainMoments holds the effect on the mean and second moment of the total of a chain of rolls of putting some rolls in
+// front of it: the mean becomes a+q*mean and the second moment becomes b+2*c*mean+q*second. The moments of a chain
+// that ends after those rolls are a and b.
+type chainMoments struct {
+	q, a, b, c float64
+}
+
+func (m rollMoments) chain() chainMoments {
+	return chainMoments{q: m.explodes, a: m.sum, b: m.sumSq, c: m.explodeSum}
+}
+
+// then returns the effect of putting the rolls of m in front of those of next.
+func (m chainMoments) then(next chainMoments) chainMoments {
+	return chainMoments{
+		q: m.q * next.q,
+		a: m.a + m.q*next.a,
+		b: m.b + 2*m.c*next.a + m.q*next.b,
+		c: m.c*next.q + m.q*next.c,
+	}
+}
+
+// repeat returns the effect of putting n copies of the rolls of m in front of a chain. It squares its way up to n, so
+// only O(log n) steps are needed however many rolls a chain may have.
+func (m chainMoments) repeat(n int) chainMoments {
+	result := chainMoments{q: 1}
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result = result.then(m)
+		}
+		m = m.then(m)
+	}
+	return result
+}
+
+// faceMoments returns the moments of a single roll of a die of the prepared Dice, reduced by the given penalty, when
+// the roll may be rolled again up to the given number of rerolls and the faces from explodeAt up explode.
+func faceMoments(dice Dice, rerolls, penalty, explodeAt int) rollMoments {
+	var m rollMoments
+	low, high := faceChances(dice, rerolls)
+	threshold := 0
+	if dice.Reroll != NoReroll && rerolls != 0 {
+		threshold = min(dice.RerollThreshold, dice.Sides)
+	}
+	for _, part := range [][3]int{{1, min(threshold, explodeAt-1)}, {threshold + 1, explodeAt - 1},
+		{explodeAt, threshold}, {max(explodeAt, threshold+1), dice.Sides}} {
+		first, last := max(part[0], 1), part[1]
+		if first > last {
+			continue
+		}
+		chance := high
+		if last <= threshold {
+			chance = low
+		}
+		count, sum, sumSq := rangeSums(first-penalty, last-penalty)
+		m.sum += chance * sum
+		m.sumSq += chance * sumSq
+		if first >= explodeAt {
+			m.explodes += chance * count
+			m.explodeSum += chance * sum
+		}
+	}
+	return m
+}
+
+// rangeSums returns the number of integers from first to last, along with their sum and the sum of their squares.
+func rangeSums(first, last int) (count, sum, sumSq float64) {
+	count = float64(last - first + 1)
+	a := float64(first)
+	b := float64(last)
+	sum = (a + b) * count / 2
+	// The sum of the squares of the count integers a, a+1, ..., b.
+	sumSq = count * (a*a + a*(count-1) + (count-1)*(2*count-1)/6)
+	return count, sum, sumSq
+}
+
+// groupMoments returns the moments of a single roll of a die that counts successes, split into the given groups.
+func groupMoments(groups []faceGroup) rollMoments {
+	var m rollMoments
+	for _, g := range groups {
+		score := float64(g.score)
+		m.sum += g.chance * score
+		m.sumSq += g.chance * score * score
+		if g.explodes {
+			m.explodes += g.chance
+			m.explodeSum += g.chance * score
+		}
+	}
+	return m
+}
+
+// dieMoments returns the mean and variance of the total of a single die of the prepared Dice when it may explode up to
+// the given number of times and each roll may be rolled again up to the given number of rerolls, or for Dice that
+// count successes, of its number of successes. They are computed directly from the faces, without building the
+// distribution of the total, so they are available however large that distribution would be.
+func dieMoments(dice Dice, explosions, rerolls int) (mean, variance float64) {
+	if dice.OpenEnded != NoOpenEnded && !dice.countsSuccesses() {
+		return openEndedMoments(dice, explosions)
+	}
+	if dice.Explode == NoExplode {
+		explosions = 0
+	}
+	var first, rest rollMoments
+	if dice.countsSuccesses() {
+		first = groupMoments(dice.faceGroups(0, rerolls))
+		rest = groupMoments(dice.faceGroups(successPenalty(dice), rerolls))
+	} else {
+		explodeAt := dice.Sides + 1
+		if dice.Explode != NoExplode {
+			explodeAt = dice.explodesAt()
+		}
+		first = faceMoments(dice, rerolls, 0, explodeAt)
+		rest = faceMoments(dice, rerolls, successPenalty(dice), explodeAt)
+	}
+	return momentsOf(chainOf(first, rest, explosions))
+}
+
+// chainOf returns the moments of a chain of rolls that starts with a roll described by first and may continue with up
+// to the given number of further rolls, each described by rest.
+func chainOf(first, rest rollMoments, explosions int) chainMoments {
+	if explosions == 0 {
+		return rollMoments{sum: first.sum, sumSq: first.sumSq}.chain()
+	}
+	last := rollMoments{sum: rest.sum, sumSq: rest.sumSq}
+	return first.chain().then(rest.chain().repeat(explosions - 1)).then(last.chain())
+}
+
+// momentsOf returns the mean and variance of the total of a chain of rolls.
+func momentsOf(m chainMoments) (mean, variance float64) {
+	return m.a, max(m.b-m.a*m.a, 0)
+}
+
+// openEndedMoments returns the mean and variance of the total of a single open-ended die of the prepared Dice when it
+// may roll again up to the given number of times. A first roll that lands high or low adds or subtracts the total of a
+// chain of further rolls that continues while they land high.
+func openEndedMoments(dice Dice, explosions int) (mean, variance float64) {
+	plain := Dice{Count: 1, Sides: dice.Sides, Multiplier: 1}
+	all := faceMoments(plain, 0, 0, dice.Sides+1)
+	if explosions == 0 {
+		return momentsOf(chainOf(all, all, 0))
+	}
+	low, high := dice.openEndedThresholds()
+	chainMean, chainVariance := dieMoments(Dice{Count: 1, Sides: dice.Sides, Multiplier: 1, Explode: Explode,
+		ExplodeThreshold: high}, explosions-1, 0)
+	chainSecond := chainVariance + chainMean*chainMean
+	mean = all.sum
+	second := all.sumSq
+	if dice.OpenEnded != OpenEndedLow {
+		up := faceMoments(plain, 0, 0, high)
+		mean += up.explodes * chainMean
+		second += 2*up.explodeSum*chainMean + up.explodes*chainSecond
+	}
+	if dice.OpenEnded != OpenEndedHigh {
+		count, sum, _ := rangeSums(1, low)
+		chance := count / float64(dice.Sides)
+		mean -= chance * chainMean
+		second += -2*sum/float64(dice.Sides)*chainMean + chance*chainSecond
+	}
+	return mean, max(second-mean*mean, 0)
+}
+
+// openEndedMean returns the average total of a single open-ended die of the prepared Dice when it may roll again up to
+// the given number of times. A first roll that lands high or low starts a chain of further rolls that continues while
+// they land high, and the average total of that chain is added or subtracted accordingly.
+func openEndedMean(dice Dice, explosions int) float64 {
+	sides := float64(dice.Sides)
+	mean := (sides + 1) / 2
+	if explosions == 0 {
+		return mean
+	}
+	low, high := dice.openEndedThresholds()
+	q := float64(dice.Sides-high+1) / sides
+	chain := mean * (1 + expectedExplosions(q, explosions-1))
+	total := mean
+	if dice.OpenEnded != OpenEndedLow {
+		total += q * chain
+	}
+	if dice.OpenEnded != OpenEndedHigh {
+		total -= float64(low) / sides * chain
+	}
+	return total
+}
+
+// expectedExplosions returns the expected number of additional rolls of a die that explodes with probability q and may
+// explode up to the given number of times: q + q^2 + ... + q^explosions.
+func expectedExplosions(q float64, explosions int) float64 {
+	if q >= 1 {
+		return float64(explosions)
+	}
+	return q * (1 - math.Pow(q, float64(explosions))) / (1 - q)
+}
+
+// faceChances returns the chance of a single roll of a die of the prepared Dice landing on any one face at or below its
+// reroll threshold, and on any one face above it, when the roll may be rolled again up to the given number of rerolls.
+// A face at or below the threshold is only kept once every reroll has been used, while a face above it may be kept
+// after any of them.
+func faceChances(dice Dice, rerolls int) (low, high float64) {
+	sides := float64(dice.Sides)
+	if dice.Reroll == NoReroll || rerolls == 0 {
+		return 1 / sides, 1 / sides
+	}
+	p := float64(dice.RerollThreshold) / sides // The chance of a roll being rolled again
+	if p >= 1 {
+		return 1 / sides, 0
+	}
+	kept := math.Pow(p, float64(rerolls)) // The chance of reaching the final roll
+	return kept / sides, (1 - kept*p) / (1 - p) / sides
+}
+
+// faceMean returns the average face of a single roll of a die of the prepared Dice when the roll may be rolled again up
+// to the given number of rerolls.
+func faceMean(dice Dice, rerolls int) float64 {
+	if dice.Reroll == NoReroll || rerolls == 0 {
+		return float64(dice.Sides+1) / 2
+	}
+	low, high := faceChances(dice, rerolls)
+	lowSum := float64(dice.RerollThreshold) * float64(dice.RerollThreshold+1) / 2
+	return low*lowSum + high*(float64(dice.Sides)*float64(dice.Sides+1)/2-lowSum)
+}
+
+// chanceAtLeast returns the chance of a single roll of a die of the prepared Dice landing on the given face or higher
+// when the roll may be rolled again up to the given number of rerolls.
+func chanceAtLeast(dice Dice, rerolls, face int) float64 {
+	return chanceBetween(dice, rerolls, face, dice.Sides)
+}
+
+// chanceBetween returns the chance of a single roll of a die of the prepared Dice landing on a face from first to last,
+// inclusive, when the roll may be rolled again up to the given number of rerolls.
+func chanceBetween(dice Dice, rerolls, first, last int) float64 {
+	first = max(first, 1)
+	last = min(last, dice.Sides)
+	if first > last {
+		return 0
+	}
+	if dice.Reroll == NoReroll || rerolls == 0 {
+		return float64(last-first+1) / float64(dice.Sides)
+	}
+	low, high := faceChances(dice, rerolls)
+	threshold := dice.RerollThreshold
+	lowFaces := max(min(last, threshold)-first+1, 0)
+	highFaces := max(last-max(first, threshold+1)+1, 0)
+	return float64(lowFaces)*low + float64(highFaces)*high
+}
+
+// floorMean returns the floor of an average computed in floating point. An average that falls short of a whole number
+// by no more than the rounding error accumulated while computing it is treated as that whole number.
+func floorMean(mean float64) int {
+	return int(math.Floor(mean + (math.Abs(mean)+1)*1e-14))
+}
+
+// diceMean returns the average total of the dice the prepared Dice keeps, ignoring its modifier and multiplier. The
+// Dice must have at least one die.
+func diceMean(dice Dice, explosions, rerolls int) float64 {
+	if dice.Selection == SelectAll {
+		return float64(dice.Count) * dieMean(dice, explosions, rerolls)
+	}
+	if dice.addsDice() {
+		if added := newAddedDice(dice, explosions, rerolls); added != nil {
+			return added.mean()
+		}
+		// A single face is too large to build, so approximate by treating every kept die as an average face.
+		rolled := float64(dice.Count) * (1 + expectedExplosions(chanceAtLeast(dice, rerolls, dice.explodesAt()),
+			explosions))
+		from, to := dice.keptRangeOf(int(math.Round(rolled)))
+		return float64(to-from) * faceMean(dice, rerolls)
+	}
+	if dice.Explode == NoExplode && dice.Reroll == NoReroll && dice.OpenEnded == NoOpenEnded &&
+		!selectionFits(dice.Sides, dice.kept()) {
+		return selectedMean(dice, uniformDie(dice.Sides))
+	}
+	if die := dieDistribution(dice, explosions, rerolls); die != nil {
+		return keptMean(dice, die)
+	}
+	// The distribution of a single die is too large to build, so approximate by treating every kept die as average.
+	return float64(dice.kept()) * dieMean(dice, explosions, rerolls)
+}
+
+// dieTotals describes the totals a single die can produce.
+type dieTotals interface {
+	// bounds returns the lowest and highest totals.
+	bounds() (low, high int)
+	// meanTail returns the chance of a die producing a total of at least v, averaged over each v in the block of
+	// totals that starts at start and has the given width.
+	meanTail(start, width int) float64
+}
+
+// uniformDie describes an ordinary die with the given number of sides.
+type uniformDie int
+
+func (u uniformDie) bounds() (low, high int) {
+	return 1, int(u)
+}
+
+func (u uniformDie) meanTail(start, width int) float64 {
+	return (float64(int(u)-start+1) - float64(width-1)/2) / float64(u)
+}
+
+// keptMean returns the average total of the dice the Selection keeps, ignoring the modifier and multiplier, where each
+// die is distributed as die. The mean of the exact distribution is used whenever it can be computed, so that it agrees
+// with Distribution.Mean; larger pools fall back to selectedMean.
+func keptMean(dice Dice, die *pmf) float64 {
+	if totals := selectedDistribution(die, dice); totals != nil {
+		return totals.mean()
+	}
+	return selectedMean(dice, die)
+}
+
+// selectedMean returns the expected sum of the dice the Selection keeps, ignoring the modifier and multiplier, where
+// each die produces totals as described by die. The Dice must be normalized and have at least one die.
+//
+// Sorting the n dice into ascending order, the kept dice are those at the indexes from through to-1. For any total v,
+// the B(v) dice reaching v or higher occupy the top B(v) indexes, so min(B(v), n-from) - min(B(v), n-to) of them are
+// kept. Summing that over every total above the lowest, plus the lowest total for each kept die, yields the kept
+// total, and since each B(v) is binomially distributed, the expectation of every term can be computed directly.
+func selectedMean(dice Dice, die dieTotals) float64 {
+	from, to := dice.keptRange()
+	if from == to {
+		return 0
+	}
+	n := dice.Count
+	low, high := die.bounds()
+	step := max(1, (high-low+maxExactThresholds-1)/maxExactThresholds)
+	total := float64(to-from) * float64(low)
+	for v := low + 1; v <= high; v += step {
+		width := min(step, high-v+1)
+		p := die.meanTail(v, width)
+		total += float64(width) * (expectedMinBinomial(n, p, n-from) - expectedMinBinomial(n, p, n-to))
+	}
+	return total
+}
+
+// expectedMinBinomial returns the expected value of min(B, k), where B is the number of successes in n trials that each
+// succeed with probability p.
+func expectedMinBinomial(n int, p float64, k int) float64 {
+	switch {
+	case k <= 0 || p <= 0:
+		return 0
+	case k >= n:
+		return float64(n) * p
+	case p >= 1:
+		return float64(k)
+	}
+	if k <= maxExactBinomialTerms {
+		// E[min(B,k)] = k - sum over i < k of (k-i)*P(B=i)
+		result := float64(k)
+		for i := range k {
+			result -= float64(k-i) * binomialProbability(n, i, p)
+		}
+		return result
+	}
+	if n-k <= maxExactBinomialTerms {
+		// E[min(B,k)] = E[B] - sum over i > k of (i-k)*P(B=i)
+		result := float64(n) * p
+		for i := k + 1; i <= n; i++ {
+			result -= float64(i-k) * binomialProbability(n, i, p)
+		}
+		return result
+	}
+	// Both tails are too long to sum, so n is large and B is very nearly normal. For a normal X with mean mu and
+	// standard deviation sigma, E[max(X-k,0)] = sigma*phi(z) + (mu-k)*(1-Phi(z)) where z = (k-mu)/sigma.
+	mu := float64(n) * p
+	sigma := math.Sqrt(mu * (1 - p))
+	z := (float64(k) - mu) / sigma
+	excess := sigma*math.Exp(-z*z/2)/math.Sqrt(2*math.Pi) + (mu-float64(k))*0.5*math.Erfc(z/math.Sqrt2)
+	return mu - excess
+}
+
+// binomialProbability returns the probability of exactly i successes in n trials that each succeed with probability p,
+// where 0 < p < 1. It works in log space so that very large n neither overflows nor underflows prematurely.
+func binomialProbability(n, i int, p float64) float64 {
+	lnN, _ := math.Lgamma(float64(n + 1))
+	lnI, _ := math.Lgamma(float64(i + 1))
+	lnNI, _ := math.Lgamma(float64(n - i + 1))
+	return math.Exp(lnN - lnI - lnNI + float64(i)*math.Log(p) + float64(n-i)*math.Log1p(-p))
+}
+
+// pmf is a probability mass function over the consecutive integers starting at lo.
+type pmf struct {
+	lo    int
+	probs []float64
+	// tails holds the running sums of the chance of reaching each total, built on demand by meanTail.
+	tails []float64
+}
+
+func newPMF(lo, hi int) *pmf {
+	return &pmf{lo: lo, probs: make([]float64, hi-lo+1)}
+}
+
+func (d *pmf) bounds() (low, high int) {
+	return d.lo, d.lo + len(d.probs) - 1
+}
+
+func (d *pmf) mean() float64 {
+	var mean float64
+	for i, p := range d.probs {
+		mean += p * float64(d.lo+i)
+	}
+	return mean
+}
+
+func (d *pmf) meanTail(start, width int) float64 {
+	if d.tails == nil {
+		// tails[i] is the sum of P(X >= lo+j) for every j < i.
+		d.tails = make([]float64, len(d.probs)+1)
+		var tail float64
+		for i := len(d.probs) - 1; i >= 0; i-- {
+			tail += d.probs[i]
+			d.tails[i+1] = tail
+		}
+		for i := 1; i < len(d.tails); i++ {
+			d.tails[i] += d.tails[i-1]
+		}
+	}
+	i := start - d.lo
+	return (d.tails[i+width] - d.tails[i]) / float64(width)
+}
+
+// dieDistribution returns the probability mass function of the total of a single die of the prepared Dice when it may
+// explode up to the given number of times and each roll may be rolled again up to the given number of rerolls, or nil
+// if it would be too large to build. Explosion chains whose chance of occurring is negligible are not followed.
+func dieDistribution(dice Dice, explosions, rerolls int) *pmf {
+	if dice.countsSuccesses() {
+		return successDistribution(dice, explosions, rerolls)
+	}
+	if dice.OpenEnded != NoOpenEnded {
+		return openEndedDistribution(dice, explosions)
+	}
+	sides := dice.Sides
+	if sides > maxSupport {
+		return nil
+	}
+	if dice.Explode == NoExplode {
+		explosions = 0
+	}
+	threshold := dice.explodesAt()
+	exploding := sides - threshold + 1
+	if explosions > 0 {
+		// Check the size before building anything, as a die too large to build may be rolled many times over.
+		if q := chanceAtLeast(dice, rerolls, threshold); q < 1 {
+			explosions = min(explosions, int(math.Ceil(math.Log(negligible)/math.Log(q))))
+		}
+		if explosions >= maxSupport/sides || explosions*exploding*(explosions+1)*sides > maxWork {
+			return nil
+		}
+	}
+	face := newPMF(1, sides)
+	low, high := faceChances(dice, rerolls)
+	for i := range face.probs {
+		if i < dice.RerollThreshold {
+			face.probs[i] = low
+		} else {
+			face.probs[i] = high
+		}
+	}
+	if explosions == 0 {
+		return face
+	}
+	var penalty int
+	if dice.Explode == Penetrate {
+		penalty = 1
+	}
+	// Build the chain from its deepest roll upward. The deepest roll never explodes further, and every roll after the
+	// first is reduced by the penalty.
+	level := face
+	if explosions > 0 {
+		level = shiftPMF(face, -penalty)
+	}
+	for depth := explosions - 1; depth >= 0; depth-- {
+		p := penalty
+		if depth == 0 {
+			p = 0
+		}
+		levelLow, levelHigh := level.bounds()
+		lo := threshold - p + levelLow
+		hi := sides - p + levelHigh
+		if threshold > 1 {
+			lo = min(lo, 1-p)
+			hi = max(hi, threshold-1-p)
+		}
+		next := newPMF(lo, hi)
+		for f := 1; f <= sides; f++ {
+			chance := face.probs[f-1]
+			if f < threshold {
+				next.probs[f-p-lo] += chance
+				continue
+			}
+			offset := f - p + levelLow - lo
+			for i, v := range level.probs {
+				next.probs[offset+i] += chance * v
+			}
+		}
+		level = next
+	}
+	return level
+}
+
+// openEndedDistribution returns the probability mass function of the total of a single open-ended die of the prepared
+// Dice when it may roll again up to the given number of times, or nil if it would be too large to build. The chain of
+// further rolls started by a first roll that lands high or low behaves like a die that explodes on the high faces, and
+// is added to or subtracted from that first roll.
+func openEndedDistribution(dice Dice, explosions int) *pmf {
+	sides := dice.Sides
+	if sides > maxSupport {
+		return nil
+	}
+	face := newPMF(1, sides)
+	for i := range face.probs {
+		face.probs[i] = 1 / float64(sides)
+	}
+	if explosions == 0 {
+		return face
+	}
+	low, high := dice.openEndedThresholds()
+	chain := dieDistribution(Dice{Count: 1, Sides: sides, Multiplier: 1, Explode: Explode, ExplodeThreshold: high},
+		explosions-1, 0)
+	if chain == nil {
+		return nil
+	}
+	chainLow, chainHigh := chain.bounds()
+	lo, hi := 1, sides
+	if dice.OpenEnded != OpenEndedLow {
+		hi += chainHigh
+	}
+	if dice.OpenEnded != OpenEndedHigh {
+		lo -= chainHigh
+	}
+	if hi-lo >= maxSupport {
+		return nil
+	}
+	d := newPMF(lo, hi)
+	for f := 1; f <= sides; f++ {
+		chance := face.probs[f-1]
+		switch {
+		case f >= high && dice.OpenEnded != OpenEndedLow:
+			for i, v := range chain.probs {
+				d.probs[f+chainLow+i-lo] += chance * v
+			}
+		case f <= low && dice.OpenEnded != OpenEndedHigh:
+			for i, v := range chain.probs {
+				d.probs[f-chainLow-i-lo] += chance * v
+			}
+		default:
+			d.probs[f-lo] += chance
+		}
+	}
+	return d
+}
+
+func shiftPMF(d *pmf, delta int) *pmf {
+	return &pmf{lo: d.lo + delta, probs: d.probs}
+}
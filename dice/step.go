@@ -0,0 +1,190 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"slices"
+
+	"github.com/richardwilkes/toolbox/v2/errs"
+)
+
+// StepLimit determines what happens to a StepDie that steps past an end of its StepLadder.
+type StepLimit uint8
+
+// Possible StepLimit values.
+const (
+	// StepHolds leaves the die at the end of the ladder.
+	StepHolds StepLimit = iota
+	// StepModifies adjusts the die's Modifier by 1 for each step past the end of the ladder, as with the d12+1 and d4-1
+	// of Savage Worlds.
+	StepModifies
+	// StepRemoves removes the die when it steps down past the smallest die, as in Cortex Prime. Stepping up a removed
+	// die brings back the smallest die. Past the largest die, it behaves as StepHolds.
+	StepRemoves
+)
+
+// StepDie is a single die whose type steps up and down a StepLadder, such as the d4, d6, d8, d10 and d12 of Cortex
+// Prime and Savage Worlds.
+type StepDie struct {
+	// Sides is the die's number of sides, one of those on its StepLadder, or 0 if the die has been removed.
+	Sides int
+	// Modifier is the adjustment for steps taken past the end of the StepLadder, such as the +1 of d12+1. It is only
+	// non-zero for the largest or smallest die of the ladder.
+	Modifier int
+}
+
+// StepLadder defines the die types a StepDie steps through and what happens at each end.
+type StepLadder struct {
+	// Sides holds the number of sides of each die on the ladder, from smallest to largest.
+	Sides []int
+	// Above determines what happens to a die that steps up past the largest die.
+	Above StepLimit
+	// Below determines what happens to a die that steps down past the smallest die.
+	Below StepLimit
+}
+
+// DefaultStepLadder returns the d4, d6, d8, d10 and d12 ladder of Savage Worlds, where stepping past the largest die
+// gives d12+1, d12+2 and so on, and stepping past the smallest gives d4-1, d4-2 and so on.
+func DefaultStepLadder() *StepLadder {
+	return &StepLadder{Sides: []int{4, 6, 8, 10, 12}, Above: StepModifies, Below: StepModifies}
+}
+
+// CortexStepLadder returns the d4, d6, d8, d10 and d12 ladder of Cortex Prime, where a d12 cannot step up any further
+// and a d4 that steps down is removed.
+func CortexStepLadder() *StepLadder {
+	return &StepLadder{Sides: []int{4, 6, 8, 10, 12}, Above: StepHolds, Below: StepRemoves}
+}
+
+// Valid returns an error if the ladder has no dice or its dice are not in ascending order of sides.
+func (l *StepLadder) Valid() error {
+	if len(l.Sides) == 0 {
+		return errs.New("step ladder must have at least one die")
+	}
+	for i, sides := range l.Sides {
+		if sides < 1 {
+			return errs.Newf("step ladder die %d must have at least one side", i)
+		}
+		if i > 0 && sides <= l.Sides[i-1] {
+			return errs.New("step ladder dice must be in ascending order of sides")
+		}
+	}
+	return nil
+}
+
+// StepUp returns the die one step up the ladder.
+func (l *StepLadder) StepUp(die StepDie) StepDie {
+	return l.Step(die, 1)
+}
+
+// StepDown returns the die one step down the ladder.
+func (l *StepLadder) StepDown(die StepDie) StepDie {
+	return l.Step(die, -1)
+}
+
+// Step returns the die moved the given number of steps up the ladder, or down it if steps is negative. A die whose
+// Sides are not on the ladder is first moved to the smallest die on the ladder with at least as many sides, or to the
+// largest die if there is none.
+func (l *StepLadder) Step(die StepDie, steps int) StepDie {
+	if len(l.Sides) == 0 {
+		return die
+	}
+	return l.dieAt(saturatingAdd(l.position(die), steps))
+}
+
+// position returns the position of the die on the ladder: the index of its Sides, adjusted by its Modifier. A removed
+// die is at -1.
+func (l *StepLadder) position(die StepDie) int {
+	if die.Sides == 0 {
+		return -1
+	}
+	i, _ := slices.BinarySearch(l.Sides, die.Sides)
+	i = min(i, len(l.Sides)-1)
+	switch {
+	case i == len(l.Sides)-1 && die.Modifier > 0 && l.Above == StepModifies:
+		return saturatingAdd(i, die.Modifier)
+	case i == 0 && die.Modifier < 0 && l.Below == StepModifies:
+		return saturatingAdd(i, die.Modifier)
+	default:
+		return i
+	}
+}
+
+// dieAt returns the die at the given position on the ladder.
+func (l *StepLadder) dieAt(pos int) StepDie {
+	last := len(l.Sides) - 1
+	switch {
+	case pos > last:
+		if l.Above == StepModifies {
+			return StepDie{Sides: l.Sides[last], Modifier: pos - last}
+		}
+		return StepDie{Sides: l.Sides[last]}
+	case pos < 0:
+		switch l.Below {
+		case StepModifies:
+			return StepDie{Sides: l.Sides[0], Modifier: pos}
+		case StepRemoves:
+			return StepDie{}
+		default:
+			return StepDie{Sides: l.Sides[0]}
+		}
+	default:
+		return StepDie{Sides: l.Sides[pos]}
+	}
+}
+
+// FromDice returns the StepDie equivalent to the Dice. An error is returned if the Dice is not a single die on the
+// ladder, or has a modifier the ladder could not have produced, or does anything other than add a modifier to a single
+// die. A Dice without any dice is a removed die, when the ladder removes dice.
+func (l *StepLadder) FromDice(dice Dice) (StepDie, error) {
+	if err := l.Valid(); err != nil {
+		return StepDie{}, err
+	}
+	dice = dice.normalize()
+	if dice == (Dice{Multiplier: 1}) && l.Below == StepRemoves {
+		return StepDie{}, nil
+	}
+	die := StepDie{Sides: dice.Sides, Modifier: dice.Modifier}
+	if dice != die.Dice() {
+		return StepDie{}, errs.Newf("%s is not a single die plus a modifier", dice.format(false))
+	}
+	i, found := slices.BinarySearch(l.Sides, dice.Sides)
+	if !found {
+		return StepDie{}, errs.Newf("%s is not a die on the step ladder", dice.format(false))
+	}
+	if die.Modifier == 0 || (die.Modifier > 0 && i == len(l.Sides)-1 && l.Above == StepModifies) ||
+		(die.Modifier < 0 && i == 0 && l.Below == StepModifies) {
+		return die, nil
+	}
+	return StepDie{}, errs.Newf("%s has a modifier the step ladder cannot produce", dice.format(false))
+}
+
+// Dice returns the Dice equivalent to the die.
+func (d StepDie) Dice() Dice {
+	if d.Sides < 1 {
+		return Dice{Multiplier: 1}
+	}
+	return Dice{Count: 1, Sides: d.Sides, Modifier: d.Modifier, Multiplier: 1}
+}
+
+// String implements fmt.Stringer.
+func (d StepDie) String() string {
+	return d.Dice().format(false)
+}
+
+// RollStep rolls the die.
+func (r *Roller) RollStep(die StepDie) int {
+	return r.Roll(die.Dice())
+}
+
+// FormatStep formats the die for display.
+func (r *Roller) FormatStep(die StepDie) string {
+	return r.Format(die.Dice())
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestStepLadder(t *testing.T) {
+	c := check.New(t)
+	savage := dice.DefaultStepLadder()
+	cortex := dice.CortexStepLadder()
+	holds := &dice.StepLadder{Sides: []int{4, 6, 8, 10, 12}}
+	for i, one := range []struct {
+		Ladder   *dice.StepLadder
+		Die      dice.StepDie
+		Steps    int
+		Expected string
+	}{
+		{savage, dice.StepDie{Sides: 4}, 1, "d6"},                                            // 0
+		{savage, dice.StepDie{Sides: 10}, 1, "d12"},                                          // 1
+		{savage, dice.StepDie{Sides: 12}, 1, "d12+1"},                                        // 2
+		{savage, dice.StepDie{Sides: 12, Modifier: 1}, 1, "d12+2"},                           // 3
+		{savage, dice.StepDie{Sides: 12, Modifier: 2}, -1, "d12+1"},                          // 4
+		{savage, dice.StepDie{Sides: 12, Modifier: 1}, -2, "d10"},                            // 5
+		{savage, dice.StepDie{Sides: 4}, -2, "d4-2"},                                         // 6
+		{savage, dice.StepDie{Sides: 4, Modifier: -2}, 3, "d6"},                              // 7
+		{savage, dice.StepDie{Sides: 8}, 0, "d8"},                                            // 8
+		{savage, dice.StepDie{Sides: 8}, -3, "d4-1"},                                         // 9
+		{cortex, dice.StepDie{Sides: 12}, 1, "d12"},                                          // 10
+		{cortex, dice.StepDie{Sides: 4}, -1, "0"},                                            // 11
+		{cortex, dice.StepDie{}, -1, "0"},                                                    // 12
+		{cortex, dice.StepDie{}, 1, "d4"},                                                    // 13
+		{cortex, dice.StepDie{Sides: 6}, -4, "0"},                                            // 14
+		{holds, dice.StepDie{Sides: 4}, -1, "d4"},                                            // 15
+		{holds, dice.StepDie{Sides: 12}, 5, "d12"},                                           // 16
+		{savage, dice.StepDie{Sides: 7}, 1, "d10"},                                           // 17 - off the ladder
+		{savage, dice.StepDie{Sides: 20}, -1, "d10"},                                         // 18 - off the ladder
+		{savage, dice.StepDie{Sides: 12}, math.MaxInt, "d12+" + fmt.Sprint(math.MaxInt-1-4)}, // 19
+	} {
+		desc := fmt.Sprintf("Table index %d", i)
+		c.Equal(one.Expected, one.Ladder.Step(one.Die, one.Steps).String(), desc)
+	}
+
+	die := dice.StepDie{Sides: 8}
+	c.Equal(dice.StepDie{Sides: 10}, savage.StepUp(die))
+	c.Equal(dice.StepDie{Sides: 6}, savage.StepDown(die))
+	c.Equal(die, savage.StepDown(savage.StepUp(die)))
+}
+
+func TestStepLadderValid(t *testing.T) {
+	c := check.New(t)
+	c.NoError(dice.DefaultStepLadder().Valid())
+	c.NoError(dice.CortexStepLadder().Valid())
+	c.NoError((&dice.StepLadder{Sides: []int{6}}).Valid())
+	c.HasError((&dice.StepLadder{}).Valid())
+	c.HasError((&dice.StepLadder{Sides: []int{4, 8, 6}}).Valid())
+	c.HasError((&dice.StepLadder{Sides: []int{4, 4}}).Valid())
+	c.HasError((&dice.StepLadder{Sides: []int{0, 4}}).Valid())
+}
+
+func TestStepDieDice(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, topFaceRandomizer{}, false, false)
+	savage := dice.DefaultStepLadder()
+	cortex := dice.CortexStepLadder()
+	for i, one := range []struct {
+		Ladder *dice.StepLadder
+		Spec   string
+		Valid  bool
+	}{
+		{savage, "d8", true},     // 0
+		{savage, "1d12+2", true}, // 1
+		{savage, "d4-1", true},   // 2
+		{savage, "d8+1", false},  // 3 - only the largest die has a bonus
+		{savage, "d12-1", false}, // 4 - only the smallest die has a penalty
+		{savage, "d20", false},   // 5 - not on the ladder
+		{savage, "2d6", false},   // 6
+		{savage, "d6x2", false},  // 7
+		{savage, "d6!", false},   // 8
+		{savage, "0", false},     // 9 - removed dice are not part of this ladder
+		{cortex, "0", true},      // 10
+		{cortex, "d12+1", false}, // 11
+		{cortex, "d4-1", false},  // 12
+		{cortex, "3", false},     // 13
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Spec)
+		d := r.Parse(one.Spec)
+		die, err := one.Ladder.FromDice(d)
+		if !one.Valid {
+			c.HasError(err, desc)
+			continue
+		}
+		c.NoError(err, desc)
+		c.Equal(d, die.Dice(), desc)
+		c.Equal(r.Format(d), r.FormatStep(die), desc)
+		c.Equal(r.Roll(d), r.RollStep(die), desc)
+	}
+	_, err := (&dice.StepLadder{}).FromDice(r.Parse("d6"))
+	c.HasError(err)
+
+	c.Equal(13, r.RollStep(dice.StepDie{Sides: 12, Modifier: 1}))
+	c.Equal(0, r.RollStep(dice.StepDie{}))
+	r = newRoller(c, nil, true, false)
+	c.Equal("1d12+1", r.FormatStep(dice.StepDie{Sides: 12, Modifier: 1}))
+	c.Equal("1d", r.FormatStep(dice.StepDie{Sides: 6}))
+}
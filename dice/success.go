@@ -0,0 +1,225 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"math"
+	"slices"
+)
+
+// faceGroup describes a run of consecutive faces of a die that counts successes, all of which score the same number of
+// successes and either all explode or all do not.
+type faceGroup struct {
+	score    int
+	explodes bool
+	chance   float64
+}
+
+// faceGroups splits the faces of a die that counts successes into groups, for a roll reduced by the given penalty
+// before it is scored that may be rolled again up to the given number of rerolls. No more than a handful of groups are
+// ever needed, however many sides the die has.
+func (dice Dice) faceGroups(penalty, rerolls int) []faceGroup {
+	bounds := []int{1, dice.Sides + 1, dice.SuccessThreshold + penalty}
+	if dice.Reroll != NoReroll {
+		bounds = append(bounds, dice.RerollThreshold+1)
+	}
+	if dice.Explode != NoExplode {
+		bounds = append(bounds, dice.explodesAt())
+	}
+	if dice.DoubleThreshold != 0 {
+		bounds = append(bounds, dice.DoubleThreshold+penalty)
+	}
+	if dice.FailureThreshold != 0 {
+		bounds = append(bounds, dice.FailureThreshold+1+penalty)
+	}
+	for i, b := range bounds {
+		bounds[i] = min(max(b, 1), dice.Sides+1)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+	groups := make([]faceGroup, 0, len(bounds)-1)
+	for i := 1; i < len(bounds); i++ {
+		face := bounds[i-1]
+		groups = append(groups, faceGroup{
+			score:    dice.score(face - penalty),
+			explodes: dice.Explode != NoExplode && face >= dice.explodesAt(),
+			chance:   chanceBetween(dice, rerolls, face, bounds[i]-1),
+		})
+	}
+	return groups
+}
+
+// successPenalty returns the amount each roll after the first is reduced by before it is scored.
+func successPenalty(dice Dice) int {
+	if dice.Explode == Penetrate {
+		return 1
+	}
+	return 0
+}
+
+// scoreBounds holds the best scores, as judged by some comparison, among a die's face groups.
+type scoreBounds struct {
+	explode  int // The best score among the faces that explode
+	stop     int // The best score among the faces that do not explode, if canStop is true
+	any      int // The best score among all faces
+	canStop  bool
+	anyFound bool
+}
+
+func bestScores(groups []faceGroup, pick func(a, b int) int) scoreBounds {
+	var b scoreBounds
+	explodeFound := false
+	for _, g := range groups {
+		if g.explodes {
+			if explodeFound {
+				b.explode = pick(b.explode, g.score)
+			} else {
+				b.explode = g.score
+				explodeFound = true
+			}
+		} else {
+			if b.canStop {
+				b.stop = pick(b.stop, g.score)
+			} else {
+				b.stop = g.score
+				b.canStop = true
+			}
+		}
+		if b.anyFound {
+			b.any = pick(b.any, g.score)
+		} else {
+			b.any = g.score
+			b.anyFound = true
+		}
+	}
+	return b
+}
+
+// successRange returns the fewest and most successes a single die of the prepared Dice can score when it may explode
+// up to the given number of times.
+func successRange(dice Dice, explosions int) (low, high int) {
+	return successBound(dice, explosions, func(a, b int) int { return min(a, b) }),
+		successBound(dice, explosions, func(a, b int) int { return max(a, b) })
+}
+
+// successBound returns the best number of successes, as judged by pick, that a single die of the prepared Dice can
+// score when it may explode up to the given number of times.
+//
+// Once the first roll explodes, the chain either stops on a face that does not explode after j more explosions, or
+// explodes every time it is permitted to and then ends on any face. Every exploding roll after the first scores the
+// same best amount, so the best stopping point is linear in j and only its ends need to be considered.
+func successBound(dice Dice, explosions int, pick func(a, b int) int) int {
+	first := bestScores(dice.faceGroups(0, 0), pick)
+	if dice.Explode == NoExplode || explosions == 0 {
+		return first.any
+	}
+	rest := bestScores(dice.faceGroups(successPenalty(dice), 0), pick)
+	further := explosions - 1 // The explosions still permitted once the first roll has exploded
+	chain := further*rest.explode + rest.any
+	if rest.canStop {
+		chain = pick(chain, rest.stop)
+		if further > 0 {
+			chain = pick(chain, (further-1)*rest.explode+rest.stop)
+		}
+	}
+	best := first.explode + chain
+	if first.canStop {
+		best = pick(best, first.stop)
+	}
+	return best
+}
+
+// successMean returns the average number of successes a single die of the prepared Dice scores when it may explode up
+// to the given number of times and each roll may be rolled again up to the given number of rerolls.
+func successMean(dice Dice, explosions, rerolls int) float64 {
+	first := dice.faceGroups(0, rerolls)
+	mean := groupMean(first)
+	if dice.Explode == NoExplode || explosions == 0 {
+		return mean
+	}
+	return mean + groupMean(dice.faceGroups(successPenalty(dice), rerolls))*
+		expectedExplosions(explodeChance(first), explosions)
+}
+
+func groupMean(groups []faceGroup) float64 {
+	var mean float64
+	for _, g := range groups {
+		mean += float64(g.score) * g.chance
+	}
+	return mean
+}
+
+func explodeChance(groups []faceGroup) float64 {
+	var chance float64
+	for _, g := range groups {
+		if g.explodes {
+			chance += g.chance
+		}
+	}
+	return chance
+}
+
+// successDistribution returns the probability mass function of the number of successes a single die of the prepared
+// Dice scores when it may explode up to the given number of times and each roll may be rolled again up to the given
+// number of rerolls, or nil if it would be too large to build. Explosion chains whose chance of occurring is negligible
+// are not followed.
+func successDistribution(dice Dice, explosions, rerolls int) *pmf {
+	first := dice.faceGroups(0, rerolls)
+	rest := first
+	if dice.Explode == NoExplode {
+		explosions = 0
+	} else {
+		rest = dice.faceGroups(successPenalty(dice), rerolls)
+		if q := explodeChance(first); q < 1 {
+			explosions = min(explosions, int(math.Ceil(math.Log(negligible)/math.Log(q))))
+		}
+	}
+	// Each roll scores from -1 to 2 successes, so the rolls of a single die span no more than 3*(explosions+1)+1
+	// values.
+	if explosions >= maxSupport/3-1 || (explosions+1)*max(len(first), len(rest))*(3*explosions+4) > maxWork {
+		return nil
+	}
+	lo := -(explosions + 1)
+	size := 3*(explosions+1) + 1
+	// live holds the chance of each score so far among the chains that are still exploding.
+	live := make([]float64, size)
+	live[-lo] = 1
+	done := make([]float64, size)
+	for depth := 0; depth <= explosions; depth++ {
+		groups := rest
+		if depth == 0 {
+			groups = first
+		}
+		next := make([]float64, size)
+		for _, g := range groups {
+			target := done
+			if g.explodes && depth < explosions {
+				target = next
+			}
+			for i, v := range live {
+				if v != 0 {
+					target[i+g.score] += v * g.chance
+				}
+			}
+		}
+		live = next
+	}
+	// Trim the scores that cannot occur.
+	start := 0
+	for start < len(done)-1 && done[start] == 0 {
+		start++
+	}
+	end := len(done)
+	for end > start+1 && done[end-1] == 0 {
+		end--
+	}
+	return &pmf{lo: lo + start, probs: done[start:end]}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestSuccess(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text     string
+		Expected string
+		Minimum  int
+		Maximum  int
+		Average  int
+	}{
+		{"8d10>=7", "8d10>=7", 0, 8, 3},                 // 0 - 3.2
+		{"8d10>7", "8d10>=7", 0, 8, 3},                  // 1 - '>' is inclusive, just as it is for explosions
+		{"5d10>=8dbl10", "5d10>=8dbl10", 0, 10, 2},      // 2
+		{"5d10>=8f1", "5d10>=8f1", -5, 5, 1},            // 3
+		{"5d10>=8f", "5d10>=8f1", -5, 5, 1},             // 4 - the failure face defaults to 1
+		{"5d10>=8f<2", "5d10>=8f2", -5, 5, 0},           // 5 - 0.5
+		{"3d10>=7!", "3d10>=7!", 0, 303, 1},             // 6 - every roll of an exploding die counts
+		{"d10>=7dbl10!", "d10>=7dbl10!", 0, 202, 0},     // 7
+		{"8d10>=7+2x2", "8d10>=7+2x2", 4, 20, 10},       // 8
+		{"8d10>=11", "8d10>=11", 0, 0, 0},               // 9
+		{"8d10>=20", "8d10>=11", 0, 0, 0},               // 10 - no face can reach either threshold
+		{"8d10>=7dbl3", "8d10>=7dbl7", 0, 16, 6},        // 11 - doubles start no lower than successes
+		{"8d10>=7f9", "8d10>=7f6", -8, 8, -2},           // 12 - failures stop short of successes
+		{"4d6kh3>=5", "4d6kh3", 3, 18, 12},              // 13 - successes cannot follow a selection
+		{"4d6>=5kh3", "4d6>=5", 0, 4, 1},                // 14 - nor a selection follow successes
+		{"4d6>=5!!", "4d6>=5", 0, 4, 1},                 // 15 - nor can successes compound
+		{"d10>=7!p", "d10>=7!p", 0, 101, 0},             // 16
+		{"d10>=7f1!p", "d10>=7f1!p", -1, 101, 0},        // 17
+		{"d6>=1!>1", "d6>=1!>1", 101, 101, 101},         // 18 - every face explodes and succeeds
+		{"8D10>=7DBL10F1", "8d10>=7dbl10f1", -8, 16, 3}, // 19
+		{"8d10dbl10", "8d10", 8, 80, 44},                // 20 - doubles need a success threshold
+		{"8d10>=0", "8d10>=1", 8, 8, 8},                 // 21
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		r := newRoller(c, nil, false, false)
+		d := r.Parse(one.Text)
+		c.Equal(one.Expected, r.Format(d), desc)
+		c.Equal(one.Minimum, r.Minimum(d), desc)
+		c.Equal(one.Maximum, r.Maximum(d), desc)
+		c.Equal(one.Average, r.Average(d), desc)
+		c.True(r.IsEquivalent(d, r.Parse(r.Format(d))), desc)
+		for range 100 {
+			v := r.Roll(d)
+			c.True(v >= one.Minimum && v <= one.Maximum, "%s: roll %d outside [%d,%d]", desc, v, one.Minimum,
+				one.Maximum)
+		}
+	}
+}
+
+func TestSuccessRoll(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Text      string
+		Expected  string
+		Total     int
+		Successes int
+	}{
+		{"4d10>=7", "4d10>=7 → [10{1}, 7{1}, 1, 4] = 2", 2, 2},                               // 0
+		{"4d10>=7dbl10", "4d10>=7dbl10 → [10{2}, 7{1}, 1, 4] = 3", 3, 3},                     // 1
+		{"4d10>=7dbl10f1", "4d10>=7dbl10f1 → [10{2}, 7{1}, 1{-1}, 4] = 2", 2, 2},             // 2
+		{"4d10>=7f1!", "4d10>=7f1! → [10!{1}, 7{1}, 1{-1}, 4, 10!{1}, 3] = 2", 2, 2},         // 3
+		{"4d10>=7!p", "4d10>=7!p → [10!+7-1{1}, 1, 4, 10!+3-1{1}] = 2", 2, 2},                // 4
+		{"4d10>=7+1x2", "4d10>=7+1x2 → [10{1}, 7{1}, 1, 4] + 1 = 3 x 2 = 6", 6, 2},           // 5
+		{"4d10>=7dbl8!>7", "4d10>=7dbl8!>7 → [10!{2}, 7!{1}, 1, 4, 10!{2}, 3, 6] = 5", 5, 5}, // 6
+		{"4d10>=8!p", "4d10>=8!p → [10!+7-1{1}, 1, 4, 10!+3-1{1}] = 2", 2, 2},                // 7 - the penalty makes the 7 miss
+		{"4d10>=5f<3", "4d10>=5f3 → [10{1}, 7{1}, 1{-1}, 4] = 1", 1, 1},                      // 8
+		{"4d10>=1", "4d10>=1 → [10{1}, 7{1}, 1{1}, 4{1}] = 4", 4, 4},                         // 9
+		{"4d10>=7-5", "4d10>=7-5 → [10{1}, 7{1}, 1, 4] - 5 = -3", -3, 2},                     // 10
+		{"2d10>=7dbl10f1!x3", "2d10>=7dbl10f1!x3 → [10!{2}, 7{1}, 1{-1}] = 2 x 3 = 6", 6, 2}, // 11
+		{"4d10>=7f2!p", "4d10>=7f2!p → [10!+7-1{1}, 1{-1}, 4, 10!+3-1] = 0", 0, 0},           // 12 - the penalty makes the 3 fail
+		{"4d10>=11", "4d10>=11 → [10, 7, 1, 4] = 0", 0, 0},                                   // 13
+		{"4d10>=7dbl10f1!>11", "4d10>=7dbl10f1 → [10{2}, 7{1}, 1{-1}, 4] = 2", 2, 2},         // 14
+		{"3d10>=7!", "3d10>=7! → [10!{1}, 7{1}, 1, 4] = 2", 2, 2},                            // 15
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		// Rolls 10, 7, 1, 4, 10, 3, 6
+		rnd := &sequenceRandomizer{values: []int{9, 6, 0, 3, 9, 2, 5}}
+		r := newRoller(c, rnd, false, false)
+		d := r.Parse(one.Text)
+		res := r.RollDetailed(d)
+		c.Equal(one.Expected, res.String(), desc)
+		c.Equal(one.Total, res.Total, desc)
+		c.Equal(one.Successes, res.Successes(), desc)
+		rnd.next = 0
+		c.Equal(res.Total, r.Roll(d), desc)
+	}
+}
+
+func TestSuccessDistribution(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for _, text := range []string{"4d6>=4", "4d6>=4dbl6", "4d6>=5dbl6f1", "3d10>=8f2+1", "5d4>=3f1x2", "3d6>=7"} {
+		d := r.Parse(text)
+		dist, err := r.Distribution(d)
+		c.NoError(err, text)
+		want := make(map[int]float64)
+		var outcomes float64
+		enumerate(d.Count, d.Sides, func(rolls []int) {
+			var successes int
+			for _, v := range rolls {
+				switch {
+				case v >= d.SuccessThreshold:
+					successes++
+					if d.DoubleThreshold != 0 && v >= d.DoubleThreshold {
+						successes++
+					}
+				case v <= d.FailureThreshold:
+					successes--
+				}
+			}
+			want[(successes+d.Modifier)*d.Multiplier]++
+			outcomes++
+		})
+		for v := r.Minimum(d) - 1; v <= r.Maximum(d)+1; v++ {
+			c.True(near(want[v]/outcomes, dist.Probability(v)), "%s: P(%d) = %v, want %v", text, v,
+				dist.Probability(v), want[v]/outcomes)
+		}
+	}
+
+	// Eight dice needing a 7 on a d10, with 10s exploding, average 8 * 0.4 * 10/9 successes.
+	dist, err := r.Distribution(r.Parse("8d10>=7!"))
+	c.NoError(err)
+	c.True(near(8*0.4*10/9, dist.Mean()))
+	c.Equal(0, dist.Minimum())
+	c.True(near(0.6*0.6*0.6*0.6*0.6*0.6*0.6*0.6, dist.Probability(0)))
+}
+
+func TestSuccessStrictParse(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for _, text := range []string{"8d10>=7", "8d10>=7dbl10f1!", "8d10>=7f<2!p+1", "(8d10>=7)*2"} {
+		_, err := r.ParseExpression(text)
+		c.NoError(err, text)
+	}
+	for _, text := range []string{
+		"4d6kh3>=5",       // a selection cannot be combined with successes
+		"4d6>=5kh3",       // in either order
+		"8d10>=7!!",       // successes cannot compound
+		"8d10>=7>=8",      // only one success threshold
+		"8d10dbl10",       // doubles need a success threshold
+		"8d10f1",          // as do failures
+		"8d10>=7dbl",      // doubles need a face
+		"8d10>=",          // as do successes
+		"8d10>=7dbl9dbl8", // only one double threshold
+		"8d10>=7f1f2",     // only one failure threshold
+	} {
+		_, err := r.ParseExpression(text)
+		c.HasError(err, text)
+	}
+}
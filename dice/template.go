@@ -0,0 +1,251 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Template holds text with embedded rolls, such as "Attack [[1d20+7]] to hit, [[2d6+4]] slashing". Each roll is a dice
+// expression, as accepted by Roller.ParseFormula, surrounded by "[[" and "]]". A roll may be given a label, as in
+// "[[atk: 1d20+7]]", and a later roll may refer back to an earlier one's result, either by its label, as in
+// "[[$atk]]", or by its zero-based position within the template, as in "[[$0]]". Create one with
+// Roller.ParseTemplate.
+type Template struct {
+	roller *Roller
+	parts  []templatePart
+	tail   string
+}
+
+// templatePart holds a single roll within a Template, along with the literal text that precedes it.
+type templatePart struct {
+	literal string
+	label   string
+	ref     string
+	formula *Formula
+	// target is the index of the roll a reference refers to, or -1 if this is not a reference.
+	target int
+}
+
+// TemplateResult holds the outcome of rolling a Template.
+type TemplateResult struct {
+	// Text is the template with each roll replaced by its total.
+	Text string
+	// Rolls holds one entry for each roll in the template, in the order they appear.
+	Rolls []TemplateRoll
+}
+
+// TemplateRoll holds the outcome of a single roll within a Template.
+type TemplateRoll struct {
+	// Label is the roll's label, or empty if it has none.
+	Label string
+	// Spec is the roll's expression in its canonical form. For a reference, it is the Spec of the roll referred to.
+	Spec string
+	// Results holds the details of each dice term in the roll's expression, in the order they appear. It is nil for a
+	// reference, whose details are found in the roll it refers to.
+	Results []*Result
+	// Total is the roll's result.
+	Total int
+	// Start and End are the start (inclusive) and end (exclusive) index of the Total within the TemplateResult's Text.
+	Start int
+	End   int
+	// Reference is the index within the TemplateResult's Rolls of the roll this refers back to, or -1 if this is not
+	// a reference.
+	Reference int
+}
+
+// ParseTemplate parses text containing embedded rolls into a Template. An error describing the first problem found,
+// such as a malformed expression, a duplicate label or a reference to a roll that does not precede it, is returned as
+// a *ParseError whose Offset is within the text.
+func (r *Roller) ParseTemplate(text string) (*Template, error) {
+	t := &Template{roller: r}
+	labels := make(map[string]int)
+	pos := 0
+	for {
+		i := strings.Index(text[pos:], "[[")
+		if i == -1 {
+			break
+		}
+		open := pos + i
+		start := open + len("[[")
+		i = strings.Index(text[start:], "]]")
+		if i == -1 {
+			return nil, newParseError(text, open, "roll is missing its closing ']]'")
+		}
+		end := start + i
+		part, err := t.parseRoll(text, start, end, labels)
+		if err != nil {
+			return nil, err
+		}
+		part.literal = text[pos:open]
+		if part.label != "" {
+			labels[part.label] = len(t.parts)
+		}
+		t.parts = append(t.parts, part)
+		pos = end + len("]]")
+	}
+	t.tail = text[pos:]
+	return t, nil
+}
+
+// parseRoll parses the contents of a single roll, found in text[start:end].
+func (t *Template) parseRoll(text string, start, end int, labels map[string]int) (templatePart, error) {
+	part := templatePart{target: -1}
+	start, end = trimSpan(text, start, end)
+	if start < end && text[start] == '$' {
+		part.ref = text[start+1 : end]
+		if n, err := strconv.Atoi(part.ref); err == nil && part.ref[0] != '+' && part.ref[0] != '-' {
+			if n >= len(t.parts) {
+				return part, newParseError(text, start, "roll "+part.ref+" does not precede this reference")
+			}
+			part.target = n
+		} else {
+			var ok bool
+			if part.target, ok = labels[part.ref]; !ok {
+				return part, newParseError(text, start, "no earlier roll is labeled "+strconv.Quote(part.ref))
+			}
+		}
+		// Refer to the original roll, rather than to another reference to it.
+		if target := t.parts[part.target].target; target != -1 {
+			part.target = target
+		}
+		return part, nil
+	}
+	if i := strings.IndexByte(text[start:end], ':'); i != -1 {
+		labelStart, labelEnd := trimSpan(text, start, start+i)
+		part.label = text[labelStart:labelEnd]
+		if !validLabel(part.label) {
+			return part, newParseError(text, labelStart, "label must start with a letter or '_' and contain only "+
+				"letters, digits and '_'")
+		}
+		if _, exists := labels[part.label]; exists {
+			return part, newParseError(text, labelStart, "label "+strconv.Quote(part.label)+" is already in use")
+		}
+		start, end = trimSpan(text, start+i+1, end)
+	}
+	var err error
+	if part.formula, err = t.roller.ParseFormula(text[start:end]); err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			return part, newParseError(text, start+parseErr.Offset, parseErr.Reason)
+		}
+		return part, err
+	}
+	return part, nil
+}
+
+// trimSpan returns the span of text[start:end] without any leading or trailing spaces or tabs.
+func trimSpan(text string, start, end int) (trimmedStart, trimmedEnd int) {
+	for start < end && (text[start] == ' ' || text[start] == '\t') {
+		start++
+	}
+	for end > start && (text[end-1] == ' ' || text[end-1] == '\t') {
+		end--
+	}
+	return start, end
+}
+
+// validLabel returns true if the label starts with an ASCII letter or '_' and contains only ASCII letters, digits and
+// '_'.
+func validLabel(label string) bool {
+	if label == "" || isDigit(rune(label[0])) {
+		return false
+	}
+	for i := range len(label) {
+		if ch := label[i]; !isWordByte(ch) && ch != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Variables returns the name of each distinct variable the template's rolls refer to, in the order they first appear.
+// The names do not include the leading '@'.
+func (t *Template) Variables() []string {
+	var names []string
+	for _, part := range t.parts {
+		if part.formula != nil {
+			for _, name := range part.formula.variables {
+				if !slices.Contains(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// RollWith binds the variables of each of the template's rolls to their values from vars, just as Formula.Bind does,
+// and then rolls them.
+func (t *Template) RollWith(vars Variables) (*TemplateResult, error) {
+	exprs := make([]*Expression, len(t.parts))
+	for i, part := range t.parts {
+		if part.formula != nil {
+			var err error
+			if exprs[i], err = part.formula.Bind(vars); err != nil {
+				return nil, err
+			}
+		}
+	}
+	res := &TemplateResult{Rolls: make([]TemplateRoll, len(t.parts))}
+	var buffer strings.Builder
+	for i, part := range t.parts {
+		buffer.WriteString(part.literal)
+		roll := &res.Rolls[i]
+		if part.target == -1 {
+			roll.Label = part.label
+			roll.Spec = exprs[i].String()
+			roll.Total, roll.Results = exprs[i].RollDetailed()
+		} else {
+			target := &res.Rolls[part.target]
+			roll.Spec = target.Spec
+			roll.Total = target.Total
+		}
+		roll.Reference = part.target
+		roll.Start = buffer.Len()
+		buffer.WriteString(strconv.Itoa(roll.Total))
+		roll.End = buffer.Len()
+	}
+	buffer.WriteString(t.tail)
+	res.Text = buffer.String()
+	return res, nil
+}
+
+// Roll each of the template's rolls and return the outcome. An error is returned if a roll refers to a variable; use
+// RollWith to provide their values.
+func (t *Template) Roll() (*TemplateResult, error) {
+	return t.RollWith(nil)
+}
+
+// String returns the template with each roll in its canonical form.
+func (t *Template) String() string {
+	var buffer strings.Builder
+	for _, part := range t.parts {
+		buffer.WriteString(part.literal)
+		buffer.WriteString("[[")
+		if part.target != -1 {
+			buffer.WriteByte('$')
+			buffer.WriteString(part.ref)
+		} else {
+			if part.label != "" {
+				buffer.WriteString(part.label)
+				buffer.WriteString(": ")
+			}
+			buffer.WriteString(part.formula.String())
+		}
+		buffer.WriteString("]]")
+	}
+	buffer.WriteString(t.tail)
+	return buffer.String()
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestTemplate(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, topFaceRandomizer{}, false, false)
+	for i, one := range []struct {
+		Text      string
+		Expected  string
+		Canonical string
+	}{
+		{ // 0
+			"Attack [[1d20+7]] to hit, [[2d6+4]] slashing",
+			"Attack 27 to hit, 16 slashing",
+			"Attack [[d20+7]] to hit, [[2d6+4]] slashing",
+		},
+		{"No rolls here", "No rolls here", "No rolls here"}, // 1
+		{ // 2
+			"[[atk: 1d20+7]] vs AC, crit on [[$atk]]",
+			"27 vs AC, crit on 27",
+			"[[atk: d20+7]] vs AC, crit on [[$atk]]",
+		},
+		{"[[ 2d6 ]] and [[$0]] and [[$1]]", "12 and 12 and 12", "[[2d6]] and [[$0]] and [[$1]]"}, // 3
+		{"[[(1d8+2)*2 - 1d4]]]", "16]", "[[(d8+2)*2-d4]]]"},                                      // 4
+		{"Damage: [[dmg:2d6+1d4+3]]!", "Damage: 19!", "Damage: [[dmg: 2d6+d4+3]]!"},              // 5
+		{"[[_x1: 5]] [[$_x1]]", "5 5", "[[_x1: 5]] [[$_x1]]"},                                    // 6
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		tmpl, err := r.ParseTemplate(one.Text)
+		c.NoError(err, desc)
+		if tmpl == nil {
+			continue
+		}
+		res, err := tmpl.Roll()
+		c.NoError(err, desc)
+		c.Equal(one.Expected, res.Text, desc)
+		for _, roll := range res.Rolls {
+			c.Equal(strconv.Itoa(roll.Total), res.Text[roll.Start:roll.End], desc)
+		}
+		c.Equal(one.Canonical, tmpl.String(), desc)
+	}
+}
+
+func TestTemplateRolls(t *testing.T) {
+	c := check.New(t)
+	rnd := &sequenceRandomizer{values: []int{10, 2, 4, 3, 0, 0, 0, 0}}
+	r := newRoller(c, rnd, false, false)
+	tmpl, err := r.ParseTemplate("Attack [[atk: 1d20+7]] to hit, [[2d6+1d4]] slashing; again [[$atk]]")
+	c.NoError(err)
+	res, err := tmpl.Roll()
+	c.NoError(err)
+	c.Equal("Attack 18 to hit, 12 slashing; again 18", res.Text)
+	c.Equal(3, len(res.Rolls))
+
+	c.Equal("atk", res.Rolls[0].Label)
+	c.Equal("d20+7", res.Rolls[0].Spec)
+	c.Equal(18, res.Rolls[0].Total)
+	c.Equal(-1, res.Rolls[0].Reference)
+	c.Equal(1, len(res.Rolls[0].Results))
+	c.Equal("d20 → [11] = 11", res.Rolls[0].Results[0].String()) // The +7 is a separate term of the expression
+
+	c.Equal("", res.Rolls[1].Label)
+	c.Equal("2d6+d4", res.Rolls[1].Spec)
+	c.Equal(2, len(res.Rolls[1].Results))
+	c.Equal("2d6 → [3, 5] = 8", res.Rolls[1].Results[0].String())
+	c.Equal("d4 → [4] = 4", res.Rolls[1].Results[1].String())
+
+	c.Equal(0, res.Rolls[2].Reference)
+	c.Equal(18, res.Rolls[2].Total)
+	c.Equal("d20+7", res.Rolls[2].Spec)
+	c.True(res.Rolls[2].Results == nil)
+
+	// Each call rolls anew.
+	again, err := tmpl.Roll()
+	c.NoError(err)
+	c.NotEqual(res.Text, again.Text)
+}
+
+func TestTemplateErrors(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, one := range []struct {
+		Text   string
+		Offset int
+	}{
+		{"Attack [[1d20+7 to hit", 7},      // 0 - unclosed roll
+		{"Attack [[1d20+]] to hit", 14},    // 1 - malformed expression
+		{"[[]]", 2},                        // 2 - empty roll
+		{"[[$atk]] [[atk: 1d20]]", 2},      // 3 - reference before the label
+		{"[[$0]]", 2},                      // 4 - reference to itself
+		{"[[1d6]] [[$1]]", 10},             // 5 - reference to a roll that does not precede it
+		{"[[a: 1d6]] [[a: 1d8]]", 13},      // 6 - duplicate label
+		{"[[1a: 1d6]]", 2},                 // 7 - invalid label
+		{"[[ : 1d6]]", 3},                  // 8 - empty label
+		{"[[x: ]]", 4},                     // 9 - label without an expression
+		{"[[$-1]]", 2},                     // 10 - negative position
+		{"[[ a b: 2d6]]", 3},               // 11 - label containing a space
+		{"text [[1d6]] [[1000000d6]]", 15}, // 12 - count exceeds MaxCount
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		tmpl, err := r.ParseTemplate(one.Text)
+		c.HasError(err, desc)
+		c.True(tmpl == nil, desc)
+		var parseErr *dice.ParseError
+		c.True(errors.As(err, &parseErr), desc)
+		if parseErr != nil {
+			c.Equal(one.Text, parseErr.Input, desc)
+			c.Equal(one.Offset, parseErr.Offset, desc)
+		}
+	}
+}
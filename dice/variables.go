@@ -0,0 +1,203 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package dice simulates dice using standard roleplaying game notation.
+package dice
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/richardwilkes/toolbox/v2/errs"
+)
+
+// Variables provides the values of the variables an Expression refers to, such as the attribute modifiers of a
+// character.
+type Variables interface {
+	// Variable returns the value of the named variable and true, or false if no such variable is defined. The name does
+	// not include the leading '@'.
+	Variable(name string) (value int, ok bool)
+}
+
+// VariableMap is a Variables that holds its values in a map.
+type VariableMap map[string]int
+
+// Variable implements Variables.
+func (m VariableMap) Variable(name string) (value int, ok bool) {
+	value, ok = m[name]
+	return value, ok
+}
+
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) roll(_ *Roller, _ *[]*Result) int {
+	return 0
+}
+
+func (n *variableNode) bounds() (minimum, maximum int) {
+	return 0, 0
+}
+
+func (n *variableNode) average(_ *Roller) float64 {
+	return 0
+}
+
+func (n *variableNode) precedence() int {
+	return precedencePrimary
+}
+
+func (n *variableNode) format(_ *Roller, buffer *strings.Builder) {
+	buffer.WriteByte('@')
+	buffer.WriteString(n.name)
+}
+
+// isUnbound returns true if the node depends on a variable that has not been bound.
+func isUnbound(node exprNode) bool {
+	switch n := node.(type) {
+	case *variableNode:
+		return true
+	case *negateNode:
+		return n.unbound
+	case *binaryNode:
+		return n.unbound
+	default:
+		return false
+	}
+}
+
+// Formula holds a dice expression that refers to variables, such as 1d8+@str_mod+@prof. A Formula cannot be rolled
+// itself; bind its variables to values with Bind to obtain an Expression that can. Create one with Roller.ParseFormula.
+type Formula struct {
+	roller *Roller
+	root   exprNode
+	// variables holds the name of each distinct variable the formula references, in the order they first appear.
+	variables []string
+}
+
+// ParseFormula parses a dice expression that may refer to variables into a Formula. A variable is written as '@'
+// followed by its name, as in @str_mod. The expression is otherwise parsed just as ParseExpression does, although the
+// checks for overflow and division by zero of any part that depends on a variable are deferred until it is bound.
+func (r *Roller) ParseFormula(spec string) (*Formula, error) {
+	p := exprParser{roller: r, cfg: r.config(), in: spec, formula: true}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Formula{roller: r, root: root, variables: p.variables}, nil
+}
+
+// String returns the formula in its canonical form, with each dice term formatted by the Roller it was parsed with and
+// each variable shown by name.
+func (f *Formula) String() string {
+	var buffer strings.Builder
+	f.root.format(f.roller, &buffer)
+	return buffer.String()
+}
+
+// parseVariable parses a variable reference: '@' followed by a name that starts with a letter or '_' and contains only
+// letters, digits and '_'.
+func (p *exprParser) parseVariable() (exprNode, error) {
+	start := p.pos
+	if !p.formula {
+		return nil, p.errorf("variables are only permitted in a formula")
+	}
+	p.pos++
+	for p.pos < len(p.in) && (isWordByte(p.in[p.pos]) || p.in[p.pos] == '_') {
+		p.pos++
+	}
+	name := p.in[start+1 : p.pos]
+	if !validLabel(name) {
+		return nil, p.errorAtf(start, "variable name must start with a letter or '_' and contain only letters, "+
+			"digits and '_'")
+	}
+	if !slices.Contains(p.variables, name) {
+		p.variables = append(p.variables, name)
+	}
+	return &variableNode{name: name}, nil
+}
+
+// Variables returns the name of each distinct variable the formula refers to, in the order they first appear. The names
+// do not include the leading '@'.
+func (f *Formula) Variables() []string {
+	return slices.Clone(f.variables)
+}
+
+// Bind returns an Expression with each of the formula's variables replaced by its value from vars, which can then be
+// rolled and its Minimum, Maximum and Average computed. An error is returned if a variable is not defined by vars, if
+// its value exceeds the limits the Config places on a constant, or if the expression could overflow an int or divide
+// by zero once its variables are bound.
+func (f *Formula) Bind(vars Variables) (*Expression, error) {
+	cfg := f.roller.config()
+	root, err := f.bindNode(f.root, vars, max(cfg.MaxCount, cfg.MaxModifier, cfg.MaxMultiplier))
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{roller: f.roller, root: root}, nil
+}
+
+// RollWith binds the formula's variables to their values from vars, just as Bind does, and then rolls it.
+func (f *Formula) RollWith(vars Variables) (int, error) {
+	bound, err := f.Bind(vars)
+	if err != nil {
+		return 0, err
+	}
+	return bound.Roll(), nil
+}
+
+func (f *Formula) bindNode(node exprNode, vars Variables, limit int) (exprNode, error) {
+	if !isUnbound(node) {
+		return node, nil
+	}
+	var reason string
+	switch n := node.(type) {
+	case *variableNode:
+		var value int
+		var ok bool
+		if vars != nil {
+			value, ok = vars.Variable(n.name)
+		}
+		if !ok {
+			return nil, errs.Newf("undefined variable @%s", n.name)
+		}
+		if value > limit || value < -limit {
+			return nil, errs.Newf("variable @%s has the value %d, which exceeds %d", n.name, value, limit)
+		}
+		if value < 0 {
+			// Constants are never negative, just as they are when parsed; a negative value is a negated constant.
+			return &negateNode{operand: &constantNode{value: -value}, min: value, max: value}, nil
+		}
+		return &constantNode{value: value}, nil
+	case *negateNode:
+		operand, err := f.bindNode(n.operand, vars, limit)
+		if err != nil {
+			return nil, err
+		}
+		if node, reason = newNegateNode(operand); reason != "" {
+			return nil, errs.Newf("dice expression %q: %s", f.String(), reason)
+		}
+		return node, nil
+	case *binaryNode:
+		left, err := f.bindNode(n.left, vars, limit)
+		if err != nil {
+			return nil, err
+		}
+		var right exprNode
+		if right, err = f.bindNode(n.right, vars, limit); err != nil {
+			return nil, err
+		}
+		if node, reason = newBinaryNode(n.op, left, right); reason != "" {
+			return nil, errs.Newf("dice expression %q: %s", f.String(), reason)
+		}
+		return node, nil
+	default:
+		return node, nil
+	}
+}
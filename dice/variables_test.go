@@ -0,0 +1,134 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package dice_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+func TestExpressionVariables(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, topFaceRandomizer{}, false, false)
+	vars := dice.VariableMap{"str_mod": 3, "prof": 2, "level": 5, "zero": 0, "neg": -4}
+	for i, one := range []struct {
+		Text      string
+		Unbound   string
+		Variables []string
+		Bound     string
+		Minimum   int
+		Maximum   int
+		Average   int
+	}{
+		{"1d8+@str_mod+@prof", "d8+@str_mod+@prof", []string{"str_mod", "prof"}, "d8+3+2", 6, 13, 9}, // 0
+		{"@level*(1d6)", "@level*d6", []string{"level"}, "5*d6", 5, 30, 17},                          // 1
+		{"2d6 + @str_mod * 2", "2d6+@str_mod*2", []string{"str_mod"}, "2d6+3*2", 8, 18, 13},          // 2
+		{"-@neg + 1d4", "-@neg+d4", []string{"neg"}, "--4+d4", 5, 8, 6},                              // 3
+		{"1d20+@prof+@prof", "d20+@prof+@prof", []string{"prof"}, "d20+2+2", 5, 24, 14},              // 4
+		{"1d10/@level", "d10/@level", []string{"level"}, "d10/5", 0, 2, 1},                           // 5
+		{"(1d6+@_x9)x", "", nil, "", 0, 0, 0},                                                        // 6 - not valid
+		{"3d6", "3d6", nil, "3d6", 3, 18, 10},                                                        // 7
+		{"1d6+@neg", "d6+@neg", []string{"neg"}, "d6+-4", -3, 2, -1},                                 // 8
+		{"@zero", "@zero", []string{"zero"}, "0", 0, 0, 0},                                           // 9
+	} {
+		desc := fmt.Sprintf("Table index %d: %s", i, one.Text)
+		e, err := r.ParseFormula(one.Text)
+		if one.Unbound == "" {
+			c.HasError(err, desc)
+			continue
+		}
+		c.NoError(err, desc)
+		c.Equal(one.Unbound, e.String(), desc)
+		c.Equal(one.Variables, e.Variables(), desc)
+		if len(one.Variables) != 0 {
+			// Without bound variables, the expression cannot be parsed into something that can be rolled.
+			_, err = r.ParseExpression(one.Text)
+			c.HasError(err, desc)
+			_, err = e.Bind(nil)
+			c.HasError(err, desc)
+		}
+		bound, err := e.Bind(vars)
+		c.NoError(err, desc)
+		c.Equal(one.Bound, bound.String(), desc)
+		c.Equal(one.Minimum, bound.Minimum(), desc)
+		c.Equal(one.Maximum, bound.Maximum(), desc)
+		c.Equal(one.Average, bound.Average(), desc)
+		c.Equal(one.Maximum, bound.Roll(), desc)
+		total, err := e.RollWith(vars)
+		c.NoError(err, desc)
+		c.Equal(one.Maximum, total, desc)
+		// Binding leaves the original expression untouched.
+		c.Equal(one.Unbound, e.String(), desc)
+	}
+}
+
+func TestExpressionVariableErrors(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, nil, false, false)
+	for i, text := range []string{"1d6+@", "1d6+@9lives", "1d6+@@x", "@x@y", "1d6+str_mod"} {
+		_, err := r.ParseFormula(text)
+		c.HasError(err, "Table index %d: %s", i, text)
+	}
+
+	e, err := r.ParseFormula("1d8+@str_mod+@prof")
+	c.NoError(err)
+	_, err = e.Bind(dice.VariableMap{"str_mod": 3})
+	c.HasError(err) // prof is undefined
+	_, err = e.RollWith(nil)
+	c.HasError(err)
+	_, err = e.Bind(dice.VariableMap{"str_mod": 3, "prof": 1_000_000})
+	c.HasError(err) // exceeds the limit for a constant
+
+	e, err = r.ParseFormula("1d6/@divisor")
+	c.NoError(err)
+	_, err = e.Bind(dice.VariableMap{"divisor": 0})
+	c.HasError(err)
+	_, err = e.Bind(dice.VariableMap{"divisor": 2})
+	c.NoError(err)
+
+	e, err = r.ParseFormula("@a*@a*@a*@a*@a")
+	c.NoError(err)
+	_, err = e.Bind(dice.VariableMap{"a": 999_999})
+	c.HasError(err) // overflow
+	bound, err := e.Bind(dice.VariableMap{"a": 10})
+	c.NoError(err)
+	c.Equal(100_000, bound.Roll())
+
+	cfg := dice.DefaultConfig()
+	cfg.MaxModifier = math.MaxInt32
+	r, err = dice.NewRoller(cfg)
+	c.NoError(err)
+	e, err = r.ParseFormula("-@a")
+	c.NoError(err)
+	bound, err = e.Bind(dice.VariableMap{"a": math.MaxInt32})
+	c.NoError(err)
+	c.Equal(-math.MaxInt32, bound.Roll())
+}
+
+func TestTemplateVariables(t *testing.T) {
+	c := check.New(t)
+	r := newRoller(c, topFaceRandomizer{}, false, false)
+	tmpl, err := r.ParseTemplate("Attack [[atk: 1d20+@str_mod+@prof]] to hit, [[1d8+@str_mod]] slashing")
+	c.NoError(err)
+	c.Equal("Attack [[atk: d20+@str_mod+@prof]] to hit, [[d8+@str_mod]] slashing", tmpl.String())
+	res, err := tmpl.RollWith(dice.VariableMap{"str_mod": 3, "prof": 2})
+	c.NoError(err)
+	c.Equal("Attack 25 to hit, 11 slashing", res.Text)
+	c.Equal("d20+3+2", res.Rolls[0].Spec)
+	_, err = tmpl.RollWith(dice.VariableMap{"str_mod": 3})
+	c.HasError(err)
+	c.Equal([]string{"str_mod", "prof"}, tmpl.Variables())
+	_, err = tmpl.Roll()
+	c.HasError(err)
+}
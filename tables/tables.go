@@ -0,0 +1,409 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+// Package tables provides random tables, such as those for loot, encounters and rumors, whose rows are chosen by a roll
+// of the dice or by weight.
+package tables
+
+import (
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/toolbox/v2/errs"
+	"github.com/richardwilkes/toolbox/v2/xrand"
+	"gopkg.in/yaml.v3"
+)
+
+// maxTotalWeight bounds the sum of the weights of a weighted table's rows, keeping the value passed to the Randomizer
+// within the range of an int32.
+const maxTotalWeight = math.MaxInt32
+
+// Table defines a random table. A table whose Dice is set chooses a row by rolling the dice and finding the row whose
+// Range holds the result, while a table without Dice chooses a row at random, with each row's chance of being chosen
+// proportional to its Weight.
+type Table struct {
+	// Dice is the dice to roll to choose a row, such as "d100" or "2d6", or "d66" and "d%" when the Roller's Config has
+	// DigitDice enabled. When empty, rows are chosen by weight.
+	Dice string `json:"dice,omitempty" yaml:"dice,omitempty"`
+	Rows []Row  `json:"rows" yaml:"rows"`
+}
+
+// Row defines a single row of a Table.
+type Row struct {
+	// Text is the row's result. It may embed dice expressions, as in "[[2d6]] goblins", which are rolled when the row
+	// is chosen, just as a dice.Template is, and references to other tables of the Set, as in "a chest holding {{gems}}",
+	// each of which is replaced by the result of rolling that table.
+	Text string `json:"text" yaml:"text"`
+	// Range is the range of dice results that choose the row in a table with Dice, such as "01-15", "16-40" or "41".
+	// A bound of two or more zeros, such as the "00" of "96-00", stands for the power of ten it rolls over to; that is,
+	// 100 for "00" and 1000 for "000". Either bound may be negative, as in "-4--2" for a table rolled on 4dF.
+	Range string `json:"range,omitempty" yaml:"range,omitempty"`
+	// Weight is the row's relative chance of being chosen in a table without Dice. A Weight of 0 is treated as 1.
+	Weight int `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+// Result holds the outcome of rolling a table.
+type Result struct {
+	// Table is the name of the table that was rolled.
+	Table string
+	// Text is the chosen row's Text, with its embedded dice rolled and its references to other tables replaced by the
+	// Text of their results.
+	Text string
+	// Nested holds the result of each table the chosen row refers to, in the order they appear.
+	Nested []*Result
+	// Roll is the result of the table's Dice or, for a table chosen by weight, the position within the total weight
+	// that was drawn, starting at 1.
+	Roll int
+	// Row is the index of the chosen row within the table's Rows.
+	Row int
+}
+
+// Set holds a collection of named tables that have been validated, ready to be rolled. Tables within a Set may refer to
+// each other.
+type Set struct {
+	rnd    xrand.Randomizer
+	roller *dice.Roller
+	tables map[string]*table
+}
+
+type table struct {
+	rows []*row
+	dice dice.Dice
+	// weighted is true if rows are chosen by weight rather than by rolling dice.
+	weighted bool
+}
+
+type row struct {
+	tmpl *dice.Template
+	refs []string
+	// low and high hold the inclusive range of dice results that choose the row or, for a weighted table, the range of
+	// positions within the total weight.
+	low  int
+	high int
+}
+
+// NewSet validates the tables and returns a Set that rolls them with the Roller, which also provides the Randomizer
+// used to choose rows by weight. A nil Roller uses the default dice Config. An error describing the first problem found
+// is returned if a table has no rows, a range is malformed, ranges overlap or leave a possible result of the dice
+// uncovered, a row refers to a table that does not exist, or tables refer to each other in a cycle.
+func NewSet(roller *dice.Roller, tables map[string]*Table) (*Set, error) {
+	s := &Set{
+		rnd:    roller.Config().Randomizer,
+		roller: roller,
+		tables: make(map[string]*table, len(tables)),
+	}
+	for _, name := range slices.Sorted(maps.Keys(tables)) {
+		if name == "" || strings.ContainsAny(name, "{}") {
+			return nil, errs.Newf("invalid table name %q", name)
+		}
+		def := tables[name]
+		if def == nil {
+			return nil, errs.Newf("table %q is empty", name)
+		}
+		t, err := s.newTable(def)
+		if err != nil {
+			return nil, errs.NewWithCausef(err, "invalid table %q", name)
+		}
+		s.tables[name] = t
+	}
+	checked := make(map[string]bool, len(s.tables))
+	for _, name := range slices.Sorted(maps.Keys(s.tables)) {
+		if err := s.checkRefs(name, nil, checked); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// LoadJSON loads a Set from JSON holding an object that maps each table's name to its Table. See NewSet.
+func LoadJSON(roller *dice.Roller, data []byte) (*Set, error) {
+	var tables map[string]*Table
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	return NewSet(roller, tables)
+}
+
+// LoadYAML loads a Set from YAML holding a mapping of each table's name to its Table. See NewSet.
+func LoadYAML(roller *dice.Roller, data []byte) (*Set, error) {
+	var tables map[string]*Table
+	if err := yaml.Unmarshal(data, &tables); err != nil {
+		return nil, errs.Wrap(err)
+	}
+	return NewSet(roller, tables)
+}
+
+func (s *Set) newTable(def *Table) (*table, error) {
+	if len(def.Rows) == 0 {
+		return nil, errs.New("the table has no rows")
+	}
+	t := &table{rows: make([]*row, len(def.Rows)), weighted: def.Dice == ""}
+	if !t.weighted {
+		var err error
+		if t.dice, err = s.roller.ParseStrict(def.Dice); err != nil {
+			return nil, err
+		}
+	}
+	total := 0
+	for i := range def.Rows {
+		one := &def.Rows[i]
+		r, err := s.newRow(one)
+		if err != nil {
+			return nil, errs.NewWithCausef(err, "invalid row %d", i)
+		}
+		if t.weighted {
+			if one.Range != "" {
+				return nil, errs.Newf("row %d: a range requires the table to have dice", i)
+			}
+			if one.Weight < 0 {
+				return nil, errs.Newf("row %d: the weight may not be negative", i)
+			}
+			weight := max(one.Weight, 1)
+			if weight > maxTotalWeight-total {
+				return nil, errs.Newf("row %d: the total weight exceeds %d", i, maxTotalWeight)
+			}
+			r.low = total + 1
+			total += weight
+			r.high = total
+		} else {
+			if one.Weight != 0 {
+				return nil, errs.Newf("row %d: a weight requires the table to have no dice", i)
+			}
+			if r.low, r.high, err = parseRange(one.Range); err != nil {
+				return nil, errs.NewWithCausef(err, "invalid row %d", i)
+			}
+		}
+		t.rows[i] = r
+	}
+	if !t.weighted {
+		if err := s.checkCoverage(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (s *Set) newRow(def *Row) (*row, error) {
+	r := &row{}
+	var err error
+	if r.tmpl, err = s.roller.ParseTemplate(def.Text); err != nil {
+		return nil, err
+	}
+	if vars := r.tmpl.Variables(); len(vars) != 0 {
+		return nil, errs.Newf("dice refer to the variable @%s, which tables cannot provide", vars[0])
+	}
+	text := def.Text
+	for {
+		start := strings.Index(text, "{{")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(text[start:], "}}")
+		if end == -1 {
+			return nil, errs.New("table reference is missing its closing '}}'")
+		}
+		name := strings.TrimSpace(text[start+2 : start+end])
+		if name == "" {
+			return nil, errs.New("table reference is empty")
+		}
+		r.refs = append(r.refs, name)
+		text = text[start+end+2:]
+	}
+	return r, nil
+}
+
+// checkCoverage verifies that the rows of a table with dice do not overlap, that every result the dice can produce
+// chooses a row, and that every row can be chosen.
+func (s *Set) checkCoverage(t *table) error {
+	dist, err := s.roller.Distribution(t.dice)
+	if err != nil {
+		return err
+	}
+	sorted := slices.Clone(t.rows)
+	slices.SortFunc(sorted, func(a, b *row) int { return a.low - b.low })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].low <= sorted[i-1].high {
+			return errs.Newf("range %s overlaps range %s", formatRange(sorted[i-1]), formatRange(sorted[i]))
+		}
+	}
+	reachable := make([]bool, len(sorted))
+	for v := range dist.All() {
+		i, _ := slices.BinarySearchFunc(sorted, v, func(r *row, v int) int {
+			switch {
+			case r.high < v:
+				return -1
+			case r.low > v:
+				return 1
+			default:
+				return 0
+			}
+		})
+		if i == len(sorted) || sorted[i].low > v {
+			return errs.Newf("no row covers a roll of %d", v)
+		}
+		reachable[i] = true
+	}
+	for i, ok := range reachable {
+		if !ok {
+			return errs.Newf("range %s can never be rolled", formatRange(sorted[i]))
+		}
+	}
+	return nil
+}
+
+// checkRefs verifies that the table and those it refers to, directly or indirectly, refer only to tables that exist
+// and never back to a table in the chain of references that led to it. Tables already found to be sound are recorded
+// in checked, so that each is only examined once.
+func (s *Set) checkRefs(name string, chain []string, checked map[string]bool) error {
+	if checked[name] {
+		return nil
+	}
+	if slices.Contains(chain, name) {
+		return errs.Newf("tables refer to each other in a cycle: %s", strings.Join(append(chain, name), " → "))
+	}
+	chain = append(chain, name)
+	for i, r := range s.tables[name].rows {
+		for _, ref := range r.refs {
+			if _, exists := s.tables[ref]; !exists {
+				return errs.Newf("table %q: row %d: refers to table %q, which does not exist", name, i, ref)
+			}
+			if err := s.checkRefs(ref, chain, checked); err != nil {
+				return err
+			}
+		}
+	}
+	checked[name] = true
+	return nil
+}
+
+// Names returns the names of the tables in the Set, in sorted order.
+func (s *Set) Names() []string {
+	return slices.Sorted(maps.Keys(s.tables))
+}
+
+// Roll the named table, choosing a row and resolving its embedded dice and table references. An error is returned if
+// the Set has no such table.
+func (s *Set) Roll(name string) (*Result, error) {
+	t, exists := s.tables[name]
+	if !exists {
+		return nil, errs.Newf("no table named %q", name)
+	}
+	return s.roll(name, t)
+}
+
+func (s *Set) roll(name string, t *table) (*Result, error) {
+	res := &Result{Table: name}
+	if t.weighted {
+		res.Roll = 1 + s.rnd.Intn(t.rows[len(t.rows)-1].high)
+	} else {
+		res.Roll = s.roller.Roll(t.dice)
+	}
+	for i, r := range t.rows {
+		if r.low <= res.Roll && res.Roll <= r.high {
+			res.Row = i
+			break
+		}
+	}
+	r := t.rows[res.Row]
+	out, err := r.tmpl.Roll()
+	if err != nil {
+		return nil, err
+	}
+	text := out.Text
+	if len(r.refs) == 0 {
+		res.Text = text
+		return res, nil
+	}
+	var buffer strings.Builder
+	for _, ref := range r.refs {
+		start := strings.Index(text, "{{")
+		end := start + strings.Index(text[start:], "}}")
+		nested, err := s.roll(ref, s.tables[ref])
+		if err != nil {
+			return nil, err
+		}
+		res.Nested = append(res.Nested, nested)
+		buffer.WriteString(text[:start])
+		buffer.WriteString(nested.Text)
+		text = text[end+2:]
+	}
+	buffer.WriteString(text)
+	res.Text = buffer.String()
+	return res, nil
+}
+
+// parseRange parses a range such as "01-15", "41" or "-4--2". Either bound may be negative, so the bounds are split at
+// the first '-' that follows a digit.
+func parseRange(text string) (low, high int, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, 0, errs.New("a range is required when the table has dice")
+	}
+	lowText, highText, found := text, "", false
+	for i := 1; i < len(text); i++ {
+		if text[i] == '-' {
+			if before := strings.TrimSpace(text[:i]); before != "" && isDigit(before[len(before)-1]) {
+				lowText, highText, found = text[:i], text[i+1:], true
+				break
+			}
+		}
+	}
+	if low, err = parseBound(lowText); err != nil {
+		return 0, 0, errs.Newf("invalid range %q", text)
+	}
+	high = low
+	if found {
+		if high, err = parseBound(highText); err != nil {
+			return 0, 0, errs.Newf("invalid range %q", text)
+		}
+	}
+	if low > high {
+		return 0, 0, errs.Newf("range %q ends before it starts", text)
+	}
+	return low, high, nil
+}
+
+// parseBound parses one end of a range, which may be negative. A bound of two or more zeros is the power of ten it
+// rolls over to.
+func parseBound(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	digits := strings.TrimPrefix(text, "-")
+	if digits == "" {
+		return 0, errs.New("missing bound")
+	}
+	for i := range len(digits) {
+		if !isDigit(digits[i]) {
+			return 0, errs.New("bound must contain only digits, after an optional '-'")
+		}
+	}
+	if len(text) > 1 && strings.Trim(text, "0") == "" {
+		text = "1" + text
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, errs.Wrap(err)
+	}
+	return v, nil
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func formatRange(r *row) string {
+	if r.low == r.high {
+		return strconv.Itoa(r.low)
+	}
+	return strconv.Itoa(r.low) + "-" + strconv.Itoa(r.high)
+}
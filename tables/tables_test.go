@@ -0,0 +1,342 @@
+// Copyright (c) 2017-2026 by Richard A. Wilkes. All rights reserved.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, version 2.0. If a copy of the MPL was not distributed with
+// this file, You can obtain one at http://mozilla.org/MPL/2.0/.
+//
+// This Source Code Form is "Incompatible With Secondary Licenses", as
+// defined by the Mozilla Public License, version 2.0.
+
+package tables_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/richardwilkes/rpgtools/dice"
+	"github.com/richardwilkes/rpgtools/tables"
+	"github.com/richardwilkes/toolbox/v2/check"
+)
+
+const lootYAML = `
+loot:
+  dice: d100
+  rows:
+    - range: 01-15
+      text: Nothing
+    - range: 16-40
+      text: "[[2d6]] gold pieces"
+    - range: 41-95
+      text: A pouch holding {{gems}} and {{ gems }}
+    - range: 96-00
+      text: "{{magic}}"
+gems:
+  rows:
+    - text: a ruby
+    - text: "[[1d4]] pearls"
+      weight: 3
+magic:
+  dice: 1d6
+  rows:
+    - range: 1-5
+      text: a potion
+    - range: 6
+      text: a wand
+`
+
+const lootJSON = `{
+	"loot": {
+		"dice": "d100",
+		"rows": [
+			{"range": "01-15", "text": "Nothing"},
+			{"range": "16-40", "text": "[[2d6]] gold pieces"},
+			{"range": "41-95", "text": "A pouch holding {{gems}} and {{ gems }}"},
+			{"range": "96-00", "text": "{{magic}}"}
+		]
+	},
+	"gems": {
+		"rows": [
+			{"text": "a ruby"},
+			{"text": "[[1d4]] pearls", "weight": 3}
+		]
+	},
+	"magic": {
+		"dice": "1d6",
+		"rows": [
+			{"range": "1-5", "text": "a potion"},
+			{"range": "6", "text": "a wand"}
+		]
+	}
+}`
+
+// sequenceRandomizer returns each of its values in turn, wrapping around to the start once they have all been used.
+type sequenceRandomizer struct {
+	values []int
+	next   int
+}
+
+func (s *sequenceRandomizer) Intn(n int) int {
+	v := s.values[s.next%len(s.values)]
+	s.next++
+	return v % n
+}
+
+func newRoller(c check.Checker, values ...int) *dice.Roller {
+	c.Helper()
+	cfg := dice.DefaultConfig()
+	cfg.Randomizer = &sequenceRandomizer{values: values}
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	return r
+}
+
+func TestRoll(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Values []int
+		Text   string
+		Roll   int
+		Row    int
+		Nested int
+	}{
+		{[]int{0}, "Nothing", 1, 0, 0},                                        // 0
+		{[]int{14}, "Nothing", 15, 0, 0},                                      // 1
+		{[]int{20, 2, 4}, "8 gold pieces", 21, 1, 0},                          // 2
+		{[]int{50, 0, 3, 1}, "A pouch holding a ruby and 2 pearls", 51, 2, 2}, // 3
+		{[]int{99, 5}, "a wand", 100, 3, 1},                                   // 4
+		{[]int{95, 0}, "a potion", 96, 3, 1},                                  // 5
+	} {
+		for _, format := range []struct {
+			load func(*dice.Roller, []byte) (*tables.Set, error)
+			data string
+		}{{tables.LoadYAML, lootYAML}, {tables.LoadJSON, lootJSON}} {
+			desc := fmt.Sprintf("Table index %d", i)
+			set, err := format.load(newRoller(c, one.Values...), []byte(format.data))
+			c.NoError(err, desc)
+			c.Equal([]string{"gems", "loot", "magic"}, set.Names(), desc)
+			res, err := set.Roll("loot")
+			c.NoError(err, desc)
+			c.Equal("loot", res.Table, desc)
+			c.Equal(one.Text, res.Text, desc)
+			c.Equal(one.Roll, res.Roll, desc)
+			c.Equal(one.Row, res.Row, desc)
+			c.Equal(one.Nested, len(res.Nested), desc)
+		}
+	}
+
+	set, err := tables.LoadYAML(newRoller(c, 50, 0, 3, 1), []byte(lootYAML))
+	c.NoError(err)
+	res, err := set.Roll("loot")
+	c.NoError(err)
+	c.Equal("gems", res.Nested[0].Table)
+	c.Equal(1, res.Nested[0].Roll)
+	c.Equal(0, res.Nested[0].Row)
+	c.Equal(4, res.Nested[1].Roll)
+	c.Equal(1, res.Nested[1].Row)
+	c.Equal("2 pearls", res.Nested[1].Text)
+
+	_, err = set.Roll("treasure")
+	c.HasError(err)
+}
+
+func TestWeights(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Value int
+		Text  string
+	}{
+		{0, "common"},   // 0
+		{5, "common"},   // 1
+		{6, "uncommon"}, // 2
+		{8, "uncommon"}, // 3
+		{9, "rare"},     // 4
+	} {
+		set, err := tables.NewSet(newRoller(c, one.Value), map[string]*tables.Table{
+			"rarity": {Rows: []tables.Row{
+				{Text: "common", Weight: 6},
+				{Text: "uncommon", Weight: 3},
+				{Text: "rare"},
+			}},
+		})
+		c.NoError(err)
+		res, err := set.Roll("rarity")
+		c.NoError(err, "Table index %d", i)
+		c.Equal(one.Text, res.Text, "Table index %d", i)
+		c.Equal(one.Value+1, res.Roll, "Table index %d", i)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Tables map[string]*tables.Table
+		Valid  bool
+	}{
+		{ // 0
+			map[string]*tables.Table{"t": {Dice: "2d6", Rows: []tables.Row{{Range: "2-6"}, {Range: "7"}, {Range: "8-12"}}}},
+			true,
+		},
+		{ // 1 - gap
+			map[string]*tables.Table{"t": {Dice: "2d6", Rows: []tables.Row{{Range: "2-6"}, {Range: "8-12"}}}},
+			false,
+		},
+		{ // 2 - overlap
+			map[string]*tables.Table{"t": {Dice: "2d6", Rows: []tables.Row{{Range: "2-7"}, {Range: "7-12"}}}},
+			false,
+		},
+		{ // 3 - a row that can never be rolled
+			map[string]*tables.Table{"t": {Dice: "1d6", Rows: []tables.Row{{Range: "1-6"}, {Range: "7-8"}}}},
+			false,
+		},
+		{ // 4 - results that cannot occur need not be covered
+			map[string]*tables.Table{"t": {Dice: "1d3x2", Rows: []tables.Row{{Range: "2"}, {Range: "4"}, {Range: "6"}}}},
+			true,
+		},
+		{ // 5 - reference to a missing table
+			map[string]*tables.Table{"t": {Rows: []tables.Row{{Text: "{{missing}}"}}}},
+			false,
+		},
+		{ // 6 - cycle
+			map[string]*tables.Table{
+				"a": {Rows: []tables.Row{{Text: "x"}, {Text: "{{b}}"}}},
+				"b": {Rows: []tables.Row{{Text: "{{c}}"}}},
+				"c": {Rows: []tables.Row{{Text: "{{a}}"}}},
+			},
+			false,
+		},
+		{ // 7 - malformed range
+			map[string]*tables.Table{"t": {Dice: "1d6", Rows: []tables.Row{{Range: "1-x"}, {Range: "4-6"}}}},
+			false,
+		},
+		{ // 8 - reversed range
+			map[string]*tables.Table{"t": {Dice: "1d6", Rows: []tables.Row{{Range: "3-1"}, {Range: "4-6"}}}},
+			false,
+		},
+		{ // 9 - missing range
+			map[string]*tables.Table{"t": {Dice: "1d6", Rows: []tables.Row{{Text: "x"}}}},
+			false,
+		},
+		{ // 10 - range without dice
+			map[string]*tables.Table{"t": {Rows: []tables.Row{{Range: "1-6"}}}},
+			false,
+		},
+		{ // 11 - weight with dice
+			map[string]*tables.Table{"t": {Dice: "1d6", Rows: []tables.Row{{Range: "1-6", Weight: 2}}}},
+			false,
+		},
+		{ // 12 - no rows
+			map[string]*tables.Table{"t": {Dice: "1d6"}},
+			false,
+		},
+		{ // 13 - malformed dice
+			map[string]*tables.Table{"t": {Dice: "1d6q", Rows: []tables.Row{{Range: "1-6"}}}},
+			false,
+		},
+		{ // 14 - malformed embedded dice
+			map[string]*tables.Table{"t": {Rows: []tables.Row{{Text: "[[1d6+]]"}}}},
+			false,
+		},
+		{ // 15 - unclosed reference
+			map[string]*tables.Table{"t": {Rows: []tables.Row{{Text: "{{t"}}}},
+			false,
+		},
+		{ // 16 - negative weight
+			map[string]*tables.Table{"t": {Rows: []tables.Row{{Text: "x", Weight: -1}}}},
+			false,
+		},
+		{ // 17 - the same table referred to along separate paths is not a cycle
+			map[string]*tables.Table{
+				"a": {Rows: []tables.Row{{Text: "{{b}} {{c}}"}}},
+				"b": {Rows: []tables.Row{{Text: "{{c}}"}}},
+				"c": {Rows: []tables.Row{{Text: "x"}}},
+			},
+			true,
+		},
+		{ // 18 - empty table
+			map[string]*tables.Table{"t": nil},
+			false,
+		},
+		{ // 19 - embedded dice with a variable, which a table cannot provide
+			map[string]*tables.Table{"t": {Rows: []tables.Row{{Text: "[[1d6+@bonus]]"}}}},
+			false,
+		},
+		{ // 20 - negative bounds
+			map[string]*tables.Table{"t": {Dice: "4dF", Rows: []tables.Row{
+				{Range: "-4--1"}, {Range: "0"}, {Range: "1 - 4"},
+			}}},
+			true,
+		},
+		{ // 21 - a negative range that can never be rolled
+			map[string]*tables.Table{"t": {Dice: "4dF", Rows: []tables.Row{{Range: "-6--5"}, {Range: "-4-4"}}}},
+			false,
+		},
+		{ // 22 - a reversed negative range
+			map[string]*tables.Table{"t": {Dice: "4dF", Rows: []tables.Row{{Range: "-1--4"}, {Range: "0-4"}}}},
+			false,
+		},
+		{ // 23 - a sign without digits
+			map[string]*tables.Table{"t": {Dice: "4dF", Rows: []tables.Row{{Range: "--4"}, {Range: "-3-4"}}}},
+			false,
+		},
+	} {
+		_, err := tables.NewSet(nil, one.Tables)
+		if one.Valid {
+			c.NoError(err, "Table index %d", i)
+		} else {
+			c.HasError(err, "Table index %d", i)
+		}
+	}
+}
+
+func TestDigitDice(t *testing.T) {
+	c := check.New(t)
+	cfg := dice.DefaultConfig()
+	cfg.DigitDice = true
+	// Each digit is read from a separate die: 2 and 3 from the d6 of the d66, then 0 and 9 from the d10 of the d%.
+	cfg.Randomizer = &sequenceRandomizer{values: []int{1, 2, 0, 9}}
+	r, err := dice.NewRoller(cfg)
+	c.NoError(err)
+	rows := []tables.Row{{Range: "11-16", Text: "ones"}, {Range: "21-36", Text: "middle"}, {Range: "41-66", Text: "high"}}
+	set, err := tables.NewSet(r, map[string]*tables.Table{"t": {Dice: "d66", Rows: rows}})
+	c.NoError(err)
+	res, err := set.Roll("t")
+	c.NoError(err)
+	c.Equal(23, res.Roll)
+	c.Equal("middle", res.Text)
+
+	// Results that a d66 cannot roll, such as 17 through 20, cannot be given a row of their own.
+	_, err = tables.NewSet(r, map[string]*tables.Table{"t": {Dice: "d66", Rows: append(rows,
+		tables.Row{Range: "17-20", Text: "never"})}})
+	c.HasError(err)
+
+	set, err = tables.NewSet(r, map[string]*tables.Table{"t": {Dice: "d%", Rows: []tables.Row{
+		{Range: "01-50", Text: "low"}, {Range: "51-00", Text: "high"},
+	}}})
+	c.NoError(err)
+	res, err = set.Roll("t")
+	c.NoError(err)
+	c.Equal(9, res.Roll)
+	c.Equal("low", res.Text)
+}
+
+func TestNegativeRanges(t *testing.T) {
+	c := check.New(t)
+	for i, one := range []struct {
+		Values []int
+		Text   string
+		Roll   int
+	}{
+		{[]int{0, 0, 0, 1}, "terrible", -3}, // 0
+		{[]int{0, 1, 2, 1}, "fair", 0},      // 1
+		{[]int{2, 2, 1, 2}, "great", 3},     // 2
+	} {
+		desc := fmt.Sprintf("Table index %d", i)
+		rows := []tables.Row{{Range: "-4--2", Text: "terrible"}, {Range: "-1-1", Text: "fair"}, {Range: "2-4", Text: "great"}}
+		set, err := tables.NewSet(newRoller(c, one.Values...), map[string]*tables.Table{"t": {Dice: "4dF", Rows: rows}})
+		c.NoError(err, desc)
+		res, err := set.Roll("t")
+		c.NoError(err, desc)
+		c.Equal(one.Roll, res.Roll, desc)
+		c.Equal(one.Text, res.Text, desc)
+	}
+}